@@ -0,0 +1,420 @@
+package bayes
+
+import (
+	"math"
+	"sync"
+
+	"github.com/pkg/errors"
+)
+
+// ============================================================================
+//  Anomaly detection
+// ============================================================================
+//  The Detector flags the transitions which the trained model rarely or never
+//  saw. The surprise of an item is -ln(p), where p is the probability of the
+//  item given its previous items as in `Score()`. The item is anomalous if the
+//  surprise exceeds the adaptive threshold:
+//
+//    threshold = max(MinSurprise, mean + Sensitivity * stddev)
+//
+//  where the mean and the standard deviation are the exponentially weighted
+//  ones of the finite surprises observed so far. So the threshold follows the
+//  usual level of the surprise of the stream. The transitions never seen are
+//  the surprise of +Inf and always anomalous.
+//
+//  The consecutive anomalous items are grouped into a span.
+// ============================================================================
+
+const (
+	// DetectorSensitivityDefault is the default sensitivity of the Detector,
+	// which is the number of the standard deviations above the mean.
+	DetectorSensitivityDefault = 3.0
+	// DetectorMinSurpriseDefault is the default minimum threshold of the
+	// surprise, which flags the items less probable than about 5%.
+	DetectorMinSurpriseDefault = 3.0
+	// DetectorAdaptRateDefault is the default weight of the latest surprise to
+	// update the mean and the variance.
+	DetectorAdaptRateDefault = 0.05
+	// DetectorWarmupDefault is the default number of the surprises observed
+	// before the threshold adapts. Until then, the threshold is MinSurprise.
+	DetectorWarmupDefault = 10
+	// DetectorContextMax is the maximum number of the previous items kept as the
+	// context if the maximum order of the predictor is unlimited.
+	DetectorContextMax = 32
+)
+
+// ----------------------------------------------------------------------------
+//  Type: Detector
+// ----------------------------------------------------------------------------
+
+// Detector detects the anomalous items of the sequences under the trained
+// predictor. Feed the items as they arrive via `Observe()`, or the whole
+// sequence via `Detect()`.
+//
+// The methods are safe for concurrent use, but the items observed concurrently
+// are treated as a single sequence in the order of the calls. Use a Detector
+// per sequence, such as per user session, sharing the same predictor.
+//
+// Use `NewDetector()` to create an instance.
+type Detector struct {
+	// predictor is the trained predictor to score the items.
+	predictor *Predictor
+	// span is the span of the anomalous items being observed. Nil if the last
+	// item is not anomalous.
+	span *AnomalySpan
+	// contextIDs are the IDs of the previous items of the sequence.
+	contextIDs []uint64
+	// sensitivity is the number of the standard deviations above the mean.
+	sensitivity float64
+	// minSurprise is the minimum threshold.
+	minSurprise float64
+	// adaptRate is the weight of the latest surprise to update the statistics.
+	adaptRate float64
+	// mean and variance are the exponentially weighted statistics of the
+	// surprises.
+	mean     float64
+	variance float64
+	// warmup is the number of the surprises observed before the adaption.
+	warmup int
+	// numObserved is the number of the finite surprises observed.
+	numObserved int
+	// index is the index of the next item in the sequence.
+	index int
+	// mu protects the fields above.
+	mu sync.Mutex
+}
+
+// Observation is the result of an item given to the Detector.
+type Observation struct {
+	// Item is the item observed.
+	Item any
+	// Closed is the span of the anomalous items which ended right before the
+	// item. Nil if the previous item is not anomalous or the item is anomalous.
+	Closed *AnomalySpan
+	// Index is the index of the item in the sequence.
+	Index int
+	// Probability is the probability of the item given the previous items.
+	Probability float64
+	// Surprise is -ln(Probability). It is 0 for the first item of the sequence,
+	// which has no context to be scored.
+	Surprise float64
+	// Threshold is the threshold of the surprise at the time of observation.
+	Threshold float64
+	// Anomalous is true if Surprise exceeds Threshold.
+	Anomalous bool
+}
+
+// AnomalySpan is a span of the consecutive anomalous items of a sequence.
+type AnomalySpan struct {
+	// Items are the anomalous items in the span.
+	Items []any
+	// Surprises are the surprises of the items.
+	Surprises []float64
+	// Start is the index of the first item of the span in the sequence.
+	Start int
+	// End is the index next to the last item of the span.
+	End int
+	// MaxSurprise is the maximum surprise in the span.
+	MaxSurprise float64
+}
+
+// ----------------------------------------------------------------------------
+//  Type: DetectorOption
+// ----------------------------------------------------------------------------
+
+// DetectorOption is a functional option of `NewDetector()`.
+type DetectorOption func(*Detector)
+
+// WithDetectorAdaptRate sets the weight of the latest surprise to update the
+// mean and the variance, between 0 and 1. The higher, the faster the threshold
+// follows the change of the stream. Default: DetectorAdaptRateDefault.
+func WithDetectorAdaptRate(rate float64) DetectorOption {
+	return func(d *Detector) {
+		d.adaptRate = rate
+	}
+}
+
+// WithDetectorMinSurprise sets the minimum threshold of the surprise, so that
+// the usual transitions are never flagged in a stable stream with a small
+// deviation. e.g. -ln(0.01) flags only the items less probable than 1%.
+// Default: DetectorMinSurpriseDefault.
+func WithDetectorMinSurprise(surprise float64) DetectorOption {
+	return func(d *Detector) {
+		d.minSurprise = surprise
+	}
+}
+
+// WithDetectorSensitivity sets the number of the standard deviations above the
+// mean of the threshold. The lower, the more items are flagged. Default:
+// DetectorSensitivityDefault.
+func WithDetectorSensitivity(sensitivity float64) DetectorOption {
+	return func(d *Detector) {
+		d.sensitivity = sensitivity
+	}
+}
+
+// WithDetectorWarmup sets the number of the surprises observed before the
+// threshold adapts. Until then, the threshold is the minimum one. Default:
+// DetectorWarmupDefault.
+func WithDetectorWarmup(numObserved int) DetectorOption {
+	return func(d *Detector) {
+		d.warmup = numObserved
+	}
+}
+
+// ----------------------------------------------------------------------------
+//  Constructor
+// ----------------------------------------------------------------------------
+
+// NewDetector returns a new Detector of the trained predictor with the given
+// options.
+func NewDetector(predictor *Predictor, opts ...DetectorOption) (*Detector, error) {
+	if predictor == nil {
+		return nil, errors.New("predictor must not be nil")
+	}
+
+	detector := &Detector{
+		predictor:   predictor,
+		sensitivity: DetectorSensitivityDefault,
+		minSurprise: DetectorMinSurpriseDefault,
+		adaptRate:   DetectorAdaptRateDefault,
+		warmup:      DetectorWarmupDefault,
+	}
+
+	for _, opt := range opts {
+		opt(detector)
+	}
+
+	if detector.adaptRate <= 0 || detector.adaptRate > 1 {
+		return nil, errors.Errorf("adapt rate must be greater than 0 and up to 1. Given: %v", detector.adaptRate)
+	}
+
+	if detector.sensitivity < 0 || math.IsNaN(detector.sensitivity) {
+		return nil, errors.Errorf("sensitivity must not be negative. Given: %v", detector.sensitivity)
+	}
+
+	return detector, nil
+}
+
+// ----------------------------------------------------------------------------
+//  Methods
+// ----------------------------------------------------------------------------
+
+// Detect starts a new sequence and returns the spans of the anomalous items of
+// the given items. The statistics of the threshold learned so far are kept, so
+// the detector adapts across the sequences.
+//
+// The items are validated before any observation, so on error the state of the
+// detector is left unchanged.
+func (d *Detector) Detect(items []any) ([]AnomalySpan, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.predictor.mu.RLock()
+	itemIDs, err := d.predictor.itemIDs(items)
+	d.predictor.mu.RUnlock()
+
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to detect the anomalies")
+	}
+
+	d.startSequence()
+
+	spans := []AnomalySpan{}
+
+	for i, itemID := range itemIDs {
+		observation, err := d.observe(itemID, items[i])
+		if err != nil {
+			return nil, err
+		}
+
+		if observation.Closed != nil {
+			spans = append(spans, *observation.Closed)
+		}
+	}
+
+	if span := d.flush(); span != nil {
+		spans = append(spans, *span)
+	}
+
+	return spans, nil
+}
+
+// Flush ends the current sequence and returns the span of the anomalous items
+// not closed yet. It returns nil if the last item is not anomalous. The next
+// item observed is the first item of a new sequence.
+func (d *Detector) Flush() *AnomalySpan {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	span := d.flush()
+
+	d.startSequence()
+
+	return span
+}
+
+// Observe scores the next item of the sequence, such as an event as it arrives,
+// and returns the result. Check Anomalous to alert on each item, or Closed to
+// get the spans of the anomalous items.
+func (d *Detector) Observe(item any) (Observation, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.predictor.mu.RLock()
+	itemID, err := d.predictor.itemID(d.predictor.quantize(item))
+	d.predictor.mu.RUnlock()
+
+	if err != nil {
+		return Observation{}, errors.Wrap(err, "failed to observe the item")
+	}
+
+	return d.observe(itemID, item)
+}
+
+// Reset clears the current sequence and the statistics of the threshold.
+func (d *Detector) Reset() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.startSequence()
+
+	d.mean = 0
+	d.variance = 0
+	d.numObserved = 0
+}
+
+// Threshold returns the current threshold of the surprise.
+func (d *Detector) Threshold() float64 {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	return d.threshold()
+}
+
+// ----------------------------------------------------------------------------
+//  Private methods
+// ----------------------------------------------------------------------------
+//  The private methods do not lock the detector. The caller must hold the lock.
+
+// adapt updates the exponentially weighted mean and variance of the surprises.
+func (d *Detector) adapt(surprise float64) {
+	if math.IsInf(surprise, 0) {
+		return
+	}
+
+	d.numObserved++
+
+	if d.numObserved == 1 {
+		d.mean = surprise
+
+		return
+	}
+
+	diff := surprise - d.mean
+	increment := d.adaptRate * diff
+
+	d.mean += increment
+	d.variance = (1 - d.adaptRate) * (d.variance + diff*increment)
+}
+
+// flush closes and returns the span being observed.
+func (d *Detector) flush() *AnomalySpan {
+	span := d.span
+	d.span = nil
+
+	return span
+}
+
+// observe scores the item of the given ID and updates the state.
+func (d *Detector) observe(itemID uint64, item any) (Observation, error) {
+	observation := Observation{
+		Item:      item,
+		Index:     d.index,
+		Threshold: d.threshold(),
+	}
+
+	d.predictor.mu.RLock()
+
+	if d.predictor.nodeLogger == nil {
+		d.predictor.mu.RUnlock()
+
+		return Observation{}, errors.New("predictor is not initialized")
+	}
+
+	maxOrder := d.predictor.maxOrder
+
+	if d.index == 0 && d.predictor.boundaries {
+		startID, _ := d.predictor.itemID(StartOfSequence)
+		d.contextIDs = append(d.contextIDs, startID)
+	}
+
+	var (
+		step StepScore
+		err  error
+	)
+
+	if len(d.contextIDs) > 0 {
+		step, err = d.predictor.scoreStep(d.contextIDs, itemID)
+	}
+
+	d.predictor.mu.RUnlock()
+
+	if err != nil {
+		return Observation{}, err
+	}
+
+	if len(d.contextIDs) > 0 {
+		observation.Probability = step.Probability
+		observation.Surprise = math.Max(0, -step.LogProbability)
+		observation.Anomalous = observation.Surprise > observation.Threshold
+
+		d.adapt(observation.Surprise)
+	} else {
+		observation.Probability = 1
+	}
+
+	switch {
+	case observation.Anomalous && d.span == nil:
+		d.span = &AnomalySpan{Start: d.index}
+
+		fallthrough
+	case observation.Anomalous:
+		d.span.Items = append(d.span.Items, item)
+		d.span.Surprises = append(d.span.Surprises, observation.Surprise)
+		d.span.End = d.index + 1
+		d.span.MaxSurprise = math.Max(d.span.MaxSurprise, observation.Surprise)
+	default:
+		observation.Closed = d.flush()
+	}
+
+	// Keep the context up to the maximum order.
+	if maxOrder <= 0 {
+		maxOrder = DetectorContextMax
+	}
+
+	d.contextIDs = append(d.contextIDs, itemID)
+	if len(d.contextIDs) > maxOrder {
+		d.contextIDs = append(d.contextIDs[:0], d.contextIDs[len(d.contextIDs)-maxOrder:]...)
+	}
+
+	d.index++
+
+	return observation, nil
+}
+
+// startSequence clears the context and the span of the current sequence.
+func (d *Detector) startSequence() {
+	d.contextIDs = nil
+	d.span = nil
+	d.index = 0
+}
+
+// threshold returns the current threshold of the surprise.
+func (d *Detector) threshold() float64 {
+	if d.numObserved < d.warmup || d.numObserved < 2 {
+		return d.minSurprise
+	}
+
+	return math.Max(d.minSurprise, d.mean+d.sensitivity*math.Sqrt(d.variance))
+}
@@ -0,0 +1,192 @@
+package bayes
+
+import (
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// apiCalls is the corpus of the usual API calls.
+var apiCalls = [][]any{
+	{"login", "list", "get", "logout"},
+	{"login", "list", "get", "get", "logout"},
+	{"login", "get", "logout"},
+	{"login", "list", "get", "logout"},
+}
+
+// ----------------------------------------------------------------------------
+//  NewDetector
+// ----------------------------------------------------------------------------
+
+func TestNewDetector_errors(t *testing.T) {
+	t.Parallel()
+
+	_, err := NewDetector(nil)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "predictor must not be nil")
+
+	predictor, err := NewPredictor()
+	require.NoError(t, err)
+
+	for _, opt := range []DetectorOption{WithDetectorAdaptRate(0), WithDetectorAdaptRate(1.1)} {
+		_, err = NewDetector(predictor, opt)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "adapt rate must be greater than 0 and up to 1")
+	}
+
+	_, err = NewDetector(predictor, WithDetectorSensitivity(-1))
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "sensitivity must not be negative")
+}
+
+// ----------------------------------------------------------------------------
+//  Methods
+// ----------------------------------------------------------------------------
+
+func TestDetector_Detect(t *testing.T) {
+	t.Parallel()
+
+	detector, err := NewDetector(newTrainedPredictor(t, apiCalls, WithMaxOrder(2)))
+	require.NoError(t, err)
+
+	spans, err := detector.Detect([]any{"login", "list", "delete_all", "get", "logout"})
+	require.NoError(t, err)
+	require.Len(t, spans, 1)
+
+	// "delete_all" never followed "list", and "get" never followed "delete_all"
+	span := spans[0]
+
+	assert.Equal(t, 2, span.Start)
+	assert.Equal(t, 4, span.End)
+	assert.Equal(t, []any{"delete_all", "get"}, span.Items)
+	assert.True(t, math.IsInf(span.MaxSurprise, 1))
+
+	// Usual sequence
+	spans, err = detector.Detect([]any{"login", "list", "get", "logout"})
+	require.NoError(t, err)
+	assert.Empty(t, spans)
+
+	// Span at the end of the sequence
+	spans, err = detector.Detect([]any{"login", "list", "get", "login"})
+	require.NoError(t, err)
+	require.Len(t, spans, 1)
+	assert.Equal(t, 3, spans[0].Start)
+	assert.Equal(t, 4, spans[0].End)
+
+	// Invalid item
+	_, err = detector.Detect([]any{"login", struct{}{}})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "failed to detect the anomalies")
+}
+
+func TestDetector_Observe(t *testing.T) {
+	t.Parallel()
+
+	detector, err := NewDetector(newTrainedPredictor(t, apiCalls, WithMaxOrder(2)))
+	require.NoError(t, err)
+
+	observation, err := detector.Observe("login")
+	require.NoError(t, err)
+	assert.False(t, observation.Anomalous, "first item has no context to be scored")
+	assert.Zero(t, observation.Surprise)
+
+	observation, err = detector.Observe("list")
+	require.NoError(t, err)
+	assert.False(t, observation.Anomalous)
+	assert.InDelta(t, -math.Log(observation.Probability), observation.Surprise, 1e-12)
+
+	observation, err = detector.Observe("delete_all")
+	require.NoError(t, err)
+	assert.True(t, observation.Anomalous, "unseen transition should be an anomaly")
+	assert.Equal(t, 2, observation.Index)
+	assert.Nil(t, observation.Closed)
+
+	observation, err = detector.Observe("get")
+	require.NoError(t, err)
+	assert.True(t, observation.Anomalous)
+
+	observation, err = detector.Observe("logout")
+	require.NoError(t, err)
+	assert.False(t, observation.Anomalous)
+	require.NotNil(t, observation.Closed, "span should be closed by the usual item")
+	assert.Equal(t, []any{"delete_all", "get"}, observation.Closed.Items)
+
+	// Flush the open span
+	_, err = detector.Observe("delete_all")
+	require.NoError(t, err)
+
+	span := detector.Flush()
+	require.NotNil(t, span)
+	assert.Equal(t, 5, span.Start)
+	assert.Nil(t, detector.Flush(), "flushed span should not be returned again")
+
+	// New sequence after Flush
+	observation, err = detector.Observe("get")
+	require.NoError(t, err)
+	assert.Equal(t, 0, observation.Index)
+	assert.False(t, observation.Anomalous)
+
+	_, err = detector.Observe(struct{}{})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "failed to observe the item")
+}
+
+func TestDetector_Threshold(t *testing.T) {
+	t.Parallel()
+
+	predictor, err := NewPredictor(WithMaxOrder(1))
+	require.NoError(t, err)
+
+	// "a" is followed by "b" 9 times and by "c" once
+	sequence := []any{}
+	for i := 0; i < 9; i++ {
+		sequence = append(sequence, "a", "b")
+	}
+
+	require.NoError(t, predictor.Train(append(append([]any{}, sequence...), "a", "c", "a", "b")))
+
+	const minSurprise = 0.001
+
+	detector, err := NewDetector(predictor,
+		WithDetectorMinSurprise(minSurprise),
+		WithDetectorWarmup(5),
+		WithDetectorSensitivity(2),
+	)
+	require.NoError(t, err)
+
+	require.InDelta(t, minSurprise, detector.Threshold(), 0, "threshold should be the minimum until the warmup")
+
+	_, err = detector.Detect(sequence)
+	require.NoError(t, err)
+
+	threshold := detector.Threshold()
+	assert.Greater(t, threshold, minSurprise, "threshold should adapt to the surprises of the stream")
+
+	// The rare transition exceeds the adapted threshold while the usual ones do not
+	spans, err := detector.Detect([]any{"a", "b", "a", "c", "a", "b"})
+	require.NoError(t, err)
+	require.Len(t, spans, 1)
+	assert.Equal(t, []any{"c"}, spans[0].Items)
+	assert.Greater(t, spans[0].MaxSurprise, threshold)
+
+	detector.Reset()
+
+	assert.InDelta(t, minSurprise, detector.Threshold(), 0, "statistics should be cleared")
+}
+
+func TestDetector_boundaries(t *testing.T) {
+	t.Parallel()
+
+	predictor := newTrainedPredictor(t, [][]any{{"login", "get"}, {"login", "list"}}, WithBoundaries())
+
+	detector, err := NewDetector(predictor)
+	require.NoError(t, err)
+
+	spans, err := detector.Detect([]any{"get", "login"})
+	require.NoError(t, err)
+	require.Len(t, spans, 1)
+	assert.Equal(t, 0, spans[0].Start, "first item should be scored from the start of the sequence")
+	assert.Equal(t, 2, spans[0].End)
+}
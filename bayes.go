@@ -2,10 +2,37 @@
 package bayes
 
 import (
+	"sync"
+
 	"github.com/KEINOS/go-bayes/pkg/nodelogger/logmem"
 	"github.com/pkg/errors"
 )
 
+var (
+	// _openers holds the openers of the storages registered via
+	// `RegisterStorage()`.
+	_openers = make(map[Storage]StorageOpener)
+	// _muOpeners protects _openers.
+	_muOpeners sync.RWMutex
+)
+
+// ----------------------------------------------------------------------------
+//  Type: Hashable
+// ----------------------------------------------------------------------------
+
+// Hashable is an item type which provides its own ID, such as a struct of an
+// event. It is checked before any other type, so the items of the type can be
+// trained and predicted as is.
+//
+// The items without BayesHash() but with MarshalBinary() (encoding.
+// BinaryMarshaler) or String() (fmt.Stringer), such as time.Time, are hashed by
+// the output of them in this order.
+type Hashable interface {
+	// BayesHash returns the ID of the item. The equal items must return the
+	// same ID.
+	BayesHash() uint64
+}
+
 // ----------------------------------------------------------------------------
 //  Type: NodeLogger
 // ----------------------------------------------------------------------------
@@ -34,6 +61,12 @@ type NodeLogger interface {
 	// Update updates the records of a node. It must be called by the next node
 	// accessed.
 	Update(fromA, toB uint64)
+	// UpdateN is the same as calling Update n times at once. The n may be
+	// fractional and must be ignored if it is not a positive finite number.
+	UpdateN(fromA, toB uint64, n float64)
+	// Decrement reverses an update made via Update. It must do nothing if there
+	// is no such update, so the records never go negative.
+	Decrement(fromA, toB uint64)
 }
 
 // ----------------------------------------------------------------------------
@@ -52,6 +85,40 @@ const (
 	SQLite3Storage
 )
 
+// StorageOpener opens the NodeLogger of a storage registered via
+// `RegisterStorage()`. The path is the location of the records, such as the
+// database file path set via `SetSQLite3Path()` or `WithSQLite3Path()`.
+type StorageOpener func(path string, scopeID uint64) (NodeLogger, error)
+
+// RegisterStorage makes the storage available to `New()` and `WithStorage()`.
+//
+// It is intended to be called from the init function of the package which
+// implements the storage, so the package itself is not linked unless imported.
+// For example, to use the SQLite3Storage:
+//
+//	import _ "github.com/KEINOS/go-bayes/pkg/nodelogger/logsqlite"
+//
+// It panics if the opener is nil, the storage is MemoryStorage or the storage
+// is already registered.
+func RegisterStorage(engine Storage, opener StorageOpener) {
+	_muOpeners.Lock()
+	defer _muOpeners.Unlock()
+
+	if opener == nil {
+		panic("bayes: RegisterStorage opener is nil")
+	}
+
+	if engine == MemoryStorage {
+		panic("bayes: RegisterStorage can not override the in-memory storage")
+	}
+
+	if _, ok := _openers[engine]; ok {
+		panic("bayes: RegisterStorage called twice for " + engine.Type() + " storage")
+	}
+
+	_openers[engine] = opener
+}
+
 // Type returns the type name of the storage.
 func (s Storage) Type() string {
 	switch s {
@@ -73,16 +140,50 @@ func (s Storage) Type() string {
 //
 // Use this function if you want to have more control over the NodeLogger
 // instance rather than using the convenient functions.
+//
+// For SQLite3Storage, the records are stored in the database file set via
+// `SetSQLite3Path()` (default: SQLite3PathDefault). The existing records of the
+// same scope ID in the file are kept. The storage must be registered by
+// importing the logsqlite package. See `RegisterStorage()`.
 func New(engine Storage, scopeID uint64) (NodeLogger, error) {
-	// Currently only MemoryStorage is supported. So disable switch statement
-	//
-	// switch engine {
-	// case MemoryStorage:
-	// 	return logmem.New(scopeID), nil
-	// }
-	if engine == MemoryStorage {
-		return logmem.New(scopeID), nil
+	return newNodeLogger(engine, scopeID, getSQLite3Path())
+}
+
+// newNodeLogger returns a new NodeLogger instance of the given storage. The
+// sqlite3Path is used only for the SQLite3Storage and memOpts only for the
+// MemoryStorage.
+func newNodeLogger(engine Storage, scopeID uint64, sqlite3Path string, memOpts ...logmem.Option) (NodeLogger, error) {
+	switch engine {
+	case MemoryStorage:
+		return logmem.New(scopeID, memOpts...), nil
+	case SQLite3Storage:
+		if len(memOpts) > 0 {
+			return nil, errors.New("the options of the in-memory storage are not supported by SQLite3 storage")
+		}
+
+		opener := getOpener(engine)
+		if opener == nil {
+			return nil, errors.New("SQLite3 storage is not registered. " +
+				"Import github.com/KEINOS/go-bayes/pkg/nodelogger/logsqlite to register it")
+		}
+
+		nodeLog, err := opener(sqlite3Path, scopeID)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to create SQLite3 storage")
+		}
+
+		return nodeLog, nil
+	case UnknwonStorage:
 	}
 
 	return nil, errors.New("unknown storage engine type")
 }
+
+// getOpener returns the opener of the storage registered via
+// `RegisterStorage()`. It returns nil if not registered.
+func getOpener(engine Storage) StorageOpener {
+	_muOpeners.RLock()
+	defer _muOpeners.RUnlock()
+
+	return _openers[engine]
+}
@@ -2,22 +2,23 @@ package bayes
 
 import (
 	"fmt"
+	"math"
 	"math/big"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
 
 // ----------------------------------------------------------------------------
-//  addClass
+//  Predictor.addClass
 // ----------------------------------------------------------------------------
 
-//nolint:paralleltest // disable parallel test due to global variable change
 func Test_addClass(t *testing.T) {
-	oldClasses := _classes
+	t.Parallel()
 
-	defer func() { _classes = oldClasses }()
+	predictor := &Predictor{classes: make(map[uint64]_Class)}
 
 	//nolint:varnamelen // tt is short but descriptive
 	for i, tt := range []struct {
@@ -42,10 +43,10 @@ func Test_addClass(t *testing.T) {
 		{big.NewInt(9223372036854775807)},
 	} {
 		require.NotPanics(t, func() {
-			addClass(uint64(i), tt.input) // #nosec
+			predictor.addClass(uint64(i), tt.input) // #nosec
 		})
 
-		c := GetClass(uint64(i)) // #nosec
+		c := predictor.GetClass(uint64(i)) // #nosec
 
 		require.Equal(t, tt.input, c)
 	}
@@ -60,8 +61,9 @@ func Test_convAnyToUint64_error_cases(t *testing.T) {
 
 	for _, tt := range []interface{}{
 		nil,
-		big.NewInt(9223372036854775807),
-		*big.NewInt(9223372036854775807),
+		*big.NewInt(9223372036854775807), // String() is of the pointer
+		struct{ Name string }{"foo"},
+		[]int{1, 2},
 	} {
 		v, err := convAnyToUint64(tt)
 
@@ -87,14 +89,29 @@ func Test_convAnyToUint64_golden(t *testing.T) {
 		{uint64(1), uint64(1)},
 		{uint32(1), uint64(1)},
 		{uint16(1), uint64(1)},
+		{uint8(1), uint64(1)},
 		{uint(1), uint64(1)},
 		{int64(1), uint64(1)},
 		{int32(1), uint64(1)},
 		{int16(1), uint64(1)},
+		{int8(-1), uint64(0xffffffffffffffff)},
 		{int(0xff), uint64(0xff)},
-		{float64(1.0), uint64(1)},
-		{float32(1.0), uint64(1)},
+		{int(1 << 40), uint64(1 << 40)},
+		{int(math.MinInt64), uint64(0x8000000000000000)},
+		{int(math.MaxInt64), uint64(0x7fffffffffffffff)},
+		{int64(math.MinInt64), uint64(0x8000000000000000)},
+		{float64(1.0), uint64(0x3ff0000000000000)},
+		{float32(1.0), uint64(0x3ff0000000000000)},
+		{float64(0.1), uint64(0x3fb999999999999a)},
+		{float64(0.5), uint64(0x3fe0000000000000)},
+		{float64(-0.5), uint64(0xbfe0000000000000)},
+		{math.Copysign(0, -1), uint64(0)},
+		{math.NaN(), uint64(0x7ff8000000000001)},
 		{"foobar", uint64(0xaa51dcd43d5c6c52)},
+		{[]byte("foobar"), uint64(0xaa51dcd43d5c6c52)},
+		{big.NewInt(12345), mustConv(t, "12345")}, // via fmt.Stringer
+		{hashableItem{id: 42}, uint64(42)},
+		{marshalerItem("foobar"), uint64(0xaa51dcd43d5c6c52)},
 		{true, uint64(1)},
 		{false, uint64(0)},
 	} {
@@ -105,6 +122,60 @@ func Test_convAnyToUint64_golden(t *testing.T) {
 	}
 }
 
+func Test_convAnyToUint64_signed_integers(t *testing.T) {
+	t.Parallel()
+
+	// All the signed integer types are the same ID for the same value
+	expect := mustConv(t, int64(-5))
+
+	for _, value := range []any{int(-5), int32(-5), int16(-5), int8(-5)} {
+		assert.Equal(t, expect, mustConv(t, value), "type: %T", value)
+	}
+
+	// Unix nanoseconds in int
+	nanosec := int(time.Date(2026, 1, 2, 3, 4, 5, 6, time.UTC).UnixNano())
+
+	assert.Equal(t, mustConv(t, int64(nanosec)), mustConv(t, nanosec))
+}
+
+func Test_convAnyToUint64_priority(t *testing.T) {
+	t.Parallel()
+
+	// Hashable is checked before the other types and fallbacks
+	classID, err := convAnyToUint64(hashableString("foo"))
+	require.NoError(t, err)
+	assert.Equal(t, uint64(7), classID)
+
+	// BinaryMarshaler is checked before fmt.Stringer
+	date := time.Date(2026, 1, 2, 3, 4, 5, 6, time.UTC)
+	marshaled, err := date.MarshalBinary()
+	require.NoError(t, err)
+
+	classID, err = convAnyToUint64(date)
+	require.NoError(t, err)
+	assert.Equal(t, mustConv(t, marshaled), classID)
+}
+
+func Test_convAnyToTypedUint64_custom_types(t *testing.T) {
+	t.Parallel()
+
+	// Same ID without the type
+	require.Equal(t, mustConv(t, hashableItem{id: 7}), mustConv(t, hashableString("foo")))
+
+	// Custom types are distinguished by their names
+	assert.NotEqual(t, mustConvTyped(t, hashableItem{id: 7}), mustConvTyped(t, hashableString("foo")))
+	assert.Equal(t, mustConvTyped(t, hashableItem{id: 7}), mustConvTyped(t, hashableItem{id: 7}))
+}
+
+func Test_convAnyToUint64_marshal_error(t *testing.T) {
+	t.Parallel()
+
+	_, err := convAnyToUint64(marshalerItem(""))
+
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "Failed to marshal bayes.marshalerItem")
+}
+
 // ----------------------------------------------------------------------------
 //  chopAndMergeBytes
 // ----------------------------------------------------------------------------
@@ -232,6 +303,54 @@ func TestNew_unknown_storage_type(t *testing.T) {
 	assert.Contains(t, err.Error(), "unknown storage engine type")
 }
 
+//nolint:paralleltest // disable parallel test due to global variable change
+func TestNew_sqlite3_storage_not_registered(t *testing.T) {
+	opener := getOpener(SQLite3Storage)
+
+	defer func() {
+		if opener != nil {
+			_openers[SQLite3Storage] = opener // Recover object
+		}
+	}()
+
+	delete(_openers, SQLite3Storage)
+
+	trainer, err := New(SQLite3Storage, 0)
+
+	require.Error(t, err, "it should be an error if the storage is not registered")
+	require.Nil(t, trainer, "it should be nil on error")
+
+	assert.Contains(t, err.Error(), "SQLite3 storage is not registered")
+}
+
+// ----------------------------------------------------------------------------
+//  RegisterStorage
+// ----------------------------------------------------------------------------
+
+func TestRegisterStorage_panics(t *testing.T) {
+	t.Parallel()
+
+	opener := func(path string, scopeID uint64) (NodeLogger, error) {
+		return nil, nil //nolint:nilnil // never called
+	}
+
+	// Storage of a custom type, not to conflict with the other tests
+	custom := Storage(100)
+
+	assert.PanicsWithValue(t, "bayes: RegisterStorage opener is nil", func() {
+		RegisterStorage(custom, nil)
+	})
+	assert.PanicsWithValue(t, "bayes: RegisterStorage can not override the in-memory storage", func() {
+		RegisterStorage(MemoryStorage, opener)
+	})
+
+	RegisterStorage(custom, opener)
+
+	assert.PanicsWithValue(t, "bayes: RegisterStorage called twice for unknown storage", func() {
+		RegisterStorage(custom, opener)
+	})
+}
+
 // ----------------------------------------------------------------------------
 //  Predict
 // ----------------------------------------------------------------------------
@@ -297,6 +416,46 @@ func TestReset_panic(t *testing.T) {
 	}, "it should panic if the storage is unknown")
 }
 
+// ----------------------------------------------------------------------------
+//  Score, Generate and MostLikelyContinuation
+// ----------------------------------------------------------------------------
+
+// They are thin wrappers of the methods of the default predictor, so only the
+// conversions of the items are tested here.
+//
+//nolint:paralleltest // disable parallel test due to global variable change
+func TestSequenceFunctions(t *testing.T) {
+	defer Reset()
+
+	require.NoError(t, Train([]int{1, 2, 3}))
+
+	score, err := Score([]int{1, 2})
+	require.NoError(t, err)
+	require.Len(t, score.Steps, 1)
+	assert.Equal(t, 2, score.Steps[0].Item)
+	assert.Zero(t, score.LogProbability)
+
+	generated, err := Generate([]int{1}, 5, GenerateOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, []int{2, 3}, generated)
+
+	continuations, err := MostLikelyContinuation([]int{1}, 3, 2)
+	require.NoError(t, err)
+	require.Len(t, continuations, 1)
+	assert.Equal(t, []int{2, 3}, continuations[0].Items)
+
+	// 3 is followed only by a string
+	require.NoError(t, Train([]any{3, "foo"}))
+
+	_, err = Generate([]int{3}, 1, GenerateOptions{})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "generated item at index 0 is of the unexpected type: string")
+
+	_, err = MostLikelyContinuation([]int{3}, 1, 1)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "item at index 0 of the continuation 0 is of the unexpected type: string")
+}
+
 // ----------------------------------------------------------------------------
 //  Train
 // ----------------------------------------------------------------------------
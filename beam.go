@@ -0,0 +1,247 @@
+package bayes
+
+import (
+	"math"
+	"sort"
+
+	"github.com/pkg/errors"
+)
+
+// ============================================================================
+//  Beam search of the continuations
+// ============================================================================
+//  Calling `Predict()` repeatedly takes the most probable item at each step,
+//  which may lead to an unlikely path as a whole. `MostLikelyContinuation()`
+//  keeps the beamWidth most probable paths at each step instead, and returns
+//  the paths of the highest probability as a whole.
+//
+//    step 1: [a] 0.6, [b] 0.4                  --> keeps both (beamWidth: 2)
+//    step 2: [a, x] 0.6*0.3, [b, y] 0.4*0.9    --> [b, y] wins over [a, x]
+//
+//  The probability of a path is the product of the probabilities of the steps
+//  as in `Score()`, summed in the log space.
+// ============================================================================
+
+// Continuation is a candidate of the continuation of the seed items.
+type Continuation struct {
+	// Items are the items following the seed.
+	Items []any
+	// ClassIDs are the IDs of the items.
+	ClassIDs []uint64
+	// LogProbability is the natural logarithm of the probability of the items
+	// following the seed.
+	LogProbability float64
+}
+
+// ----------------------------------------------------------------------------
+//  Public functions
+// ----------------------------------------------------------------------------
+
+// MostLikelyContinuation returns up to beamWidth continuations of the seed
+// items of the default predictor, in descending order of the probability. It
+// returns an error if an item of the continuations is not of T, such as with
+// the classes of the mixed types. See `Predictor.MostLikelyContinuation()` for
+// details.
+func MostLikelyContinuation[T any](seed []T, length, beamWidth int) ([]ModelContinuation[T], error) {
+	predictor := getPredictor()
+	if predictor == nil {
+		return nil, errors.New("predictor is not initialized")
+	}
+
+	continuations, err := predictor.MostLikelyContinuation(toAnySlice(seed), length, beamWidth)
+	if err != nil {
+		return nil, err
+	}
+
+	typed := make([]ModelContinuation[T], len(continuations))
+
+	for i, continuation := range continuations {
+		typed[i] = ModelContinuation[T]{
+			Items:          make([]T, len(continuation.Items)),
+			LogProbability: continuation.LogProbability,
+		}
+
+		for j, item := range continuation.Items {
+			value, ok := item.(T)
+			if !ok {
+				return nil, errors.Errorf(
+					"item at index %d of the continuation %d is of the unexpected type: %T", j, i, item,
+				)
+			}
+
+			typed[i].Items[j] = value
+		}
+	}
+
+	return typed, nil
+}
+
+// ----------------------------------------------------------------------------
+//  Methods
+// ----------------------------------------------------------------------------
+
+// MostLikelyContinuation returns up to beamWidth continuations of length items
+// following the seed items, in descending order of the probability, searched
+// by the beam search of beamWidth. The larger beamWidth, the more likely to
+// find the most probable continuation, at the cost of the number of the
+// predictions. The beamWidth of 1 is the same as calling `Predict()`
+// repeatedly.
+//
+// A continuation is shorter than length if no item follows it. If the
+// boundaries are enabled, the seed is the start of a sequence, and a
+// continuation also ends when EndOfSequence follows, which is not included in
+// the items but in the probability. See `WithBoundaries()`.
+//
+// It returns ErrUnknownContext if no item follows the seed.
+func (p *Predictor) MostLikelyContinuation(seed []any, length, beamWidth int) ([]Continuation, error) {
+	if length < 1 {
+		return nil, errors.Errorf("length must be 1 or more. Given: %d", length)
+	}
+
+	if beamWidth < 1 {
+		return nil, errors.Errorf("beam width must be 1 or more. Given: %d", beamWidth)
+	}
+
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	if p.nodeLogger == nil {
+		return nil, errors.New("predictor is not initialized")
+	}
+
+	seedIDs, err := p.itemIDs(seed)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to search the continuations")
+	}
+
+	endID := uint64(0)
+
+	if p.boundaries {
+		startID, _ := p.itemID(StartOfSequence)
+		endID, _ = p.itemID(EndOfSequence)
+
+		seedIDs = append([]uint64{startID}, seedIDs...)
+	}
+
+	beams := []_Beam{{}}
+
+	for step := 0; step < length; step++ {
+		next := make([]_Beam, 0, len(beams)*beamWidth)
+		extended := false
+
+		for _, beam := range beams {
+			if beam.ended {
+				next = append(next, beam)
+
+				continue
+			}
+
+			contextIDs := append(append([]uint64{}, seedIDs...), beam.ClassIDs...)
+
+			predictions, err := p.predictIDs(contextIDs)
+			if err != nil {
+				return nil, err
+			}
+
+			if len(predictions) == 0 {
+				beam.ended = true
+				next = append(next, beam)
+
+				continue
+			}
+
+			// Only the top beamWidth of each beam can be in the top beamWidth
+			// of all.
+			if len(predictions) > beamWidth {
+				predictions = predictions[:beamWidth]
+			}
+
+			for _, prediction := range predictions {
+				next = append(next, beam.extend(prediction, p.boundaries && prediction.ClassID == endID))
+			}
+
+			extended = true
+		}
+
+		if !extended {
+			break
+		}
+
+		sortBeams(next)
+
+		if len(next) > beamWidth {
+			next = next[:beamWidth]
+		}
+
+		beams = next
+	}
+
+	if len(beams) == 1 && len(beams[0].ClassIDs) == 0 && !beams[0].ended {
+		return nil, ErrUnknownContext
+	}
+
+	continuations := make([]Continuation, len(beams))
+
+	for i, beam := range beams {
+		continuations[i] = beam.Continuation
+	}
+
+	return continuations, nil
+}
+
+// ----------------------------------------------------------------------------
+//  Type: _Beam (private)
+// ----------------------------------------------------------------------------
+
+// _Beam is a path of the beam search.
+type _Beam struct {
+	Continuation
+
+	// ended is true if the path can not be extended anymore.
+	ended bool
+}
+
+// extend returns a copy of the beam followed by the prediction. If end is true,
+// the prediction ends the beam without being added to the items.
+func (b _Beam) extend(prediction Prediction, end bool) _Beam {
+	extended := _Beam{
+		Continuation: Continuation{
+			Items:          b.Items,
+			ClassIDs:       b.ClassIDs,
+			LogProbability: b.LogProbability + math.Log(prediction.Probability),
+		},
+		ended: end,
+	}
+
+	if !end {
+		// Copy not to share the underlying arrays between the beams.
+		extended.Items = append(append([]any{}, b.Items...), prediction.Raw)
+		extended.ClassIDs = append(append([]uint64{}, b.ClassIDs...), prediction.ClassID)
+	}
+
+	return extended
+}
+
+// ----------------------------------------------------------------------------
+//  Private functions
+// ----------------------------------------------------------------------------
+
+// sortBeams sorts the beams in descending order of the probability. On tie, the
+// beam of the smaller class IDs comes first to always get the same result.
+func sortBeams(beams []_Beam) {
+	sort.SliceStable(beams, func(i, j int) bool {
+		if beams[i].LogProbability != beams[j].LogProbability {
+			return beams[i].LogProbability > beams[j].LogProbability
+		}
+
+		idsI, idsJ := beams[i].ClassIDs, beams[j].ClassIDs
+
+		for k := 0; k < len(idsI) && k < len(idsJ); k++ {
+			if idsI[k] != idsJ[k] {
+				return idsI[k] < idsJ[k]
+			}
+		}
+
+		return len(idsI) < len(idsJ)
+	})
+}
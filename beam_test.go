@@ -0,0 +1,97 @@
+package bayes
+
+import (
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// plans is the corpus where "s" is followed by "a" more often than "b", but "a"
+// is followed by 3 items evenly while "b" is always followed by "w".
+var plans = [][]any{
+	{"s", "a", "x"}, {"s", "a", "y"}, {"s", "a", "z"}, {"s", "b", "w"}, {"s", "b", "w"},
+}
+
+// ----------------------------------------------------------------------------
+//  Predictor
+// ----------------------------------------------------------------------------
+
+func TestPredictor_MostLikelyContinuation(t *testing.T) {
+	t.Parallel()
+
+	predictor := newTrainedPredictor(t, plans, WithMaxOrder(1))
+
+	// Greedy: "a" is more probable than "b" but followed by 3 items evenly
+	greedy, err := predictor.MostLikelyContinuation([]any{"s"}, 2, 1)
+	require.NoError(t, err)
+	require.Len(t, greedy, 1)
+	assert.Equal(t, "a", greedy[0].Items[0])
+
+	continuations, err := predictor.MostLikelyContinuation([]any{"s"}, 2, 3)
+	require.NoError(t, err)
+	require.Len(t, continuations, 3)
+
+	assert.Equal(t, []any{"b", "w"}, continuations[0].Items, "beam search should find the most probable path")
+	assert.Greater(t, continuations[0].LogProbability, greedy[0].LogProbability)
+	assert.Equal(t, []uint64{mustConv(t, "b"), mustConv(t, "w")}, continuations[0].ClassIDs)
+
+	for i, continuation := range continuations {
+		// Same as the score of the whole path
+		score, err := predictor.Score(append([]any{"s"}, continuation.Items...))
+		require.NoError(t, err)
+		assert.InDelta(t, score.LogProbability, continuation.LogProbability, 1e-12, "continuation #%d", i)
+
+		if i > 0 {
+			assert.GreaterOrEqual(t, continuations[i-1].LogProbability, continuation.LogProbability,
+				"continuations should be in descending order")
+		}
+	}
+}
+
+func TestPredictor_MostLikelyContinuation_short(t *testing.T) {
+	t.Parallel()
+
+	predictor := newTrainedPredictor(t, plans, WithMaxOrder(1))
+
+	// Nothing follows "w"
+	continuations, err := predictor.MostLikelyContinuation([]any{"b"}, 5, 2)
+	require.NoError(t, err)
+	require.Len(t, continuations, 1)
+	assert.Equal(t, []any{"w"}, continuations[0].Items, "continuation should end if nothing follows")
+	assert.Zero(t, continuations[0].LogProbability)
+
+	_, err = predictor.MostLikelyContinuation([]any{"w"}, 5, 2)
+	require.ErrorIs(t, err, ErrUnknownContext)
+
+	// End of the sequence
+	bounded := newTrainedPredictor(t, [][]any{{"a", "b"}, {"a", "b", "c"}}, WithBoundaries())
+
+	continuations, err = bounded.MostLikelyContinuation([]any{"a"}, 5, 2)
+	require.NoError(t, err)
+	require.Len(t, continuations, 2)
+
+	for _, continuation := range continuations {
+		assert.NotContains(t, continuation.Items, EndOfSequence, "end should not be included in the items")
+		assert.False(t, math.IsInf(continuation.LogProbability, 0))
+	}
+}
+
+func TestPredictor_MostLikelyContinuation_errors(t *testing.T) {
+	t.Parallel()
+
+	predictor := newTrainedPredictor(t, plans, WithMaxOrder(1))
+
+	_, err := predictor.MostLikelyContinuation([]any{"s"}, 0, 1)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "length must be 1 or more")
+
+	_, err = predictor.MostLikelyContinuation([]any{"s"}, 1, 0)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "beam width must be 1 or more")
+
+	_, err = predictor.MostLikelyContinuation([]any{struct{}{}}, 1, 1)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "failed to search the continuations")
+}
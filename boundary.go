@@ -0,0 +1,78 @@
+package bayes
+
+// ============================================================================
+//  Boundaries of the sequences
+// ============================================================================
+//  By default, the first item of a sequence is only trained as a context and
+//  the end of a sequence is not trained at all. With `WithBoundaries()`, each
+//  sequence is trained between StartOfSequence and EndOfSequence as ordinary
+//  items. So the predictor can predict the first item from StartOfSequence and
+//  predict EndOfSequence as the next item, such as the end of a session.
+//
+//    Train([a, b]) trains [StartOfSequence, a, b, EndOfSequence]
+// ============================================================================
+
+// Boundary is the marker of the start or the end of a sequence. See
+// `WithBoundaries()`.
+type Boundary uint8
+
+const (
+	// StartOfSequence is the marker trained before the first item of each
+	// sequence. Give it as the context to predict the first item. e.g.
+	//
+	//	classID, err := predictor.Predict([]any{bayes.StartOfSequence})
+	StartOfSequence Boundary = iota + 1
+	// EndOfSequence is the marker trained after the last item of each sequence.
+	// `GetClass()` returns it if the end of the sequence is predicted. e.g.
+	//
+	//	if predictor.GetClass(classID) == bayes.EndOfSequence { ... }
+	EndOfSequence
+)
+
+// BayesHash implements the Hashable interface. The IDs of the markers are
+// derived from their names, so they never collide with the ordinary items
+// except for the strings of the same names.
+func (b Boundary) BayesHash() uint64 {
+	return bytesToUint64([]byte("go-bayes/" + b.String()))
+}
+
+// String returns the name of the marker.
+func (b Boundary) String() string {
+	switch b {
+	case StartOfSequence:
+		return "StartOfSequence"
+	case EndOfSequence:
+		return "EndOfSequence"
+	}
+
+	return "Boundary(unknown)"
+}
+
+// ----------------------------------------------------------------------------
+//  Private methods
+// ----------------------------------------------------------------------------
+
+// bound returns the items and their IDs between the markers of the start and
+// the end of the sequence if the boundaries are enabled. Otherwise, or if the
+// sequence is empty, it returns them as is.
+func (p *Predictor) bound(itemIDs []uint64, items []any) ([]uint64, []any) {
+	if !p.boundaries || len(items) == 0 {
+		return itemIDs, items
+	}
+
+	// The markers are Hashable, so they never fail to convert.
+	startID, _ := p.itemID(StartOfSequence)
+	endID, _ := p.itemID(EndOfSequence)
+
+	boundIDs := make([]uint64, 0, len(itemIDs)+2)
+	boundIDs = append(boundIDs, startID)
+	boundIDs = append(boundIDs, itemIDs...)
+	boundIDs = append(boundIDs, endID)
+
+	boundItems := make([]any, 0, len(items)+2)
+	boundItems = append(boundItems, StartOfSequence)
+	boundItems = append(boundItems, items...)
+	boundItems = append(boundItems, EndOfSequence)
+
+	return boundIDs, boundItems
+}
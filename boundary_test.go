@@ -0,0 +1,214 @@
+package bayes
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// ----------------------------------------------------------------------------
+//  Boundary
+// ----------------------------------------------------------------------------
+
+func TestBoundary_String(t *testing.T) {
+	t.Parallel()
+
+	assert.Equal(t, "StartOfSequence", StartOfSequence.String())
+	assert.Equal(t, "EndOfSequence", EndOfSequence.String())
+	assert.Equal(t, "Boundary(unknown)", Boundary(0).String())
+
+	assert.NotEqual(t, StartOfSequence.BayesHash(), EndOfSequence.BayesHash())
+	assert.NotEqual(t, mustConv(t, "EndOfSequence"), mustConv(t, EndOfSequence),
+		"marker should not collide with the string of its name")
+}
+
+// ----------------------------------------------------------------------------
+//  Predictor
+// ----------------------------------------------------------------------------
+
+func TestPredictor_boundaries(t *testing.T) {
+	t.Parallel()
+
+	predictor, err := NewPredictor(WithBoundaries())
+	require.NoError(t, err)
+
+	require.NoError(t, predictor.TrainCorpus([][]any{
+		{"login", "view", "logout"},
+		{"login", "view", "buy", "logout"},
+		{}, // empty sequences are not trained
+	}))
+
+	// First item
+	classID, err := predictor.Predict([]any{StartOfSequence})
+	require.NoError(t, err)
+	assert.Equal(t, "login", predictor.GetClass(classID), "first item should be predictable")
+
+	classID, err = predictor.Predict(nil)
+	require.NoError(t, err)
+	assert.Equal(t, "login", predictor.GetClass(classID), "empty context should be the start")
+
+	// End of the sequence
+	classID, err = predictor.Predict([]any{"view", "logout"})
+	require.NoError(t, err)
+	assert.Equal(t, EndOfSequence, predictor.GetClass(classID))
+
+	predictions, err := predictor.PredictTopK([]any{"logout"}, 0)
+	require.NoError(t, err)
+	require.Len(t, predictions, 1)
+	assert.Equal(t, EndOfSequence, predictions[0].Raw)
+
+	// Untrain with the markers as well
+	require.NoError(t, predictor.Untrain([]any{"login", "view", "logout"}))
+	require.NoError(t, predictor.Untrain([]any{"login", "view", "buy", "logout"}))
+
+	_, err = predictor.Predict([]any{StartOfSequence})
+	require.ErrorIs(t, err, ErrUnknownContext)
+	assert.Nil(t, predictor.GetClass(EndOfSequence.BayesHash()), "marker should be removed")
+}
+
+func TestPredictor_boundaries_stream(t *testing.T) {
+	t.Parallel()
+
+	predictor, err := NewPredictor(WithBoundaries())
+	require.NoError(t, err)
+
+	items := make(chan any, 3)
+	items <- "foo"
+	items <- "bar"
+
+	close(items)
+
+	require.NoError(t, predictor.TrainChan(context.Background(), items))
+
+	classID, err := predictor.Predict([]any{StartOfSequence})
+	require.NoError(t, err)
+	assert.Equal(t, "foo", predictor.GetClass(classID))
+
+	classID, err = predictor.Predict([]any{"bar"})
+	require.NoError(t, err)
+	assert.Equal(t, EndOfSequence, predictor.GetClass(classID))
+
+	// Empty stream trains nothing
+	empty := make(chan any)
+	close(empty)
+
+	other, err := NewPredictor(WithBoundaries())
+	require.NoError(t, err)
+	require.NoError(t, other.TrainChan(context.Background(), empty))
+
+	_, err = other.Predict([]any{StartOfSequence})
+	require.ErrorIs(t, err, ErrUnknownContext)
+}
+
+func TestPredictor_Load_boundaries(t *testing.T) {
+	t.Parallel()
+
+	saved := mustSaveTrained(t, func(p *Predictor) error {
+		return p.Train([]any{"foo", "bar"})
+	}, WithBoundaries())
+
+	predictor, err := NewPredictor()
+	require.NoError(t, err)
+	require.NoError(t, predictor.Load(bytes.NewReader(saved)))
+
+	require.True(t, predictor.boundaries, "boundaries should be restored")
+
+	classID, err := predictor.Predict([]any{"bar"})
+	require.NoError(t, err)
+	assert.Equal(t, EndOfSequence, predictor.GetClass(classID), "marker should be loaded as the class")
+}
+
+func TestPredictor_TrainCorpus(t *testing.T) {
+	t.Parallel()
+
+	predictor, err := NewPredictor()
+	require.NoError(t, err)
+
+	require.NoError(t, predictor.TrainCorpus([][]any{{"a", "b"}, {"c", "d"}}))
+
+	_, err = predictor.Predict([]any{"b"})
+	require.ErrorIs(t, err, ErrUnknownContext, "no transition should be trained across the sequences")
+
+	classID, err := predictor.Predict([]any{"c"})
+	require.NoError(t, err)
+	assert.Equal(t, "d", predictor.GetClass(classID))
+
+	// Invalid item leaves the model unchanged
+	err = predictor.TrainCorpus([][]any{{"b", "x"}, {"y", struct{}{}}})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "Invalid sequence at index 1")
+
+	_, err = predictor.Predict([]any{"b"})
+	require.ErrorIs(t, err, ErrUnknownContext, "the valid sequence should not be trained either")
+}
+
+//nolint:paralleltest // disable parallel test due to global variable change
+func TestSetBoundaries(t *testing.T) {
+	defer func() {
+		SetBoundaries(false)
+		Reset()
+	}()
+
+	SetBoundaries(true)
+	Reset()
+
+	require.NoError(t, TrainCorpus([][]int{{1, 2}, {1, 3, 2}}))
+
+	classID, err := Predict([]int{2})
+	require.NoError(t, err)
+	assert.Equal(t, EndOfSequence, GetClass(classID))
+}
+
+// ----------------------------------------------------------------------------
+//  Model
+// ----------------------------------------------------------------------------
+
+func TestModel_boundaries(t *testing.T) {
+	t.Parallel()
+
+	model, err := NewModel[string](WithBoundaries())
+	require.NoError(t, err)
+
+	require.NoError(t, model.TrainCorpus([][]string{{"a", "b"}, {"a", "c"}, {"a", "b"}}))
+
+	next, err := model.Predict([]string{"a"})
+	require.NoError(t, err)
+	assert.Equal(t, "b", next)
+
+	// First item from the empty context
+	first, err := model.Predict(nil)
+	require.NoError(t, err)
+	assert.Equal(t, "a", first)
+
+	firsts, err := model.PredictTopK([]string{}, 0)
+	require.NoError(t, err)
+	require.Len(t, firsts, 1)
+	assert.Equal(t, "a", firsts[0].Class)
+
+	_, err = model.Predict([]string{"c"})
+	require.ErrorIs(t, err, ErrEndOfSequence)
+
+	predictions, err := model.PredictTopK([]string{"b"}, 0)
+	require.NoError(t, err)
+	require.Len(t, predictions, 1)
+	assert.True(t, predictions[0].EndOfSequence)
+	assert.Empty(t, predictions[0].Class)
+
+	// Collisions across the sequences
+	colliding, err := NewModel[hashableItemNamed]()
+	require.NoError(t, err)
+
+	err = colliding.TrainCorpus([][]hashableItemNamed{{{1, "foo"}}, {{1, "bar"}}})
+	require.ErrorIs(t, err, ErrHashCollision)
+}
+
+// hashableItemNamed is an item type whose ID ignores the name.
+type hashableItemNamed struct {
+	id   uint64
+	name string
+}
+
+func (h hashableItemNamed) BayesHash() uint64 { return h.id }
@@ -1,10 +1,15 @@
 package bayes
 
 import (
+	"encoding"
 	"encoding/binary"
+	"fmt"
 	"hash/crc32"
+	"math"
+	"sync"
 	"unsafe"
 
+	"github.com/KEINOS/go-bayes/pkg/nodelogger/logmem"
 	"github.com/pkg/errors"
 	"github.com/zeebo/blake3"
 )
@@ -12,13 +17,17 @@ import (
 // ============================================================================
 //  Shorthand functions.
 // ============================================================================
-//  This file contains functions for easy-to-use purposes.
+//  This file contains functions for easy-to-use purposes. They share a single
+//  default Predictor in the package. Use `NewPredictor()` to have independent
+//  models in the same process.
 //
 //  Convenient Functions:
 //    - SetStorage() - Sets the storage used by the predictor.
-//    - Reset() - Resets the trained data of the predictor.
+//    - SetSQLite3Path() - Sets the database file path of SQLite3 storage.
+//    - Reset() - Re-creates the predictor with the current settings.
 //    - Train() - Trains the predictor with the given items.
 //    - Predict() - Predicts the next item from the given items.
+//    - PredictTopK() - Predicts the k most probable next items with probability.
 //    - HashTrans() - Returns a unique hash from the input items.
 //    - GetClass() - Returns the original item value of the given class ID.
 // ============================================================================
@@ -30,35 +39,44 @@ const (
 	// ScopeIDDefault is the default scope ID on creating an instance of the
 	// predictor.
 	ScopeIDDefault = uint64(0)
+	// SQLite3PathDefault is the default database file path used by the
+	// SQLite3Storage.
+	SQLite3PathDefault = "go-bayes.sqlite3"
 )
 
 var (
-	_predictor NodeLogger
-	_classes   map[uint64]_Class
-	_storage   = StorageDefault
+	// _predictor is the default predictor used by the convenient functions.
+	_predictor      *Predictor
+	_storage        = StorageDefault
+	_sqlite3Path    = SQLite3PathDefault
+	_provenance     = false
+	_typedHashing   = false
+	_quantizer      Quantizer
+	_boundaries     = false
+	_maxOrder       = 0
+	_minProbability = float64(0)
+	_smoother       logmem.Smoother
+	// _mu protects the variables above. The default predictor itself is safe
+	// for concurrent use.
+	_mu sync.RWMutex
 )
 
 func init() {
 	Reset()
 }
 
-// ----------------------------------------------------------------------------
-//  Type: _Class (private)
-// ----------------------------------------------------------------------------
-
-// _Class holds the class ID and the original value.
-type _Class struct {
-	Raw any
-	ID  uint64
-}
-
 // ----------------------------------------------------------------------------
 //  Public functions
 // ----------------------------------------------------------------------------
 
 // GetClass returns the original value of the given class ID.
 func GetClass(classID uint64) any {
-	return _classes[classID].Raw
+	predictor := getPredictor()
+	if predictor == nil {
+		return nil
+	}
+
+	return predictor.GetClass(classID)
 }
 
 // HashTrans returns a unique hash from the input transitions. Note that the hash
@@ -80,47 +98,174 @@ func HashTrans[T any](transitions ...T) (uint64, error) {
 //
 // To get the original value of the class, use `GetClass()`.
 //
+// If the whole items have never appeared in the training set, it backs off to
+// the shorter context by dropping the oldest items one by one. It returns
+// ErrUnknownContext if even the last item has never been followed by any class
+// in the training set, since the class ID 0 is also a valid class.
+//
 //nolint:nonamedreturns // named return is used for readability.
 func Predict[T any](items []T) (classID uint64, err error) {
-	if _predictor == nil {
+	predictor := getPredictor()
+	if predictor == nil {
 		return 0, errors.New("predictor is not initialized")
 	}
 
-	biggest := struct {
-		Probability float64
-		Class       uint64
-	}{
-		Probability: 0,
-		Class:       0,
+	return predictor.Predict(toAnySlice(items))
+}
+
+// PredictTopK returns the k most probable next classes inferred from the given
+// items, in descending order of the probability. If k is zero or negative, all
+// the candidates are returned.
+//
+// The probabilities are normalized across the candidates. See
+// `Predictor.PredictTopK()` for details.
+func PredictTopK[T any](items []T, k int) ([]Prediction, error) {
+	predictor := getPredictor()
+	if predictor == nil {
+		return nil, errors.New("predictor is not initialized")
 	}
 
-	flowID, err := HashTrans(items...)
-	if err != nil {
-		return 0, errors.Wrap(err, "failed to hash the flow")
+	return predictor.PredictTopK(toAnySlice(items), k)
+}
+
+// Reset re-creates the default predictor with the current settings, such as
+// the ones set via `SetStorage()`.
+//
+// For MemoryStorage, this discards the trained data.
+//
+// IMPORTANT: For SQLite3Storage, the trained data is NOT deleted. The records
+// and the classes in the database file are kept and reopened, so the predictor
+// continues from them. To start over, use another database file via
+// `SetSQLite3Path()`.
+//
+// If the previous predictor holds resources such as a database connection, they
+// are released.
+func Reset() {
+	_mu.Lock()
+	defer _mu.Unlock()
+
+	opts := []Option{
+		WithStorage(_storage),
+		WithScopeID(ScopeIDDefault),
+		WithSQLite3Path(_sqlite3Path),
 	}
 
-	for classID := range _classes {
-		probability := _predictor.Predict(flowID, classID)
+	if _provenance {
+		opts = append(opts, WithProvenance())
+	}
 
-		if biggest.Probability < probability {
-			biggest.Probability = probability
-			biggest.Class = classID
-		}
+	if _typedHashing {
+		opts = append(opts, WithTypedHashing())
 	}
 
-	return biggest.Class, nil
-}
+	if _quantizer != nil {
+		opts = append(opts, WithQuantizer(_quantizer))
+	}
 
-// Reset resets the train object.
-func Reset() {
-	var err error
+	if _boundaries {
+		opts = append(opts, WithBoundaries())
+	}
 
-	_predictor, err = New(_storage, ScopeIDDefault)
+	if _maxOrder > 0 {
+		opts = append(opts, WithMaxOrder(_maxOrder))
+	}
+
+	if _minProbability > 0 {
+		opts = append(opts, WithMinProbability(_minProbability))
+	}
+
+	if _smoother != nil {
+		opts = append(opts, WithSmoothing(_smoother))
+	}
+
+	predictor, err := NewPredictor(opts...)
 	if err != nil {
 		panic(err)
 	}
 
-	_classes = make(map[uint64]_Class)
+	if _predictor != nil {
+		_ = _predictor.Close()
+	}
+
+	_predictor = predictor
+}
+
+// SetBoundaries enables or disables the boundaries of the sequences of the
+// predictor. See `WithBoundaries()`. Default: false.
+//
+// Do not forget to `Reset()` the predictor after changing it.
+func SetBoundaries(enabled bool) {
+	_mu.Lock()
+	defer _mu.Unlock()
+
+	_boundaries = enabled
+}
+
+// SetMaxOrder sets the maximum number of the items of the context to train the
+// predictor with. See `WithMaxOrder()`. Default: 0 (unlimited).
+//
+// Do not forget to `Reset()` the predictor after changing it.
+func SetMaxOrder(order int) {
+	_mu.Lock()
+	defer _mu.Unlock()
+
+	_maxOrder = order
+}
+
+// SetMinProbability sets the minimum probability of the prediction of the
+// predictor, below which `Predict()` abstains. See `WithMinProbability()`.
+// Default: 0 (never abstain).
+//
+// Do not forget to `Reset()` the predictor after changing it.
+func SetMinProbability(threshold float64) {
+	_mu.Lock()
+	defer _mu.Unlock()
+
+	_minProbability = threshold
+}
+
+// SetProvenance enables or disables the provenance index of the predictor. See
+// `WithProvenance()`. Default: false.
+//
+// Do not forget to `Reset()` the predictor after changing it.
+func SetProvenance(enabled bool) {
+	_mu.Lock()
+	defer _mu.Unlock()
+
+	_provenance = enabled
+}
+
+// SetQuantizer sets the quantizer of the numeric items of the predictor. See
+// `WithQuantizer()`. Default: nil.
+//
+// Do not forget to `Reset()` the predictor after changing it.
+func SetQuantizer(quantizer Quantizer) {
+	_mu.Lock()
+	defer _mu.Unlock()
+
+	_quantizer = quantizer
+}
+
+// SetSQLite3Path sets the database file path used by the SQLite3Storage. This
+// also affects the predictors created via `New()` afterwards.
+//
+// Do not forget to `Reset()` the predictor after changing the path.
+func SetSQLite3Path(path string) {
+	_mu.Lock()
+	defer _mu.Unlock()
+
+	_sqlite3Path = path
+}
+
+// SetSmoothing sets the smoothing strategy of the predictor. See
+// `WithSmoothing()`. Default: nil (no smoothing).
+//
+// Do not forget to `Reset()` the predictor after changing it.
+func SetSmoothing(smoother logmem.Smoother) {
+	_mu.Lock()
+	defer _mu.Unlock()
+
+	_smoother = smoother
 }
 
 // SetStorage sets the storage used by the predictor. This won't affect the
@@ -128,9 +273,35 @@ func Reset() {
 //
 // Do not forget to `Reset()` the predictor after changing the storage.
 func SetStorage(storage Storage) {
+	_mu.Lock()
+	defer _mu.Unlock()
+
 	_storage = storage
 }
 
+// SetTypedHashing enables or disables the type-tagged hashing of the items of
+// the predictor. See `WithTypedHashing()`. Default: false.
+//
+// Do not forget to `Reset()` the predictor after changing it.
+func SetTypedHashing(enabled bool) {
+	_mu.Lock()
+	defer _mu.Unlock()
+
+	_typedHashing = enabled
+}
+
+// Sources returns the sources which contributed to the transition from the
+// context to the class of the default predictor. The provenance must be enabled
+// via `SetProvenance()`. See `Predictor.Sources()` for details.
+func Sources[T any](context []T, class T) ([]string, error) {
+	predictor := getPredictor()
+	if predictor == nil {
+		return nil, errors.New("predictor is not initialized")
+	}
+
+	return predictor.Sources(toAnySlice(context), class)
+}
+
 // Train trains the predictor with the given items.
 //
 // Notes:
@@ -138,94 +309,78 @@ func SetStorage(storage Storage) {
 //     distribution based on the input items.
 //   - Once the item appears in the training set, the item is added to the class
 //     list.
+//   - The items are validated before any update, so on error the model is left
+//     unchanged.
 func Train[T any](items []T) error {
-	if _predictor == nil {
-		Reset()
+	return withPredictor(func(predictor *Predictor) error {
+		return predictor.Train(toAnySlice(items))
+	})
+}
+
+// TrainCorpus trains the default predictor with each sequence of the corpus as
+// a separate sequence. See `Predictor.TrainCorpus()` for details.
+func TrainCorpus[T any](corpus [][]T) error {
+	converted := make([][]any, len(corpus))
+
+	for i, items := range corpus {
+		converted[i] = toAnySlice(items)
 	}
 
-	prevItem := uint64(0)
-	drill := []uint64{}
+	return withPredictor(func(predictor *Predictor) error {
+		return predictor.TrainCorpus(converted)
+	})
+}
 
-	for index, itemRaw := range items {
-		item, err := convAnyToUint64(itemRaw)
-		if err != nil {
-			return errors.Wrap(err, "failed during training iteration")
-		}
+// TrainFrom trains the default predictor with the given items and records the
+// source of them, such as the document ID. The provenance must be enabled via
+// `SetProvenance()`. See `Predictor.TrainFrom()` for details.
+func TrainFrom[T any](source string, items []T) error {
+	return withPredictor(func(predictor *Predictor) error {
+		return predictor.TrainFrom(source, toAnySlice(items))
+	})
+}
 
-		if index == 0 {
-			prevItem = item
-			drill = append(drill, item)
+// TrainWeighted trains the default predictor with the given items as if they
+// were trained weight times. The weight may be fractional. See
+// `Predictor.TrainWeighted()` for details.
+func TrainWeighted[T any](items []T, weight float64) error {
+	return withPredictor(func(predictor *Predictor) error {
+		return predictor.TrainWeighted(toAnySlice(items), weight)
+	})
+}
 
-			continue
-		}
+// Untrain reverses the updates made by `Train()` with the same items of the
+// default predictor. The classes which no longer appear in the records are
+// removed. See `Predictor.Untrain()` for details.
+func Untrain[T any](items []T) error {
+	predictor := getPredictor()
+	if predictor == nil {
+		return errors.New("predictor is not initialized")
+	}
 
-		// 101 training. Trains only the predecessor and the successor item.
-		// e.g.
-		//   previous items --> [1, 2, 3, 4, 5]
-		//   following item --> 6
-		//   will train:
-		//               [5] --> 6
-		_predictor.Update(prevItem, item)
-
-		// Drill.
-		// Trains by repeating the flow of the previous items.
-		// e.g.
-		//   previous items --> [1, 2, 3, 4, 5]
-		//   following item --> 6
-		//   will train:
-		//               [5] --> 6
-		//            [4, 5] --> 6
-		//         [3, 4, 5] --> 6
-		//      [2, 3, 4, 5] --> 6
-		//   [1, 2, 3, 4, 5] --> 6
-		for i := 0; i < len(drill); i++ {
-			flowID, _ := HashTrans(drill[i:]...)
-
-			_predictor.Update(flowID, item)
-		}
+	return predictor.Untrain(toAnySlice(items))
+}
 
-		prevItem = item
-		drill = append(drill, item)
-		addClass(item, itemRaw)
+// UntrainFrom reverses the updates made by `TrainFrom()` with the same source
+// and items of the default predictor. See `Predictor.UntrainFrom()` for details.
+func UntrainFrom[T any](source string, items []T) error {
+	predictor := getPredictor()
+	if predictor == nil {
+		return errors.New("predictor is not initialized")
 	}
 
-	return nil
+	return predictor.UntrainFrom(source, toAnySlice(items))
 }
 
 // ----------------------------------------------------------------------------
 //  Private functions
 // ----------------------------------------------------------------------------
 
-//nolint:varnamelen,cyclop
-func addClass(class uint64, raw any) {
-	switch v := raw.(type) {
-	case uint64:
-		_classes[class] = _Class{ID: class, Raw: v}
-	case uint32:
-		_classes[class] = _Class{ID: class, Raw: v}
-	case uint16:
-		_classes[class] = _Class{ID: class, Raw: v}
-	case uint:
-		_classes[class] = _Class{ID: class, Raw: v}
-	case int64:
-		_classes[class] = _Class{ID: class, Raw: v}
-	case int32:
-		_classes[class] = _Class{ID: class, Raw: v}
-	case int16:
-		_classes[class] = _Class{ID: class, Raw: v}
-	case int:
-		_classes[class] = _Class{ID: class, Raw: v}
-	case float64:
-		_classes[class] = _Class{ID: class, Raw: v}
-	case float32:
-		_classes[class] = _Class{ID: class, Raw: v}
-	case string:
-		_classes[class] = _Class{ID: class, Raw: v}
-	case bool:
-		_classes[class] = _Class{ID: class, Raw: v}
-	default:
-		_classes[class] = _Class{ID: class, Raw: raw}
-	}
+// bytesToUint64 returns the hash of the bytes as uint64.
+func bytesToUint64(input []byte) uint64 {
+	h := blake3.Sum512(input)
+
+	return binary.BigEndian.Uint64(h[:])
 }
 
 // chopAndMergeBytes combines the two input as one in 8 byte length.
@@ -249,15 +404,11 @@ func chopAndMergeBytes(a, b []byte) (uint64, error) {
 	return binary.BigEndian.Uint64(rawid), nil
 }
 
-//nolint:varnamelen,cyclop
+//nolint:varnamelen,cyclop,funlen
 func convAnyToUint64(i interface{}) (uint64, error) {
-	// Magic numbers
-	const (
-		// maxInt32 is the max positive value of int32.
-		maxInt32 = int(2147483647)
-		// minInt32 is the min negative value of int32.
-		minInt32 = int(-2147483648)
-	)
+	if hashable, ok := i.(Hashable); ok {
+		return hashable.BayesHash(), nil
+	}
 
 	switch v := i.(type) {
 	case uint64:
@@ -266,6 +417,8 @@ func convAnyToUint64(i interface{}) (uint64, error) {
 		return uint64(v), nil
 	case uint16:
 		return uint64(v), nil
+	case uint8:
+		return uint64(v), nil
 	case uint:
 		return uint64(v), nil
 	case int64:
@@ -286,24 +439,26 @@ func convAnyToUint64(i interface{}) (uint64, error) {
 		// two's complement architectures. This is intended for discrete ID
 		// generation and we explicitly mark it for security scanner as intended.
 		return uint64(v), nil // #nosec
+	case int8:
+		// Intentional: convert signed integer to unsigned preserving bit
+		// representation. Negative values will map to large uint64 values on
+		// two's complement architectures. This is intended for discrete ID
+		// generation and we explicitly mark it for security scanner as intended.
+		return uint64(v), nil // #nosec
 	case int:
-		if v >= minInt32 && v <= maxInt32 {
-			// Intentional: convert signed int to unsigned preserving 2's
-			// complement representation across the full 64-bit width. Negative
-			// values will map to large uint64 values. This is intended for
-			// discrete ID generation. Mark as intended for security scanner.
-			return uint64(v), nil // #nosec
-		}
-
-		return 0, errors.New("failed to convert to uint64. int out of range")
+		// Intentional: convert signed int to unsigned preserving 2's
+		// complement representation across the full 64-bit width. Negative
+		// values will map to large uint64 values. This is intended for
+		// discrete ID generation. Mark as intended for security scanner.
+		return uint64(v), nil // #nosec
 	case float64:
-		return uint64(v), nil
+		return floatToUint64(v), nil
 	case float32:
-		return uint64(v), nil
+		return floatToUint64(float64(v)), nil
 	case string:
-		h := blake3.Sum512([]byte(v))
-
-		return binary.BigEndian.Uint64(h[:]), nil
+		return bytesToUint64([]byte(v)), nil
+	case []byte:
+		return bytesToUint64(v), nil
 	case bool:
 		if v {
 			return uint64(1), nil
@@ -312,9 +467,70 @@ func convAnyToUint64(i interface{}) (uint64, error) {
 		return uint64(0), nil
 	}
 
+	// Fallbacks for the other types
+	switch v := i.(type) {
+	case encoding.BinaryMarshaler:
+		marshaled, err := v.MarshalBinary()
+		if err != nil {
+			return 0, errors.Wrapf(err, "failed to convert to uint64. Failed to marshal %T", i)
+		}
+
+		return bytesToUint64(marshaled), nil
+	case fmt.Stringer:
+		return bytesToUint64([]byte(v.String())), nil
+	}
+
 	return 0, errors.Errorf("failed to convert to uint64. Unsupported type: %T", i)
 }
 
+// convAnyToTypedUint64 is the same as convAnyToUint64 but mixes the type tag of
+// the value into the result, so the values of different types never collide.
+func convAnyToTypedUint64(i any) (uint64, error) {
+	converted, err := convAnyToUint64(i)
+	if err != nil {
+		return 0, err
+	}
+
+	typeID := uint64(classTagOf(i))
+	if typeID == 0 {
+		// The types without the tag are distinguished by their names.
+		typeID = bytesToUint64([]byte(fmt.Sprintf("%T", i)))
+	}
+
+	return HashTrans(typeID, converted)
+}
+
+// floatToUint64 returns the exact bits of the float, so that every distinct
+// value is a distinct ID. The float32 values are converted to float64 before,
+// so the same value of both types is the same ID. Negative zero is the same as
+// zero and all the NaNs are the same ID.
+func floatToUint64(value float64) uint64 {
+	switch {
+	case value == 0:
+		return 0
+	case math.IsNaN(value):
+		return math.Float64bits(math.NaN())
+	}
+
+	return math.Float64bits(value)
+}
+
+// getPredictor returns the default predictor. It returns nil if not initialized.
+func getPredictor() *Predictor {
+	_mu.RLock()
+	defer _mu.RUnlock()
+
+	return _predictor
+}
+
+// getSQLite3Path returns the database file path set via `SetSQLite3Path()`.
+func getSQLite3Path() string {
+	_mu.RLock()
+	defer _mu.RUnlock()
+
+	return _sqlite3Path
+}
+
 // getBlake3 returns the hash of the input to byte array.
 func getBlake3[T any](inputs ...T) ([]byte, error) {
 	hasher := blake3.New()
@@ -359,3 +575,32 @@ func uint64ToByteArray(num uint64) []byte {
 
 	return arr
 }
+
+// toAnySlice converts the slice of any type to the slice of interface.
+func toAnySlice[T any](items []T) []any {
+	converted := make([]any, len(items))
+
+	for i, item := range items {
+		converted[i] = item
+	}
+
+	return converted
+}
+
+// withPredictor calls fn with the default predictor, creating it if not exists.
+// The package lock is held during fn, so that `Reset()` never closes the
+// predictor in use and the updates are never made to the discarded one.
+func withPredictor(fn func(predictor *Predictor) error) error {
+	if getPredictor() == nil {
+		Reset()
+	}
+
+	_mu.RLock()
+	defer _mu.RUnlock()
+
+	if _predictor == nil {
+		return errors.New("predictor is not initialized")
+	}
+
+	return fn(_predictor)
+}
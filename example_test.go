@@ -1,8 +1,14 @@
 package bayes_test
 
 import (
+	"bytes"
+	"context"
+	"errors"
 	"fmt"
 	"log"
+	"math"
+	"math/rand/v2"
+	"strings"
 
 	"github.com/KEINOS/go-bayes"
 )
@@ -10,7 +16,9 @@ import (
 func Example() {
 	// "Happy Birthday", the train data. The types of slices available for the
 	// training are as follows:
-	//   bool, int, int16-int64, uint, uint16-uint64, float32, float64, string.
+	//   bool, int, int8-int64, uint, uint8-uint64, float32, float64, string,
+	//   []byte and the types implementing bayes.Hashable, encoding.
+	//   BinaryMarshaler (such as time.Time) or fmt.Stringer.
 	score := []string{
 		"So", "So", "La", "So", "Do", "Si",
 		"So", "So", "La", "So", "Re", "Do",
@@ -52,6 +60,40 @@ func Example() {
 	// Next is: Mi (Class ID: 6586414841969023711)
 }
 
+// ----------------------------------------------------------------------------
+//  Generate()
+// ----------------------------------------------------------------------------
+
+func ExampleGenerate() {
+	defer bayes.Reset()
+
+	// "Happy Birthday"
+	score := []string{
+		"So", "So", "La", "So", "Do", "Si",
+		"So", "So", "La", "So", "Re", "Do",
+		"So", "So", "So", "Mi", "Do", "Si", "La",
+		"Fa", "Fa", "Mi", "Do", "Re", "Do",
+	}
+
+	if err := bayes.Train(score); err != nil {
+		log.Panic(err) // panic to defer Reset()
+	}
+
+	// Sample a variation of the melody. The fixed seed of the random number
+	// generator gives the same melody every time.
+	melody, err := bayes.Generate([]string{"So", "So"}, 10, bayes.GenerateOptions{
+		Rand:        rand.New(rand.NewPCG(1, 2)),
+		Temperature: 1.5,
+		TopK:        3,
+	})
+	if err != nil {
+		log.Panic(err) // panic to defer Reset()
+	}
+
+	fmt.Println(melody)
+	// Output: [So Mi Do Si La Fa Fa Mi Do Re]
+}
+
 // ----------------------------------------------------------------------------
 //  HashTrans()
 // ----------------------------------------------------------------------------
@@ -85,6 +127,50 @@ func ExampleHashTrans() {
 	// Hex: 1a0d1201d898958f
 }
 
+// ----------------------------------------------------------------------------
+//  MostLikelyContinuation()
+// ----------------------------------------------------------------------------
+
+func ExampleMostLikelyContinuation() {
+	defer bayes.Reset()
+
+	// The past workflows. "review" is the most common next step of "draft",
+	// but it is followed by various steps.
+	if err := bayes.TrainCorpus([][]string{
+		{"draft", "review", "fix", "merge"},
+		{"draft", "review", "discuss", "close"},
+		{"draft", "review", "reject", "close"},
+		{"draft", "review", "wait", "close"},
+		{"draft", "test", "merge", "deploy"},
+		{"draft", "test", "merge", "deploy"},
+		{"draft", "test", "merge", "deploy"},
+	}); err != nil {
+		log.Panic(err) // panic to defer Reset()
+	}
+
+	// The best three-step plans after "draft"
+	plans, err := bayes.MostLikelyContinuation([]string{"draft"}, 3, 2)
+	if err != nil {
+		log.Panic(err) // panic to defer Reset()
+	}
+
+	for _, plan := range plans {
+		fmt.Printf("%v (%.2f)\n", plan.Items, math.Exp(plan.LogProbability))
+	}
+
+	// Greedy prediction for comparison
+	greedy, err := bayes.MostLikelyContinuation([]string{"draft"}, 3, 1)
+	if err != nil {
+		log.Panic(err) // panic to defer Reset()
+	}
+
+	fmt.Printf("Greedy: %v (%.2f)\n", greedy[0].Items, math.Exp(greedy[0].LogProbability))
+	// Output:
+	// [test merge deploy] (0.31)
+	// [review fix merge] (0.17)
+	// Greedy: [review fix merge] (0.17)
+}
+
 // ----------------------------------------------------------------------------
 //  New()
 // ----------------------------------------------------------------------------
@@ -104,6 +190,309 @@ func ExampleNew() {
 	// Output: 100
 }
 
+// ----------------------------------------------------------------------------
+//  NewDetector()
+// ----------------------------------------------------------------------------
+
+func ExampleNewDetector() {
+	predictor, err := bayes.NewPredictor(bayes.WithMaxOrder(2))
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	defer predictor.Close()
+
+	// The usual API calls of the users
+	if err := predictor.TrainCorpus([][]any{
+		{"login", "list", "get", "logout"},
+		{"login", "list", "get", "get", "logout"},
+		{"login", "get", "logout"},
+	}); err != nil {
+		log.Fatal(err)
+	}
+
+	detector, err := bayes.NewDetector(predictor)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	// Feed the events as they arrive
+	for _, event := range []string{"login", "list", "delete_all", "get", "logout"} {
+		observation, err := detector.Observe(event)
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		if observation.Anomalous {
+			fmt.Printf("Alert: %v (surprise: %.1f)\n", event, observation.Surprise)
+		}
+
+		if span := observation.Closed; span != nil {
+			fmt.Printf("Anomalous span [%d:%d]: %v\n", span.Start, span.End, span.Items)
+		}
+	}
+	// Output:
+	// Alert: delete_all (surprise: +Inf)
+	// Alert: get (surprise: +Inf)
+	// Anomalous span [2:4]: [delete_all get]
+}
+
+// ----------------------------------------------------------------------------
+//  NewModel()
+// ----------------------------------------------------------------------------
+
+func ExampleNewModel() {
+	model, err := bayes.NewModel[string]()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	defer model.Close()
+
+	if err := model.Train([]string{"So", "So", "La", "So", "Do", "Si"}); err != nil {
+		log.Fatal(err)
+	}
+
+	// No type assertion is needed
+	next, err := model.Predict([]string{"So", "Do"})
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	fmt.Println("Next:", strings.ToUpper(next))
+	// Output: Next: SI
+}
+
+// ----------------------------------------------------------------------------
+//  NewPredictor()
+// ----------------------------------------------------------------------------
+
+func ExampleNewPredictor() {
+	// Two independent models in the same process
+	melody, err := bayes.NewPredictor(bayes.WithScopeID(1))
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	rhythm, err := bayes.NewPredictor(bayes.WithScopeID(2))
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	// The items are given as a slice of interface. The types of the items
+	// available are the same as the convenient functions.
+	if err := melody.Train([]any{"So", "So", "La", "So", "Do", "Si"}); err != nil {
+		log.Fatal(err)
+	}
+
+	if err := rhythm.Train([]any{1, 1, 2, 1, 1, 2}); err != nil {
+		log.Fatal(err)
+	}
+
+	nextNote, err := melody.Predict([]any{"So", "So"})
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	nextBeat, err := rhythm.Predict([]any{1, 1})
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	fmt.Println("Next note:", melody.GetClass(nextNote))
+	fmt.Println("Next beat:", rhythm.GetClass(nextBeat))
+
+	// Output:
+	// Next note: La
+	// Next beat: 2
+}
+
+// ----------------------------------------------------------------------------
+//  NewQuantileBins()
+// ----------------------------------------------------------------------------
+
+func ExampleNewQuantileBins() {
+	// Temperature readings of a sensor. Without a quantizer, every distinct
+	// reading would be a class of its own.
+	readings := []float64{20.1, 21.3, 22.8, 20.4, 21.6, 23.1, 20.2, 21.2, 22.6}
+
+	// Learn 3 bins holding about the same number of the readings
+	quantizer, err := bayes.NewQuantileBins(readings, 3)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	fmt.Println("Lower edges:", quantizer.Edges())
+
+	predictor, err := bayes.NewPredictor(bayes.WithQuantizer(quantizer))
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	items := make([]any, len(readings))
+	for i, reading := range readings {
+		items[i] = reading
+	}
+
+	if err := predictor.Train(items); err != nil {
+		log.Fatal(err)
+	}
+
+	// 21.0 is in the lowest bin, which is followed by the middle bin
+	nextBin, err := predictor.Predict([]any{21.0})
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	fmt.Println("Next bin from:", predictor.GetClass(nextBin))
+
+	// Output:
+	// Lower edges: [20.1 21.2 22.6]
+	// Next bin from: 21.2
+}
+
+// ----------------------------------------------------------------------------
+//  Predictor.Sources()
+// ----------------------------------------------------------------------------
+
+func ExamplePredictor_Sources() {
+	predictor, err := bayes.NewPredictor(bayes.WithProvenance())
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	// Train with the document IDs
+	if err := predictor.TrainFrom("user-1", []any{"login", "view", "buy"}); err != nil {
+		log.Fatal(err)
+	}
+
+	if err := predictor.TrainFrom("user-2", []any{"login", "view", "logout"}); err != nil {
+		log.Fatal(err)
+	}
+
+	sources, err := predictor.Sources([]any{"login"}, "view")
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	fmt.Println("Sources of login -> view:", sources)
+
+	// Delete the data of user-1 and prove it
+	if err := predictor.UntrainFrom("user-1", []any{"login", "view", "buy"}); err != nil {
+		log.Fatal(err)
+	}
+
+	sources, err = predictor.Sources([]any{"login"}, "view")
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	fmt.Println("Sources of login -> view:", sources)
+
+	// Output:
+	// Sources of login -> view: [user-1 user-2]
+	// Sources of login -> view: [user-2]
+}
+
+// ----------------------------------------------------------------------------
+//  Predict()
+// ----------------------------------------------------------------------------
+
+func ExamplePredict_unknown_context() {
+	defer bayes.Reset()
+
+	// "Save Our Souls" Morse code. The class ID of false is 0.
+	codes := []bool{
+		true, true, true, // ... ==> S
+		false, false, false, // ___ ==> O
+		true, true, true, // ... ==> S
+	}
+
+	if err := bayes.Train(codes); err != nil {
+		log.Panic(err) // panic to defer Reset()
+	}
+
+	// The context never appeared in the training set. Note that it is not
+	// "false" (class ID 0) but an error.
+	_, err := bayes.Predict([]string{"SOS"})
+	if errors.Is(err, bayes.ErrUnknownContext) {
+		fmt.Println("Unknown context")
+	}
+
+	// Output: Unknown context
+}
+
+// ----------------------------------------------------------------------------
+//  PredictTopK()
+// ----------------------------------------------------------------------------
+
+func ExamplePredictTopK() {
+	defer bayes.Reset()
+
+	// Happy Birthday
+	score := []string{
+		"So", "So", "La", "So", "Do", "Si",
+		"So", "So", "La", "So", "Re", "Do",
+		"So", "So", "So", "Mi", "Do", "Si", "La",
+		"Fa", "Fa", "Mi", "Do", "Re", "Do",
+	}
+
+	if err := bayes.Train(score); err != nil {
+		log.Panic(err) // panic to defer Reset()
+	}
+
+	// Top 3 candidates of the next note after "So"
+	predictions, err := bayes.PredictTopK([]string{"So"}, 3)
+	if err != nil {
+		log.Panic(err) // panic to defer Reset()
+	}
+
+	for _, prediction := range predictions {
+		fmt.Printf("%v: %.2f\n", prediction.Raw, prediction.Probability)
+	}
+
+	// Output:
+	// So: 0.65
+	// Do: 0.14
+	// La: 0.10
+}
+
+// ----------------------------------------------------------------------------
+//  Score()
+// ----------------------------------------------------------------------------
+
+func ExampleScore() {
+	defer bayes.Reset()
+
+	journeys := [][]string{
+		{"top", "search", "item", "cart", "buy"},
+		{"top", "search", "item", "top"},
+		{"top", "item", "cart", "buy"},
+	}
+
+	if err := bayes.TrainCorpus(journeys); err != nil {
+		log.Panic(err) // panic to defer Reset()
+	}
+
+	// Rank the candidate journeys by their likelihood
+	for _, candidate := range [][]string{
+		{"top", "search", "item", "cart"},
+		{"top", "item", "cart", "buy"},
+		{"top", "cart", "search"},
+	} {
+		score, err := bayes.Score(candidate)
+		if err != nil {
+			log.Panic(err) // panic to defer Reset()
+		}
+
+		fmt.Printf("%v: %.3f\n", candidate, score.LogProbability)
+	}
+	// Output:
+	// [top search item cart]: -0.948
+	// [top item cart buy]: -0.938
+	// [top cart search]: -Inf
+}
+
 // ----------------------------------------------------------------------------
 //  Reset(), Train() and Predict()
 // ----------------------------------------------------------------------------
@@ -151,6 +540,45 @@ func ExampleTrain_bool() {
 	// Output: OK
 }
 
+// PageView is a custom event type which implements bayes.Hashable.
+type PageView struct {
+	Page   string
+	UserID int // ignored to predict the flow of the pages of any users
+}
+
+func (v PageView) BayesHash() uint64 {
+	hashed, _ := bayes.HashTrans(v.Page)
+
+	return hashed
+}
+
+func ExampleTrain_hashable() {
+	defer bayes.Reset()
+
+	// The custom type can be trained as is, without mapping to strings
+	events := []PageView{
+		{Page: "/", UserID: 1},
+		{Page: "/items", UserID: 1},
+		{Page: "/cart", UserID: 1},
+		{Page: "/", UserID: 2},
+		{Page: "/items", UserID: 2},
+	}
+
+	if err := bayes.Train(events); err != nil {
+		log.Panic(err) // panic to defer Reset()
+	}
+
+	nextPage, err := bayes.Predict([]PageView{{Page: "/items", UserID: 3}})
+	if err != nil {
+		log.Panic(err) // panic to defer Reset()
+	}
+
+	// The original value is the last trained one of the class
+	fmt.Printf("%+v\n", bayes.GetClass(nextPage))
+
+	// Output: {Page:/cart UserID:1}
+}
+
 //nolint:varnamelen,cyclop,funlen // long but simple example
 func ExampleTrain_int() {
 	defer bayes.Reset()
@@ -271,6 +699,136 @@ func ExampleTrain_string() {
 	// Class: Mi (ID: 6586414841969023711)
 }
 
+func ExampleTrainCorpus() {
+	defer func() {
+		bayes.SetBoundaries(false)
+		bayes.Reset()
+	}()
+
+	// Train each session between the start and the end of the sequence
+	bayes.SetBoundaries(true)
+	bayes.Reset()
+
+	sessions := [][]string{
+		{"login", "search", "view", "logout"},
+		{"login", "view", "buy", "logout"},
+		{"login", "search", "logout"},
+	}
+
+	if err := bayes.TrainCorpus(sessions); err != nil {
+		log.Panic(err) // panic to defer Reset()
+	}
+
+	// The first item of a session
+	firstID, err := bayes.Predict([]any{bayes.StartOfSequence})
+	if err != nil {
+		log.Panic(err) // panic to defer Reset()
+	}
+
+	fmt.Println("First:", bayes.GetClass(firstID))
+
+	// The end of a session
+	nextID, err := bayes.Predict([]string{"buy", "logout"})
+	if err != nil {
+		log.Panic(err) // panic to defer Reset()
+	}
+
+	if bayes.GetClass(nextID) == bayes.EndOfSequence {
+		fmt.Println("Next: end of the session")
+	}
+	// Output:
+	// First: login
+	// Next: end of the session
+}
+
+func ExampleTrainReader() {
+	defer bayes.Reset()
+
+	// Newline-delimited event log. Use os.File to read from a file, which does
+	// not need to fit in memory.
+	eventLog := strings.NewReader("login\nview\ncart\nlogout\nlogin\nview\ncart\nbuy\nlogin\nview\n")
+
+	// Set a context with timeout or cancel to stop the training on the way.
+	ctx := context.Background()
+
+	if err := bayes.TrainReader(ctx, eventLog, bayes.FormatLines); err != nil {
+		log.Panic(err) // panic to defer Reset()
+	}
+
+	nextEvent, err := bayes.Predict([]string{"login", "view"})
+	if err != nil {
+		log.Panic(err) // panic to defer Reset()
+	}
+
+	fmt.Println(bayes.GetClass(nextEvent))
+
+	// Output: cart
+}
+
+func ExampleTrainWeighted() {
+	defer bayes.Reset()
+
+	// Pre-aggregated sequences with their frequencies, such as from a GROUP BY
+	// query. No need to repeat the training by the frequency.
+	for _, aggregated := range []struct {
+		events []string
+		freq   float64
+	}{
+		{events: []string{"login", "view", "buy"}, freq: 3},
+		{events: []string{"login", "view", "logout"}, freq: 1},
+	} {
+		if err := bayes.TrainWeighted(aggregated.events, aggregated.freq); err != nil {
+			log.Panic(err) // panic to defer Reset()
+		}
+	}
+
+	predictions, err := bayes.PredictTopK([]string{"login", "view"}, 0)
+	if err != nil {
+		log.Panic(err) // panic to defer Reset()
+	}
+
+	for _, prediction := range predictions {
+		fmt.Printf("%v: %.2f\n", prediction.Raw, prediction.Probability)
+	}
+
+	// Output:
+	// buy: 0.93
+	// logout: 0.07
+}
+
+func ExampleUntrain() {
+	defer bayes.Reset()
+
+	// Events of two customers
+	customerA := []string{"login", "view", "cart", "buy"}
+	customerB := []string{"login", "view", "cart", "logout"}
+
+	for _, events := range [][]string{customerA, customerB, customerA} {
+		if err := bayes.Train(events); err != nil {
+			log.Panic(err) // panic to defer Reset()
+		}
+	}
+
+	// Delete the data of customer A without retraining from scratch
+	for _, events := range [][]string{customerA, customerA} {
+		if err := bayes.Untrain(events); err != nil {
+			log.Panic(err) // panic to defer Reset()
+		}
+	}
+
+	// Only the events of customer B are left
+	predictions, err := bayes.PredictTopK([]string{"login", "view", "cart"}, 0)
+	if err != nil {
+		log.Panic(err) // panic to defer Reset()
+	}
+
+	for _, prediction := range predictions {
+		fmt.Printf("%v: %.2f\n", prediction.Raw, prediction.Probability)
+	}
+
+	// Output: logout: 1.00
+}
+
 // ----------------------------------------------------------------------------
 //  Storage.Type()
 // ----------------------------------------------------------------------------
@@ -285,3 +843,43 @@ func ExampleStorage_Type() {
 	// SQLite3
 	// unknown
 }
+
+// ----------------------------------------------------------------------------
+//  Save() and Load()
+// ----------------------------------------------------------------------------
+
+func ExampleSave() {
+	defer bayes.Reset()
+
+	// Train
+	if err := bayes.Train([]string{"So", "So", "La", "So", "Do", "Si"}); err != nil {
+		log.Panic(err) // panic to defer Reset()
+	}
+
+	// Save the trained model. Use os.File instead of bytes.Buffer to save it
+	// to a file.
+	var savedModel bytes.Buffer
+
+	if err := bayes.Save(&savedModel); err != nil {
+		log.Panic(err) // panic to defer Reset()
+	}
+
+	// Drop the trained model. Such as the process restarts.
+	bayes.Reset()
+
+	// Load the saved model
+	if err := bayes.Load(&savedModel); err != nil {
+		log.Panic(err) // panic to defer Reset()
+	}
+
+	nextNoteID, err := bayes.Predict([]string{"So", "So"})
+	if err != nil {
+		log.Panic(err) // panic to defer Reset()
+	}
+
+	// The class list is also restored with the original value
+	fmt.Printf("Next is: %v (Class ID: %v)\n", bayes.GetClass(nextNoteID), nextNoteID)
+
+	// Output:
+	// Next is: La (Class ID: 17627200281938459623)
+}
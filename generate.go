@@ -0,0 +1,207 @@
+package bayes
+
+import (
+	"math"
+	"math/rand/v2"
+
+	"github.com/pkg/errors"
+)
+
+// ============================================================================
+//  Generation of the sequences
+// ============================================================================
+//  `Generate()` continues the seed items by sampling each next item from the
+//  candidates of `PredictTopK()`, instead of always taking the most probable
+//  one as `Predict()`. So it produces the various sequences similar to the
+//  training data, such as the test traffic or the melodies.
+//
+//  Each step samples from the candidates as follows:
+//
+//    1. Apply the temperature:   p_i = p_i^(1/Temperature), normalized
+//    2. Keep the TopK most probable candidates
+//    3. Keep the most probable candidates whose cumulative probability reaches
+//       TopP
+//    4. Sample one of the candidates left by their probabilities
+// ============================================================================
+
+// GenerateOptions are the options of `Generate()`. The zero value samples from
+// the whole distribution as is with the global random number generator.
+type GenerateOptions struct {
+	// Rand is the random number generator. Set the one of a fixed seed for the
+	// reproducible output, such as rand.New(rand.NewPCG(1, 2)). Nil uses the
+	// global one of math/rand/v2.
+	Rand *rand.Rand
+	// Temperature flattens (> 1) or sharpens (< 1) the distribution. Near 0,
+	// it almost always takes the most probable candidate. Zero or negative
+	// means 1 (as is).
+	Temperature float64
+	// TopP keeps only the most probable candidates whose cumulative probability
+	// reaches TopP, between 0 and 1. Zero, negative or 1 or more means no limit.
+	TopP float64
+	// TopK keeps only the k most probable candidates. Zero or negative means no
+	// limit.
+	TopK int
+}
+
+// ----------------------------------------------------------------------------
+//  Public functions
+// ----------------------------------------------------------------------------
+
+// Generate returns up to n items following the seed items sampled from the
+// default predictor. It returns an error if a generated item is not of T, such
+// as with the classes of the mixed types. See `Predictor.Generate()` for
+// details.
+func Generate[T any](seed []T, n int, opts GenerateOptions) ([]T, error) {
+	predictor := getPredictor()
+	if predictor == nil {
+		return nil, errors.New("predictor is not initialized")
+	}
+
+	generated, err := predictor.Generate(toAnySlice(seed), n, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	typed := make([]T, len(generated))
+
+	for i, item := range generated {
+		value, ok := item.(T)
+		if !ok {
+			return nil, errors.Errorf("generated item at index %d is of the unexpected type: %T", i, item)
+		}
+
+		typed[i] = value
+	}
+
+	return typed, nil
+}
+
+// ----------------------------------------------------------------------------
+//  Methods
+// ----------------------------------------------------------------------------
+
+// Generate returns up to n items following the seed items, sampling each item
+// from the candidates of the next item. The seed is not included. Unlike
+// calling `Predict()` repeatedly, the output varies over the calls unless the
+// Rand of the fixed seed is given.
+//
+// It stops before n items if no candidate follows the context. If the
+// boundaries are enabled, the seed is the start of a sequence, and it also
+// stops when EndOfSequence is sampled, which is not included. See
+// `WithBoundaries()`.
+func (p *Predictor) Generate(seed []any, n int, opts GenerateOptions) ([]any, error) {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	if p.nodeLogger == nil {
+		return nil, errors.New("predictor is not initialized")
+	}
+
+	contextIDs, err := p.itemIDs(seed)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to generate the items")
+	}
+
+	endID := uint64(0)
+
+	if p.boundaries {
+		startID, _ := p.itemID(StartOfSequence)
+		endID, _ = p.itemID(EndOfSequence)
+
+		contextIDs = append([]uint64{startID}, contextIDs...)
+	}
+
+	generated := []any{}
+
+	for len(generated) < n {
+		predictions, err := p.predictIDs(contextIDs)
+		if err != nil {
+			return nil, err
+		}
+
+		if len(predictions) == 0 {
+			break
+		}
+
+		next := sample(predictions, opts)
+		if p.boundaries && next.ClassID == endID {
+			break
+		}
+
+		generated = append(generated, next.Raw)
+		contextIDs = p.truncate(append(contextIDs, next.ClassID))
+	}
+
+	return generated, nil
+}
+
+// ----------------------------------------------------------------------------
+//  Private functions
+// ----------------------------------------------------------------------------
+
+// sample returns one of the candidates sampled by their probabilities with the
+// options applied. The candidates must be in descending order of the
+// probability and not empty.
+func sample(predictions []Prediction, opts GenerateOptions) Prediction {
+	weights := make([]float64, len(predictions))
+	total := float64(0)
+
+	for i, prediction := range predictions {
+		weights[i] = prediction.Probability
+		if opts.Temperature > 0 && opts.Temperature != 1 {
+			// In the log space not to underflow with the low temperature.
+			weights[i] = math.Log(prediction.Probability) / opts.Temperature
+		}
+	}
+
+	if opts.Temperature > 0 && opts.Temperature != 1 {
+		// Subtract the maximum, which is the first, to keep the largest 1.
+		maxWeight := weights[0]
+
+		for i := range weights {
+			weights[i] = math.Exp(weights[i] - maxWeight)
+		}
+	}
+
+	if opts.TopK > 0 && opts.TopK < len(weights) {
+		weights = weights[:opts.TopK]
+	}
+
+	for _, weight := range weights {
+		total += weight
+	}
+
+	if opts.TopP > 0 && opts.TopP < 1 {
+		cumulative := float64(0)
+
+		for i, weight := range weights {
+			cumulative += weight
+
+			if cumulative >= opts.TopP*total {
+				weights = weights[:i+1]
+				total = cumulative
+
+				break
+			}
+		}
+	}
+
+	var threshold float64
+
+	//nolint:gosec // the random numbers are not for security
+	if opts.Rand != nil {
+		threshold = opts.Rand.Float64() * total
+	} else {
+		threshold = rand.Float64() * total
+	}
+
+	for i, weight := range weights {
+		threshold -= weight
+		if threshold < 0 {
+			return predictions[i]
+		}
+	}
+
+	// Rounding errors of the sum. Fall back to the last candidate left.
+	return predictions[len(weights)-1]
+}
@@ -0,0 +1,120 @@
+package bayes
+
+import (
+	"math/rand/v2"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// melody is the corpus of a repetitive melody.
+var melody = [][]any{{
+	"Do", "Re", "Mi", "Do", "Mi", "Re", "Do", "Re", "Mi", "Fa", "So",
+	"Do", "Re", "Mi", "Do", "Mi", "Re", "Do", "So", "Mi", "Re", "Do",
+}}
+
+// ----------------------------------------------------------------------------
+//  Predictor
+// ----------------------------------------------------------------------------
+
+func TestPredictor_Generate(t *testing.T) {
+	t.Parallel()
+
+	predictor := newTrainedPredictor(t, melody, WithMaxOrder(2))
+
+	// Reproducible with the same seed
+	generated1, err := predictor.Generate([]any{"Do"}, 20, GenerateOptions{Rand: rand.New(rand.NewPCG(1, 2))})
+	require.NoError(t, err)
+	require.Len(t, generated1, 20)
+
+	generated2, err := predictor.Generate([]any{"Do"}, 20, GenerateOptions{Rand: rand.New(rand.NewPCG(1, 2))})
+	require.NoError(t, err)
+	require.Equal(t, generated1, generated2, "same seed should generate the same items")
+
+	// Greedy by TopK of 1, the low temperature or the low TopP
+	greedy := []any{}
+	context := []any{"Do"}
+
+	for i := 0; i < 10; i++ {
+		classID, err := predictor.Predict(context)
+		require.NoError(t, err)
+
+		greedy = append(greedy, predictor.GetClass(classID))
+		context = append(context, predictor.GetClass(classID))
+	}
+
+	for _, opts := range []GenerateOptions{
+		{TopK: 1},
+		{Temperature: 0.001},
+		{TopP: 0.01},
+	} {
+		opts.Rand = rand.New(rand.NewPCG(3, 4))
+
+		generated, err := predictor.Generate([]any{"Do"}, 10, opts)
+		require.NoError(t, err)
+		assert.Equal(t, greedy, generated, "options: %+v", opts)
+	}
+}
+
+func TestPredictor_Generate_distribution(t *testing.T) {
+	t.Parallel()
+
+	predictor := newTrainedPredictor(t, [][]any{{"a", "b"}, {"a", "b"}, {"a", "c"}})
+
+	predictions, err := predictor.PredictTopK([]any{"a"}, 0)
+	require.NoError(t, err)
+	require.Len(t, predictions, 2)
+
+	//nolint:varnamelen // tt is short but descriptive
+	for _, tt := range []struct {
+		opts   GenerateOptions
+		expect float64 // rate of the most probable one
+	}{
+		{GenerateOptions{}, predictions[0].Probability},
+		{GenerateOptions{TopK: 1}, 1},
+		{GenerateOptions{Temperature: 100}, 0.5}, // almost flat
+	} {
+		tt.opts.Rand = rand.New(rand.NewPCG(5, 6))
+		count := 0
+
+		const numTrials = 2000
+
+		for i := 0; i < numTrials; i++ {
+			generated, err := predictor.Generate([]any{"a"}, 1, tt.opts)
+			require.NoError(t, err)
+			require.Len(t, generated, 1)
+
+			if generated[0] == predictions[0].Raw {
+				count++
+			}
+		}
+
+		assert.InDelta(t, tt.expect, float64(count)/numTrials, 0.05, "options: %+v", tt.opts)
+	}
+}
+
+func TestPredictor_Generate_stop(t *testing.T) {
+	t.Parallel()
+
+	predictor := newTrainedPredictor(t, [][]any{{"a", "b"}})
+
+	generated, err := predictor.Generate([]any{"a"}, 5, GenerateOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, []any{"b"}, generated, "it should stop if no candidate follows")
+
+	generated, err = predictor.Generate([]any{"a"}, 0, GenerateOptions{})
+	require.NoError(t, err)
+	assert.Empty(t, generated)
+
+	_, err = predictor.Generate([]any{struct{}{}}, 1, GenerateOptions{})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "failed to generate the items")
+
+	// End of the sequence
+	bounded := newTrainedPredictor(t, [][]any{{"a", "b"}}, WithBoundaries())
+
+	generated, err = bounded.Generate(nil, 5, GenerateOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, []any{"a", "b"}, generated, "it should start from and stop at the boundaries")
+}
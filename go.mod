@@ -1,6 +1,6 @@
 module github.com/KEINOS/go-bayes
 
-go 1.18
+go 1.22
 
 require (
 	github.com/pkg/errors v0.9.1
@@ -0,0 +1,71 @@
+package bayes
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+)
+
+// ----------------------------------------------------------------------------
+//  Helpers shared by the tests
+// ----------------------------------------------------------------------------
+
+// hashableItem is an item type which implements Hashable.
+type hashableItem struct {
+	id uint64
+}
+
+func (h hashableItem) BayesHash() uint64 { return h.id }
+
+// hashableString is a string type which implements Hashable.
+type hashableString string
+
+func (hashableString) BayesHash() uint64 { return 7 }
+
+// marshalerItem is an item type which implements encoding.BinaryMarshaler. It
+// fails to marshal if empty.
+type marshalerItem string
+
+func (m marshalerItem) MarshalBinary() ([]byte, error) {
+	if m == "" {
+		return nil, errors.New("empty item")
+	}
+
+	return []byte(m), nil
+}
+
+func mustConvTyped(t *testing.T, item any) uint64 {
+	t.Helper()
+
+	classID, err := convAnyToTypedUint64(item)
+	require.NoError(t, err)
+
+	return classID
+}
+
+func mustConv(t *testing.T, item any) uint64 {
+	t.Helper()
+
+	classID, err := convAnyToUint64(item)
+	require.NoError(t, err)
+
+	return classID
+}
+
+// mustSaveTrained returns the saved model of a new predictor trained by fn.
+func mustSaveTrained(t *testing.T, fn func(p *Predictor) error, opts ...Option) []byte {
+	t.Helper()
+
+	predictor, err := NewPredictor(opts...)
+	require.NoError(t, err)
+
+	require.NoError(t, fn(predictor))
+
+	var saved bytes.Buffer
+
+	require.NoError(t, predictor.Save(&saved))
+
+	return saved.Bytes()
+}
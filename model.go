@@ -0,0 +1,437 @@
+package bayes
+
+import (
+	"sync"
+
+	"github.com/pkg/errors"
+)
+
+// ----------------------------------------------------------------------------
+//  Type: Model
+// ----------------------------------------------------------------------------
+
+// Model is a Predictor for the items of type T. Unlike Predictor, it takes and
+// returns the items as T, so no type assertion of `GetClass()` is needed.
+//
+// Its class list maps the class IDs to the items of T and detects the hash
+// collisions by comparing the items. Training or predicting with an item whose
+// class ID is taken by a different item returns ErrHashCollision, instead of
+// returning the other item silently. Note that for Model[any] the items must
+// be comparable values, otherwise the comparison panics.
+//
+// The methods are safe for concurrent use. Use `NewModel()` to create an
+// instance.
+type Model[T comparable] struct {
+	// predictor is the underlying predictor of the items.
+	predictor *Predictor
+	// classes is the list of the items by the class ID.
+	classes map[uint64]T
+	// mu protects the fields above.
+	mu sync.RWMutex
+}
+
+// ModelPrediction is a candidate of the next item of Model.
+type ModelPrediction[T comparable] struct {
+	// Class is the item of the class. Zero value if EndOfSequence is true.
+	Class T
+	// ClassID is the ID of the class.
+	ClassID uint64
+	// Probability is the probability of the class normalized across the
+	// candidates.
+	Probability float64
+	// EndOfSequence is true if the candidate is the end of the sequence. See
+	// `WithBoundaries()`.
+	EndOfSequence bool
+}
+
+// ModelContinuation is a candidate of the continuation of the seed items of
+// the type T. See `Model.MostLikelyContinuation()` and
+// `MostLikelyContinuation()`.
+type ModelContinuation[T any] struct {
+	// Items are the items following the seed.
+	Items []T
+	// LogProbability is the natural logarithm of the probability of the items
+	// following the seed.
+	LogProbability float64
+}
+
+// ----------------------------------------------------------------------------
+//  Constructor
+// ----------------------------------------------------------------------------
+
+// NewModel returns a new Model for the items of type T with the same options
+// as `NewPredictor()`.
+//
+// With `WithQuantizer()`, the numeric items are replaced by float64, so T must
+// be float64 (or a non-numeric type, where the quantizer has no effect).
+//
+// The classes the predictor already has, such as the ones kept in the database
+// of SQLite3Storage, are taken over as T. It returns an error if a class is not
+// of type T, such as the items of a custom type reopened in the form they are
+// hashed by. See `Predictor.Save()`.
+func NewModel[T comparable](opts ...Option) (*Model[T], error) {
+	predictor, err := NewPredictor(opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	var zero T
+
+	if _, numeric := toFloat64(zero); numeric && predictor.quantizer != nil {
+		if _, ok := any(zero).(float64); !ok {
+			_ = predictor.Close()
+
+			return nil, errors.Errorf("quantizer requires Model[float64]. Given: Model[%T]", zero)
+		}
+	}
+
+	classes, err := typedClasses[T](predictor)
+	if err != nil {
+		_ = predictor.Close()
+
+		return nil, err
+	}
+
+	return &Model[T]{
+		predictor: predictor,
+		classes:   classes,
+	}, nil
+}
+
+// ----------------------------------------------------------------------------
+//  Methods
+// ----------------------------------------------------------------------------
+
+// Class returns the item of the given class ID. It returns false if the class
+// is not in the model.
+func (m *Model[T]) Class(classID uint64) (T, bool) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	item, ok := m.classes[classID]
+
+	return item, ok
+}
+
+// Close releases the resources of the model, such as the database connection.
+// See `Predictor.Close()`.
+func (m *Model[T]) Close() error {
+	return m.predictor.Close()
+}
+
+// Generate returns up to n items following the seed items, sampled from the
+// model. It returns ErrHashCollision if an item of the seed collides with a
+// different item in the model. See `Predictor.Generate()`.
+func (m *Model[T]) Generate(seed []T, n int, opts GenerateOptions) ([]T, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	if _, _, err := m.classIDs(seed); err != nil {
+		return nil, err
+	}
+
+	generated, err := m.predictor.Generate(toAnySlice(seed), n, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	typed := make([]T, len(generated))
+
+	for i, item := range generated {
+		value, ok := item.(T)
+		if !ok {
+			return nil, errors.Errorf("generated item at index %d is of the unexpected type: %T", i, item)
+		}
+
+		typed[i] = value
+	}
+
+	return typed, nil
+}
+
+// MostLikelyContinuation returns up to beamWidth continuations of length items
+// following the seed items, in descending order of the probability. It returns
+// ErrHashCollision if an item of the seed collides with a different item in the
+// model. See `Predictor.MostLikelyContinuation()`.
+func (m *Model[T]) MostLikelyContinuation(seed []T, length, beamWidth int) ([]ModelContinuation[T], error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	if _, _, err := m.classIDs(seed); err != nil {
+		return nil, err
+	}
+
+	continuations, err := m.predictor.MostLikelyContinuation(toAnySlice(seed), length, beamWidth)
+	if err != nil {
+		return nil, err
+	}
+
+	typed := make([]ModelContinuation[T], len(continuations))
+
+	for i, continuation := range continuations {
+		typed[i] = ModelContinuation[T]{
+			Items:          make([]T, len(continuation.ClassIDs)),
+			LogProbability: continuation.LogProbability,
+		}
+
+		for j, classID := range continuation.ClassIDs {
+			item, err := m.class(classID)
+			if err != nil {
+				return nil, err
+			}
+
+			typed[i].Items[j] = item
+		}
+	}
+
+	return typed, nil
+}
+
+// Predict returns the next item inferred from the given context. It returns
+// the same errors as `Predictor.Predict()`, and ErrHashCollision if an item of
+// the context collides with a different item in the model. If the end of the
+// sequence is predicted, it returns ErrEndOfSequence. With `WithBoundaries()`,
+// the empty context predicts the first item of a sequence.
+func (m *Model[T]) Predict(context []T) (T, error) {
+	var zero T
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	if _, _, err := m.classIDs(context); err != nil {
+		return zero, err
+	}
+
+	classID, err := m.predictor.Predict(toAnySlice(context))
+	if err != nil {
+		return zero, err
+	}
+
+	if m.predictor.GetClass(classID) == EndOfSequence {
+		return zero, ErrEndOfSequence
+	}
+
+	return m.class(classID)
+}
+
+// PredictTopK returns the k most probable next items inferred from the given
+// context, in descending order of the probability. If k is zero or negative,
+// all the candidates are returned. See `Predictor.PredictTopK()`.
+func (m *Model[T]) PredictTopK(context []T, k int) ([]ModelPrediction[T], error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	if _, _, err := m.classIDs(context); err != nil {
+		return nil, err
+	}
+
+	predictions, err := m.predictor.PredictTopK(toAnySlice(context), k)
+	if err != nil {
+		return nil, err
+	}
+
+	typed := make([]ModelPrediction[T], len(predictions))
+
+	for i, prediction := range predictions {
+		typed[i] = ModelPrediction[T]{
+			ClassID:       prediction.ClassID,
+			Probability:   prediction.Probability,
+			EndOfSequence: prediction.Raw == EndOfSequence,
+		}
+
+		if typed[i].EndOfSequence {
+			continue
+		}
+
+		class, err := m.class(prediction.ClassID)
+		if err != nil {
+			return nil, err
+		}
+
+		typed[i].Class = class
+	}
+
+	return typed, nil
+}
+
+// Score returns the likelihood of the items as a sequence. It returns
+// ErrHashCollision if an item collides with a different item in the model. See
+// `Predictor.Score()`.
+func (m *Model[T]) Score(items []T) (SequenceScore, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	if _, _, err := m.classIDs(items); err != nil {
+		return SequenceScore{}, err
+	}
+
+	return m.predictor.Score(toAnySlice(items))
+}
+
+// Train trains the model with the given items as a sequence. It returns
+// ErrHashCollision without any update if an item collides with a different
+// item, either in the model or in the given items.
+func (m *Model[T]) Train(items []T) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	classIDs, values, err := m.classIDs(items)
+	if err != nil {
+		return err
+	}
+
+	if err := m.predictor.Train(toAnySlice(items)); err != nil {
+		return err
+	}
+
+	for i, classID := range classIDs {
+		m.classes[classID] = values[i]
+	}
+
+	return nil
+}
+
+// TrainCorpus trains the model with each sequence of the corpus as a separate
+// sequence. It returns ErrHashCollision without any update as `Train()`. See
+// `Predictor.TrainCorpus()`.
+func (m *Model[T]) TrainCorpus(corpus [][]T) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	flatten := []T{}
+	converted := make([][]any, len(corpus))
+
+	for i, items := range corpus {
+		flatten = append(flatten, items...)
+		converted[i] = toAnySlice(items)
+	}
+
+	// Check the collisions across the sequences as well.
+	classIDs, values, err := m.classIDs(flatten)
+	if err != nil {
+		return err
+	}
+
+	if err := m.predictor.TrainCorpus(converted); err != nil {
+		return err
+	}
+
+	for i, classID := range classIDs {
+		m.classes[classID] = values[i]
+	}
+
+	return nil
+}
+
+// Untrain reverses the updates made by `Train()` with the same items. The items
+// which no longer appear in the model are removed. See `Predictor.Untrain()`.
+func (m *Model[T]) Untrain(items []T) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	classIDs, _, err := m.classIDs(items)
+	if err != nil {
+		return err
+	}
+
+	if err := m.predictor.Untrain(toAnySlice(items)); err != nil {
+		return err
+	}
+
+	for _, classID := range classIDs {
+		if m.predictor.GetClass(classID) == nil {
+			delete(m.classes, classID)
+		}
+	}
+
+	return nil
+}
+
+// ----------------------------------------------------------------------------
+//  Private methods
+// ----------------------------------------------------------------------------
+//  The private methods do not lock the model. The caller must hold the lock.
+
+// class returns the item of the class ID predicted.
+func (m *Model[T]) class(classID uint64) (T, error) {
+	item, ok := m.classes[classID]
+	if !ok {
+		return item, errors.Errorf("class %d is not in the model", classID)
+	}
+
+	return item, nil
+}
+
+// classIDs returns the class IDs of the items and the items to register, which
+// are quantized if the quantizer is set. It returns ErrHashCollision if an item
+// collides with a different item.
+func (m *Model[T]) classIDs(items []T) ([]uint64, []T, error) {
+	m.predictor.mu.RLock()
+	classIDs, err := m.predictor.itemIDs(toAnySlice(items))
+	m.predictor.mu.RUnlock()
+
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "failed to hash the items")
+	}
+
+	values := make([]T, len(items))
+	seen := make(map[uint64]T, len(items))
+
+	for i, classID := range classIDs {
+		value := items[i]
+
+		// The quantizer is never changed after creation, so no lock is needed.
+		if quantized, ok := m.predictor.quantize(value).(T); ok {
+			value = quantized
+		}
+
+		for _, known := range []map[uint64]T{m.classes, seen} {
+			if other, ok := known[classID]; ok && !sameItem(other, value) {
+				return nil, nil, errors.Wrapf(ErrHashCollision, "%v and %v", other, value)
+			}
+		}
+
+		seen[classID] = value
+		values[i] = value
+	}
+
+	return classIDs, values, nil
+}
+
+// ----------------------------------------------------------------------------
+//  Private functions
+// ----------------------------------------------------------------------------
+
+// typedClasses returns the classes of the predictor as T. The markers of the
+// sequences are skipped, since they are not items of T. See `WithBoundaries()`.
+func typedClasses[T comparable](predictor *Predictor) (map[uint64]T, error) {
+	predictor.mu.RLock()
+	defer predictor.mu.RUnlock()
+
+	classes := make(map[uint64]T, len(predictor.classes))
+
+	for classID, class := range predictor.classes {
+		if _, ok := class.Raw.(Boundary); ok {
+			continue
+		}
+
+		item, ok := class.Raw.(T)
+		if !ok {
+			var zero T
+
+			return nil, errors.Errorf("class %d of the predictor is not of Model[%T]. Given: %T",
+				classID, zero, class.Raw)
+		}
+
+		classes[classID] = item
+	}
+
+	return classes, nil
+}
+
+// sameItem returns true if the items are equal. NaNs are equal to each other
+// since they share the same class ID.
+func sameItem[T comparable](a, b T) bool {
+	//nolint:gocritic // a != a is true only for NaN
+	return a == b || (a != a && b != b)
+}
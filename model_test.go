@@ -0,0 +1,182 @@
+package bayes
+
+import (
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// ----------------------------------------------------------------------------
+//  NewModel
+// ----------------------------------------------------------------------------
+
+func TestNewModel_quantizer(t *testing.T) {
+	t.Parallel()
+
+	_, err := NewModel[int](WithQuantizer(FixedWidth{Width: 1}))
+
+	require.Error(t, err, "quantized items of int should be an error")
+	assert.Contains(t, err.Error(), "quantizer requires Model[float64]. Given: Model[int]")
+
+	model, err := NewModel[float64](WithQuantizer(FixedWidth{Width: 1}))
+	require.NoError(t, err)
+
+	require.NoError(t, model.Train([]float64{0.2, 1.5, 0.7, 1.1}))
+
+	next, err := model.Predict([]float64{0.9})
+	require.NoError(t, err)
+	assert.InDelta(t, 1.0, next, 0, "it should return the representative value of the bin")
+}
+
+// ----------------------------------------------------------------------------
+//  Methods
+// ----------------------------------------------------------------------------
+
+func TestModel_Predict(t *testing.T) {
+	t.Parallel()
+
+	model, err := NewModel[int]()
+	require.NoError(t, err)
+
+	defer model.Close()
+
+	require.NoError(t, model.Train([]int{1, 2, 3, 1, 2, 3}))
+
+	next, err := model.Predict([]int{1, 2})
+	require.NoError(t, err)
+	assert.Equal(t, 3, next)
+
+	item, ok := model.Class(mustConv(t, 2))
+	require.True(t, ok)
+	assert.Equal(t, 2, item)
+
+	_, err = model.Predict([]int{9})
+	require.ErrorIs(t, err, ErrUnknownContext)
+}
+
+func TestModel_PredictTopK(t *testing.T) {
+	t.Parallel()
+
+	model, err := NewModel[string]()
+	require.NoError(t, err)
+
+	require.NoError(t, model.Train([]string{"a", "b", "a", "b", "a", "c"}))
+
+	predictions, err := model.PredictTopK([]string{"a"}, 0)
+	require.NoError(t, err)
+	require.Len(t, predictions, 2)
+
+	assert.Equal(t, "b", predictions[0].Class)
+	assert.Equal(t, mustConv(t, "b"), predictions[0].ClassID)
+	assert.Equal(t, "c", predictions[1].Class)
+	assert.Greater(t, predictions[0].Probability, predictions[1].Probability)
+}
+
+// Score, Generate and MostLikelyContinuation are thin wrappers of the ones of
+// Predictor, so only the conversions of the items are tested here.
+func TestModel_sequence_methods(t *testing.T) {
+	t.Parallel()
+
+	model, err := NewModel[hashableItemNamed]()
+	require.NoError(t, err)
+
+	foo, bar, baz := hashableItemNamed{1, "foo"}, hashableItemNamed{2, "bar"}, hashableItemNamed{3, "baz"}
+	colliding := hashableItemNamed{1, "qux"}
+
+	require.NoError(t, model.Train([]hashableItemNamed{foo, bar, baz}))
+
+	score, err := model.Score([]hashableItemNamed{foo, bar})
+	require.NoError(t, err)
+	assert.Zero(t, score.LogProbability)
+
+	generated, err := model.Generate([]hashableItemNamed{foo}, 5, GenerateOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, []hashableItemNamed{bar, baz}, generated)
+
+	continuations, err := model.MostLikelyContinuation([]hashableItemNamed{foo}, 2, 2)
+	require.NoError(t, err)
+	require.Len(t, continuations, 1)
+	assert.Equal(t, []hashableItemNamed{bar, baz}, continuations[0].Items)
+
+	// Collisions with the trained items
+	_, err = model.Score([]hashableItemNamed{colliding, bar})
+	require.ErrorIs(t, err, ErrHashCollision)
+
+	_, err = model.Generate([]hashableItemNamed{colliding}, 1, GenerateOptions{})
+	require.ErrorIs(t, err, ErrHashCollision)
+
+	_, err = model.MostLikelyContinuation([]hashableItemNamed{colliding}, 1, 1)
+	require.ErrorIs(t, err, ErrHashCollision)
+}
+
+func TestModel_Train_hash_collision(t *testing.T) {
+	t.Parallel()
+
+	// collidingItem hashes to the id only, so the items of the same id but the
+	// different names collide.
+	type collidingItem struct {
+		hashableItem
+		name string
+	}
+
+	colliding, err := NewModel[collidingItem]()
+	require.NoError(t, err)
+
+	require.NoError(t, colliding.Train([]collidingItem{{hashableItem{1}, "foo"}, {hashableItem{2}, "bar"}}))
+
+	// Collision with the registered item
+	err = colliding.Train([]collidingItem{{hashableItem{1}, "baz"}, {hashableItem{2}, "bar"}})
+	require.ErrorIs(t, err, ErrHashCollision)
+	assert.Contains(t, err.Error(), "{{1} foo} and {{1} baz}")
+
+	// Collision within the given items
+	err = colliding.Train([]collidingItem{{hashableItem{3}, "qux"}, {hashableItem{3}, "quux"}})
+	require.ErrorIs(t, err, ErrHashCollision)
+
+	_, ok := colliding.Class(3)
+	assert.False(t, ok, "failed training should not register the items")
+
+	// Collision in the context
+	_, err = colliding.Predict([]collidingItem{{hashableItem{1}, "baz"}})
+	require.ErrorIs(t, err, ErrHashCollision)
+
+	_, err = colliding.PredictTopK([]collidingItem{{hashableItem{1}, "baz"}}, 0)
+	require.ErrorIs(t, err, ErrHashCollision)
+
+	next, err := colliding.Predict([]collidingItem{{hashableItem{1}, "foo"}})
+	require.NoError(t, err)
+	assert.Equal(t, "bar", next.name, "original item should be returned")
+}
+
+func TestModel_Train_nan(t *testing.T) {
+	t.Parallel()
+
+	model, err := NewModel[float64]()
+	require.NoError(t, err)
+
+	require.NoError(t, model.Train([]float64{math.NaN(), 1, math.NaN(), 1}),
+		"NaNs should not be a collision with each other")
+}
+
+func TestModel_Untrain(t *testing.T) {
+	t.Parallel()
+
+	model, err := NewModel[string]()
+	require.NoError(t, err)
+
+	require.NoError(t, model.Train([]string{"foo", "bar"}))
+	require.NoError(t, model.Train([]string{"foo", "baz"}))
+	require.NoError(t, model.Untrain([]string{"foo", "baz"}))
+
+	_, ok := model.Class(mustConv(t, "baz"))
+	assert.False(t, ok, "untrained item should be removed")
+
+	_, ok = model.Class(mustConv(t, "bar"))
+	assert.True(t, ok, "item still in the model should be kept")
+
+	next, err := model.Predict([]string{"foo"})
+	require.NoError(t, err)
+	assert.Equal(t, "bar", next)
+}
@@ -0,0 +1,568 @@
+package bayes
+
+import (
+	"bufio"
+	"bytes"
+	"encoding"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// ============================================================================
+//  Save and load the trained model.
+// ============================================================================
+//  The trained model is saved in the following binary format. All the numbers
+//  are in little endian.
+//
+//    Header:
+//      [4]byte  Magic  ("BAYS")
+//      uint16   Version
+//    Body:
+//      uint64   Scope ID
+//      uint8    Flags of the hashing mode and the boundaries (see saveFlag*)
+//      uint64   Number of classes, followed by the classes of:
+//                 uint64 class ID
+//                 uint8  type tag of the original value (see classTag*)
+//                 ...    original value. Fixed size numbers are stored in 8
+//                        bytes. String and []byte are stored as uint64 length
+//                        + bytes. time.Time is stored as the output of its
+//                        MarshalBinary() in the same way, which keeps the
+//                        offset but not the name of the zone. Bool is stored
+//                        in 1 byte. Boundary is stored as a fixed size
+//                        number. The custom types are stored in the form
+//                        they are hashed by. Such as the ID of Hashable as
+//                        uint64, the output of MarshalBinary() as []byte and
+//                        the output of String() as string.
+//      ...      Records of the NodeLogger. See logmem.NodeLog.WriteTo().
+// ============================================================================
+
+// SaveVersion is the current version of the binary format written by `Save()`.
+const SaveVersion = uint16(1)
+
+// saveFlagTypedHashing is the flag set if the class IDs are type-tagged. See
+// `WithTypedHashing()`.
+const saveFlagTypedHashing uint8 = 1
+
+// saveFlagBoundaries is the flag set if the sequences are trained between the
+// markers. See `WithBoundaries()`.
+const saveFlagBoundaries uint8 = 2
+
+// Type tags of the original value of the class.
+const (
+	classTagUint64 uint8 = iota + 1
+	classTagUint32
+	classTagUint16
+	classTagUint
+	classTagInt64
+	classTagInt32
+	classTagInt16
+	classTagInt
+	classTagFloat64
+	classTagFloat32
+	classTagString
+	classTagBool
+	classTagUint8
+	classTagInt8
+	classTagBytes
+	classTagTime
+	classTagBoundary
+)
+
+// saveMagic is the magic bytes of the binary format.
+var saveMagic = [4]byte{'B', 'A', 'Y', 'S'}
+
+// ----------------------------------------------------------------------------
+//  Public functions
+// ----------------------------------------------------------------------------
+
+// Load restores the trained model of the default predictor saved via `Save()`.
+//
+// The records are loaded into a new predictor of the current storage (see
+// `SetStorage()`) with the saved scope ID, and the class list is replaced with
+// the saved one. So `GetClass()` returns the original values after loading. On
+// error, the current model is left unchanged.
+func Load(r io.Reader) error {
+	return withPredictor(func(predictor *Predictor) error {
+		return predictor.Load(r)
+	})
+}
+
+// LoadNodeLogger restores the records of the NodeLogger saved via
+// `SaveNodeLogger()`.
+//
+// The NodeLogger must implement io.ReaderFrom, such as the ones created via
+// `New()`.
+func LoadNodeLogger(r io.Reader, logger NodeLogger) error {
+	loader, ok := logger.(io.ReaderFrom)
+	if !ok {
+		return errors.Errorf("failed to load the records. %T does not support loading", logger)
+	}
+
+	_, err := loader.ReadFrom(r)
+
+	return errors.Wrap(err, "failed to load the records")
+}
+
+// Save writes the trained model of the default predictor to w in a versioned
+// binary format. The saved data contains the records of the predictor, the
+// scope ID and the class list with the original values.
+//
+// Use `Load()` to restore the model.
+func Save(w io.Writer) error {
+	predictor := getPredictor()
+	if predictor == nil {
+		return errors.New("predictor is not initialized")
+	}
+
+	return predictor.Save(w)
+}
+
+// SaveNodeLogger writes the records of the NodeLogger to w in a versioned binary
+// format.
+//
+// The NodeLogger must implement io.WriterTo, such as the ones created via `New()`.
+// Use `LoadNodeLogger()` to restore the records.
+func SaveNodeLogger(w io.Writer, logger NodeLogger) error {
+	saver, ok := logger.(io.WriterTo)
+	if !ok {
+		return errors.Errorf("failed to save the records. %T does not support saving", logger)
+	}
+
+	_, err := saver.WriteTo(w)
+
+	return errors.Wrap(err, "failed to save the records")
+}
+
+// ----------------------------------------------------------------------------
+//  Methods
+// ----------------------------------------------------------------------------
+
+// Load restores the trained model saved via `Save()`.
+//
+// The records are loaded into a new node logger of the predictor's storage with
+// the saved scope ID, and the class list is replaced with the saved one. So
+// `GetClass()` returns the original values after loading. The hashing mode of
+// the items is also restored, since the saved class IDs depend on it. See
+// `WithTypedHashing()`. So are the boundaries of the sequences. See
+// `WithBoundaries()`. On error, the current model is left unchanged.
+//
+// It reads exactly the bytes of the saved model, so the reader can be shared
+// with other data following the model. Wrap the reader with bufio.Reader for
+// the performance if not shared.
+func (p *Predictor) Load(r io.Reader) error {
+	var (
+		magic   [4]byte
+		version uint16
+		scopeID uint64
+		flags   uint8
+	)
+
+	if err := readBinary(r, &magic, &version); err != nil {
+		return errors.Wrap(err, "failed to read the header")
+	}
+
+	if magic != saveMagic {
+		return errors.New("failed to read the header. Invalid magic bytes")
+	}
+
+	if version != SaveVersion {
+		return errors.Errorf("failed to read the header. Unsupported version: %d", version)
+	}
+
+	if err := readBinary(r, &scopeID); err != nil {
+		return errors.Wrap(err, "failed to read the scope ID")
+	}
+
+	if err := readBinary(r, &flags); err != nil {
+		return errors.Wrap(err, "failed to read the flags")
+	}
+
+	if flags&^(saveFlagTypedHashing|saveFlagBoundaries) != 0 {
+		return errors.Errorf("failed to read the flags. Unknown flags: %#x", flags)
+	}
+
+	classes, err := readClasses(r)
+	if err != nil {
+		return errors.Wrap(err, "failed to read the classes")
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	nodeLogger, err := newNodeLogger(p.storage, scopeID, p.sqlite3Path, p.memOptions...)
+	if err != nil {
+		return errors.Wrap(err, "failed to create the node logger")
+	}
+
+	// Load the records and store the classes in a batch, so the node logger
+	// keeping the classes never ends up with the records but without them.
+	err = inBatch(nodeLogger, func() error {
+		if err := LoadNodeLogger(r, nodeLogger); err != nil {
+			return err
+		}
+
+		for _, class := range classes {
+			storeClass(nodeLogger, class)
+		}
+
+		return nil
+	})
+	if err != nil {
+		if closer, ok := nodeLogger.(io.Closer); ok {
+			_ = closer.Close()
+		}
+
+		return err
+	}
+
+	if err := p.close(); err != nil {
+		return err
+	}
+
+	p.nodeLogger = nodeLogger
+	p.classes = classes
+	p.scopeID = scopeID
+	p.typedHashing = flags&saveFlagTypedHashing != 0
+	p.boundaries = flags&saveFlagBoundaries != 0
+
+	return nil
+}
+
+// Save writes the trained model to w in a versioned binary format. The saved
+// data contains the records of the node logger, the scope ID and the class list
+// with the original values.
+//
+// The classes of the custom item types are saved in the form they are hashed
+// by, since the original types can not be restored. Such as the ID of Hashable
+// as uint64, the output of MarshalBinary() as []byte and the output of String()
+// as string. So `GetClass()` returns them in the form after loading.
+//
+// Use `Load()` to restore the model.
+func (p *Predictor) Save(w io.Writer) error {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	if p.nodeLogger == nil {
+		return errors.New("predictor is not initialized")
+	}
+
+	writer := bufio.NewWriter(w)
+
+	var flags uint8
+
+	if p.typedHashing {
+		flags |= saveFlagTypedHashing
+	}
+
+	if p.boundaries {
+		flags |= saveFlagBoundaries
+	}
+
+	if err := writeBinary(writer, saveMagic, SaveVersion, p.nodeLogger.ID(), flags); err != nil {
+		return errors.Wrap(err, "failed to write the header")
+	}
+
+	if err := writeClasses(writer, p.classes); err != nil {
+		return errors.Wrap(err, "failed to write the classes")
+	}
+
+	if err := SaveNodeLogger(writer, p.nodeLogger); err != nil {
+		return err
+	}
+
+	return errors.Wrap(writer.Flush(), "failed to flush the saved data")
+}
+
+// ----------------------------------------------------------------------------
+//  Private functions
+// ----------------------------------------------------------------------------
+
+// classTagOf returns the type tag of the original value of the class. It
+// returns 0 for the unsupported types.
+//
+//nolint:cyclop // the switch is long but simple
+func classTagOf(raw any) uint8 {
+	switch raw.(type) {
+	case uint64:
+		return classTagUint64
+	case uint32:
+		return classTagUint32
+	case uint16:
+		return classTagUint16
+	case uint8:
+		return classTagUint8
+	case uint:
+		return classTagUint
+	case int64:
+		return classTagInt64
+	case int32:
+		return classTagInt32
+	case int16:
+		return classTagInt16
+	case int8:
+		return classTagInt8
+	case int:
+		return classTagInt
+	case float64:
+		return classTagFloat64
+	case float32:
+		return classTagFloat32
+	case string:
+		return classTagString
+	case []byte:
+		return classTagBytes
+	case time.Time:
+		return classTagTime
+	case bool:
+		return classTagBool
+	case Boundary:
+		return classTagBoundary
+	}
+
+	return 0
+}
+
+func readBinary(r io.Reader, data ...any) error {
+	for _, d := range data {
+		if err := binary.Read(r, binary.LittleEndian, d); err != nil {
+			return errors.Wrap(err, "failed to read the data")
+		}
+	}
+
+	return nil
+}
+
+//nolint:cyclop,funlen // the switch is long but simple
+func readClass(r io.Reader) (_Class, error) {
+	var (
+		classID uint64
+		tag     uint8
+		bits    uint64
+	)
+
+	if err := readBinary(r, &classID, &tag); err != nil {
+		return _Class{}, err
+	}
+
+	switch tag {
+	case classTagString:
+		buf, err := readBytes(r)
+
+		return _Class{ID: classID, Raw: string(buf)}, err
+	case classTagBytes:
+		buf, err := readBytes(r)
+
+		return _Class{ID: classID, Raw: buf}, err
+	case classTagTime:
+		buf, err := readBytes(r)
+		if err != nil {
+			return _Class{}, err
+		}
+
+		var v time.Time
+
+		err = v.UnmarshalBinary(buf)
+
+		return _Class{ID: classID, Raw: v}, errors.Wrap(err, "failed to read the time")
+	case classTagBool:
+		var v bool
+
+		err := readBinary(r, &v)
+
+		return _Class{ID: classID, Raw: v}, err
+	}
+
+	if err := readBinary(r, &bits); err != nil {
+		return _Class{}, err
+	}
+
+	// Intentional: the fixed size numbers are stored in 8 bytes preserving the
+	// bit representation. Convert them back to the original type.
+	//
+	//nolint:gosec // conversions are intentional as above
+	switch tag {
+	case classTagUint64:
+		return _Class{ID: classID, Raw: bits}, nil
+	case classTagUint32:
+		return _Class{ID: classID, Raw: uint32(bits)}, nil
+	case classTagUint16:
+		return _Class{ID: classID, Raw: uint16(bits)}, nil
+	case classTagUint8:
+		return _Class{ID: classID, Raw: uint8(bits)}, nil
+	case classTagUint:
+		return _Class{ID: classID, Raw: uint(bits)}, nil
+	case classTagInt64:
+		return _Class{ID: classID, Raw: int64(bits)}, nil
+	case classTagInt32:
+		return _Class{ID: classID, Raw: int32(bits)}, nil
+	case classTagInt16:
+		return _Class{ID: classID, Raw: int16(bits)}, nil
+	case classTagInt8:
+		return _Class{ID: classID, Raw: int8(bits)}, nil
+	case classTagInt:
+		return _Class{ID: classID, Raw: int(bits)}, nil
+	case classTagFloat64:
+		return _Class{ID: classID, Raw: math.Float64frombits(bits)}, nil
+	case classTagFloat32:
+		return _Class{ID: classID, Raw: math.Float32frombits(uint32(bits))}, nil
+	case classTagBoundary:
+		return _Class{ID: classID, Raw: Boundary(bits)}, nil
+	}
+
+	return _Class{}, errors.Errorf("unknown type tag: %d", tag)
+}
+
+// readBytes reads the bytes stored as uint64 length + bytes.
+func readBytes(r io.Reader) ([]byte, error) {
+	var length uint64
+
+	if err := readBinary(r, &length); err != nil {
+		return nil, err
+	}
+
+	// Read through the buffer instead of allocating length bytes beforehand,
+	// so that a broken length does not allocate a huge memory.
+	var buf bytes.Buffer
+
+	if _, err := io.CopyN(&buf, r, int64(length)); err != nil { // #nosec
+		return nil, errors.Wrap(err, "failed to read the bytes")
+	}
+
+	return buf.Bytes(), nil
+}
+
+func readClasses(r io.Reader) (map[uint64]_Class, error) {
+	var lenClasses uint64
+
+	if err := readBinary(r, &lenClasses); err != nil {
+		return nil, err
+	}
+
+	classes := make(map[uint64]_Class)
+
+	for i := uint64(0); i < lenClasses; i++ {
+		class, err := readClass(r)
+		if err != nil {
+			return nil, err
+		}
+
+		classes[class.ID] = class
+	}
+
+	return classes, nil
+}
+
+func writeBinary(w io.Writer, data ...any) error {
+	for _, d := range data {
+		if err := binary.Write(w, binary.LittleEndian, d); err != nil {
+			return errors.Wrap(err, "failed to write the data")
+		}
+	}
+
+	return nil
+}
+
+// writeClass writes the class ID and the original value with its type tag.
+//
+// Intentional: the fixed size numbers are stored in 8 bytes preserving the bit
+// representation.
+//
+//nolint:cyclop,gosec // the switch is long but simple. Conversions are intentional.
+func writeClass(w io.Writer, class _Class) error {
+	switch raw := class.Raw.(type) {
+	case uint64:
+		return writeBinary(w, class.ID, classTagUint64, raw)
+	case uint32:
+		return writeBinary(w, class.ID, classTagUint32, uint64(raw))
+	case uint16:
+		return writeBinary(w, class.ID, classTagUint16, uint64(raw))
+	case uint8:
+		return writeBinary(w, class.ID, classTagUint8, uint64(raw))
+	case uint:
+		return writeBinary(w, class.ID, classTagUint, uint64(raw))
+	case int64:
+		return writeBinary(w, class.ID, classTagInt64, uint64(raw))
+	case int32:
+		return writeBinary(w, class.ID, classTagInt32, uint64(raw))
+	case int16:
+		return writeBinary(w, class.ID, classTagInt16, uint64(raw))
+	case int8:
+		return writeBinary(w, class.ID, classTagInt8, uint64(raw))
+	case int:
+		return writeBinary(w, class.ID, classTagInt, uint64(raw))
+	case float64:
+		return writeBinary(w, class.ID, classTagFloat64, math.Float64bits(raw))
+	case float32:
+		return writeBinary(w, class.ID, classTagFloat32, uint64(math.Float32bits(raw)))
+	case string:
+		return writeBinary(w, class.ID, classTagString, uint64(len(raw)), []byte(raw))
+	case []byte:
+		return writeBinary(w, class.ID, classTagBytes, uint64(len(raw)), raw)
+	case time.Time:
+		marshaled, err := raw.MarshalBinary()
+		if err != nil {
+			return errors.Wrap(err, "failed to marshal the time")
+		}
+
+		return writeBinary(w, class.ID, classTagTime, uint64(len(marshaled)), marshaled)
+	case bool:
+		return writeBinary(w, class.ID, classTagBool, raw)
+	case Boundary:
+		return writeBinary(w, class.ID, classTagBoundary, uint64(raw))
+	}
+
+	return writeCustomClass(w, class)
+}
+
+// writeCustomClass writes the class of the custom item type in the form it is
+// hashed by. Such as the ID of Hashable as uint64, the output of MarshalBinary()
+// as []byte and the output of String() as string. So the loaded class is of the
+// type of the form, not the original type.
+func writeCustomClass(w io.Writer, class _Class) error {
+	switch raw := class.Raw.(type) {
+	case Hashable:
+		return writeBinary(w, class.ID, classTagUint64, raw.BayesHash())
+	case encoding.BinaryMarshaler:
+		marshaled, err := raw.MarshalBinary()
+		if err != nil {
+			return errors.Wrapf(err, "failed to marshal the class value of %T", class.Raw)
+		}
+
+		return writeBinary(w, class.ID, classTagBytes, uint64(len(marshaled)), marshaled)
+	case fmt.Stringer:
+		str := raw.String()
+
+		return writeBinary(w, class.ID, classTagString, uint64(len(str)), []byte(str))
+	}
+
+	return errors.Errorf("unsupported type of the class value: %T", class.Raw)
+}
+
+func writeClasses(w io.Writer, classes map[uint64]_Class) error {
+	classIDs := make([]uint64, 0, len(classes))
+
+	for classID := range classes {
+		classIDs = append(classIDs, classID)
+	}
+
+	// Sort to always produce the same output from the same model.
+	sort.Slice(classIDs, func(i, j int) bool { return classIDs[i] < classIDs[j] })
+
+	if err := writeBinary(w, uint64(len(classIDs))); err != nil {
+		return err
+	}
+
+	for _, classID := range classIDs {
+		if err := writeClass(w, classes[classID]); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,231 @@
+package bayes
+
+import (
+	"bytes"
+	"io"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// ----------------------------------------------------------------------------
+//  Load
+// ----------------------------------------------------------------------------
+
+//nolint:paralleltest // disable parallel test due to global variable change
+func TestLoad_broken_data(t *testing.T) {
+	defer Reset()
+
+	require.NoError(t, Train([]string{"foo", "bar", "baz"}))
+
+	var saved bytes.Buffer
+
+	require.NoError(t, Save(&saved))
+
+	data := saved.Bytes()
+
+	//nolint:varnamelen // tt is short but descriptive
+	for _, tt := range []struct {
+		data   []byte
+		expect string
+	}{
+		{nil, "failed to read the header"},
+		{[]byte("XXXX\x01\x00"), "Invalid magic bytes"},
+		{[]byte("BAYS\xff\x00"), "Unsupported version: 255"},
+		{data[:6], "failed to read the scope ID"},
+		{data[:14], "failed to read the flags"},
+		{append(append(data[:14:14], 0xff), data[15:]...), "Unknown flags: 0xff"},
+		{data[:20], "failed to read the classes"},
+		{data[:len(data)-1], "failed to load the records"},
+	} {
+		err := Load(bytes.NewReader(tt.data))
+
+		require.Error(t, err, "broken data should be an error")
+		assert.Contains(t, err.Error(), tt.expect)
+	}
+
+	// On error, the current model must be left unchanged.
+	assert.Equal(t, "bar", GetClass(mustConv(t, "bar")))
+}
+
+//nolint:paralleltest // disable parallel test due to global variable change
+func TestLoad_all_class_types(t *testing.T) {
+	defer Reset()
+
+	values := []any{
+		uint64(1 << 63), uint32(2), uint16(3), uint(4),
+		int64(-5), int32(-6), int16(-7), int(-8),
+		float64(9.5), float32(10.5),
+		"eleven", true,
+		uint8(13), int8(-14), []byte("fifteen"),
+		time.Date(2026, 1, 2, 3, 4, 5, 6, time.UTC),
+		EndOfSequence,
+	}
+
+	for i, value := range values {
+		_predictor.addClass(uint64(i), value) // #nosec
+	}
+
+	var saved bytes.Buffer
+
+	require.NoError(t, Save(&saved))
+
+	Reset()
+
+	require.NoError(t, Load(&saved))
+
+	for i, value := range values {
+		assert.Equal(t, value, GetClass(uint64(i))) // #nosec
+	}
+}
+
+func TestPredictor_Load_shared_reader(t *testing.T) {
+	t.Parallel()
+
+	saved := mustSaveTrained(t, func(p *Predictor) error {
+		return p.Train([]any{"foo", "bar"})
+	})
+
+	reader := bytes.NewReader(append(saved, "trailing data"...))
+
+	predictor, err := NewPredictor()
+	require.NoError(t, err)
+	require.NoError(t, predictor.Load(reader))
+
+	trailing, err := io.ReadAll(reader)
+	require.NoError(t, err)
+	assert.Equal(t, "trailing data", string(trailing), "it should not read past the saved model")
+}
+
+func TestPredictor_Load_typed_hashing(t *testing.T) {
+	t.Parallel()
+
+	saved := mustSaveTrained(t, func(p *Predictor) error {
+		return p.Train([]any{"foo", 1, true})
+	}, WithTypedHashing())
+
+	predictor, err := NewPredictor()
+	require.NoError(t, err)
+	require.NoError(t, predictor.Load(bytes.NewReader(saved)))
+
+	require.True(t, predictor.typedHashing, "hashing mode should be restored")
+
+	classID, err := predictor.Predict([]any{"foo"})
+	require.NoError(t, err)
+	assert.Equal(t, 1, predictor.GetClass(classID))
+}
+
+// ----------------------------------------------------------------------------
+//  Save
+// ----------------------------------------------------------------------------
+
+//nolint:paralleltest // disable parallel test due to global variable change
+func TestSave_not_initialized(t *testing.T) {
+	oldPredictor := _predictor
+
+	defer func() {
+		_predictor = oldPredictor // Recover object
+	}()
+
+	// Mock the singleton predictor
+	_predictor = nil
+
+	err := Save(new(bytes.Buffer))
+
+	require.Error(t, err, "it should be an error if the predictor is not initialized")
+	assert.Contains(t, err.Error(), "predictor is not initialized")
+}
+
+func TestPredictor_Save_custom_class_types(t *testing.T) {
+	t.Parallel()
+
+	saved := mustSaveTrained(t, func(p *Predictor) error {
+		if err := p.Train([]any{"a", hashableItem{id: 42}}); err != nil {
+			return err
+		}
+
+		if err := p.Train([]any{"b", marshalerItem("marshaled")}); err != nil {
+			return err
+		}
+
+		return p.Train([]any{"c", big.NewInt(123)})
+	})
+
+	predictor, err := NewPredictor()
+	require.NoError(t, err)
+	require.NoError(t, predictor.Load(bytes.NewReader(saved)))
+
+	// Loaded in the form they are hashed by
+	for _, tt := range []struct {
+		context string
+		expect  any
+	}{
+		{"a", uint64(42)},
+		{"b", []byte("marshaled")},
+		{"c", "123"},
+	} {
+		classID, err := predictor.Predict([]any{tt.context})
+		require.NoError(t, err)
+		assert.Equal(t, tt.expect, predictor.GetClass(classID))
+	}
+}
+
+func TestPredictor_Save_unsupported_class_type(t *testing.T) {
+	t.Parallel()
+
+	//nolint:varnamelen // tt is short but descriptive
+	for _, tt := range []struct {
+		raw    any
+		expect string
+	}{
+		{struct{}{}, "unsupported type of the class value: struct {}"},
+		{marshalerItem(""), "failed to marshal the class value of bayes.marshalerItem"},
+	} {
+		predictor, err := NewPredictor()
+		require.NoError(t, err)
+
+		predictor.addClass(1, tt.raw)
+
+		err = predictor.Save(new(bytes.Buffer))
+
+		require.Error(t, err, "it should be an error if the class value is not supported")
+		assert.Contains(t, err.Error(), tt.expect)
+	}
+}
+
+// ----------------------------------------------------------------------------
+//  SaveNodeLogger and LoadNodeLogger
+// ----------------------------------------------------------------------------
+
+func TestSaveNodeLogger_unsupported_logger(t *testing.T) {
+	t.Parallel()
+
+	err := SaveNodeLogger(new(bytes.Buffer), dummyLogger{})
+
+	require.Error(t, err, "it should be an error if the logger does not support saving")
+	assert.Contains(t, err.Error(), "does not support saving")
+
+	err = LoadNodeLogger(new(bytes.Buffer), dummyLogger{})
+
+	require.Error(t, err, "it should be an error if the logger does not support loading")
+	assert.Contains(t, err.Error(), "does not support loading")
+}
+
+// ----------------------------------------------------------------------------
+//  Helpers
+// ----------------------------------------------------------------------------
+
+// dummyLogger is a NodeLogger which does nothing.
+type dummyLogger struct{}
+
+func (dummyLogger) ID() uint64                            { return 0 }
+func (dummyLogger) Predict(_, _ uint64) float64           { return 0 }
+func (dummyLogger) PriorPtoB(_ uint64) float64            { return 0 }
+func (dummyLogger) PriorPfromAtoB(_, _ uint64) float64    { return 0 }
+func (dummyLogger) PriorPNotFromAtoB(_, _ uint64) float64 { return 0 }
+func (dummyLogger) Update(_, _ uint64)                    {}
+func (dummyLogger) UpdateN(_, _ uint64, _ float64)        {}
+func (dummyLogger) Decrement(_, _ uint64)                 {}
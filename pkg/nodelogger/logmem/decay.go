@@ -0,0 +1,135 @@
+package logmem
+
+import (
+	"math"
+	"time"
+)
+
+// ----------------------------------------------------------------------------
+//  Time-decay
+// ----------------------------------------------------------------------------
+//  Instead of scaling all the counts on every update, the weight of each new
+//  access grows exponentially as 2^e, where e is the number of half-lives
+//  elapsed since the reference point. Since the probabilities are the ratios of
+//  the counts, the older accesses lose their share as if they were decayed.
+//
+//  The decayed count as of now is the stored weight times 2^-e. Once e gets
+//  large, the weights are rescaled to the new reference point to avoid the
+//  overflow.
+// ============================================================================
+
+// maxDecayExponent is the number of half-lives after which the weights are
+// rescaled. 2^32 keeps enough precision for the float64 weights.
+const maxDecayExponent = 32
+
+// decay holds the state of the time-decay.
+type decay struct {
+	// reference is the time of the reference point. Zero means not set yet.
+	reference time.Time
+	// halfLife is the half-life by the elapsed time. Zero means not used.
+	halfLife time.Duration
+	// halfLifeUpdates is the half-life by the number of updates. Zero means not
+	// used.
+	halfLifeUpdates float64
+	// updates is the number of updates since the reference point.
+	updates float64
+}
+
+// ----------------------------------------------------------------------------
+//  Private methods
+// ----------------------------------------------------------------------------
+
+// exponent returns the number of half-lives elapsed since the reference point.
+func (n *NodeLog) exponent() float64 {
+	if n.decay == nil {
+		return 0
+	}
+
+	exponent := 0.0
+
+	if n.decay.halfLife > 0 && !n.decay.reference.IsZero() {
+		elapsed := n.clock().Sub(n.decay.reference)
+		exponent += elapsed.Seconds() / n.decay.halfLife.Seconds()
+	}
+
+	if n.decay.halfLifeUpdates > 0 {
+		exponent += n.decay.updates / n.decay.halfLifeUpdates
+	}
+
+	return exponent
+}
+
+func (n *NodeLog) clock() time.Time {
+	if n.now == nil {
+		return time.Now()
+	}
+
+	return n.now()
+}
+
+// getDecay returns the state of the time-decay, creating it if not exists.
+func (n *NodeLog) getDecay() *decay {
+	if n.decay == nil {
+		n.decay = new(decay)
+	}
+
+	return n.decay
+}
+
+// nextWeight returns the weight of a new access, rescaling the stored weights
+// if needed. The caller must hold the write lock.
+func (n *NodeLog) nextWeight() float64 {
+	if n.decay == nil {
+		return 1
+	}
+
+	if n.decay.reference.IsZero() {
+		n.decay.reference = n.clock()
+	}
+
+	n.decay.updates++
+
+	exponent := n.exponent()
+	if exponent > maxDecayExponent {
+		n.rescale(math.Exp2(-exponent))
+
+		exponent = 0
+	}
+
+	return math.Exp2(exponent)
+}
+
+// rescale multiplies all the stored weights by the factor and resets the
+// reference point to now.
+func (n *NodeLog) rescale(factor float64) {
+	n.TotalAccesses *= factor
+
+	for nodeA := range n.FromA {
+		n.FromA[nodeA] *= factor
+	}
+
+	for nodeB := range n.ToB {
+		n.ToB[nodeB] *= factor
+	}
+
+	for _, toB := range n.FromAToB {
+		for nodeB := range toB {
+			toB[nodeB] *= factor
+		}
+	}
+
+	if n.window != nil {
+		for i := n.window.head; i < len(n.window.entries); i++ {
+			n.window.entries[i].weight *= factor
+		}
+	}
+
+	n.decay.reference = n.clock()
+	n.decay.updates = 0
+}
+
+// scale returns the factor to convert the stored weights into the decayed
+// counts as of now.
+func (n *NodeLog) scale() float64 {
+	return math.Exp2(-n.exponent())
+}
@@ -0,0 +1,117 @@
+package logmem
+
+import (
+	"bytes"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWithHalfLifeUpdates(t *testing.T) {
+	t.Parallel()
+
+	nodeLog := New(0, WithHalfLifeUpdates(1))
+
+	nodeLog.Update(1, 2)
+	nodeLog.Update(1, 3)
+
+	// The weight of the older access is the half of the newer one.
+	assert.InDelta(t, 1.0/3.0, nodeLog.PriorPfromAtoB(1, 2), 1e-12)
+	assert.InDelta(t, 2.0/3.0, nodeLog.PriorPfromAtoB(1, 3), 1e-12)
+
+	// Decayed counts as of now. The latest access counts as 1.
+	scale := nodeLog.Scale()
+
+	assert.InDelta(t, 0.5, nodeLog.FromAToB[1][2]*scale, 1e-12)
+	assert.InDelta(t, 1.0, nodeLog.FromAToB[1][3]*scale, 1e-12)
+	assert.InDelta(t, 1.5, nodeLog.TotalAccesses*scale, 1e-12)
+}
+
+func TestWithHalfLife(t *testing.T) {
+	t.Parallel()
+
+	now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	nodeLog := New(0, WithClock(func() time.Time { return now }), WithHalfLife(time.Hour))
+
+	assert.InDelta(t, 1.0, nodeLog.Scale(), 0, "scale should be 1 before any update")
+
+	for i := 0; i < 3; i++ {
+		nodeLog.Update(1, 2)
+	}
+
+	now = now.Add(2 * time.Hour)
+
+	nodeLog.Update(1, 3)
+
+	// 3 old accesses are decayed to 3/4, which is less than the new one.
+	assert.InDelta(t, 0.75/1.75, nodeLog.PriorPfromAtoB(1, 2), 1e-12)
+	assert.InDelta(t, 1.0/1.75, nodeLog.PriorPfromAtoB(1, 3), 1e-12)
+	assert.Greater(t, nodeLog.Predict(1, 3), nodeLog.Predict(1, 2), "recent behavior should dominate")
+
+	// Reading does not change the ratios but decays the counts.
+	now = now.Add(time.Hour)
+
+	assert.InDelta(t, 0.875, nodeLog.TotalAccesses*nodeLog.Scale(), 1e-12)
+	assert.InDelta(t, 0.75/1.75, nodeLog.PriorPfromAtoB(1, 2), 1e-12)
+}
+
+func TestWithHalfLife_disabled(t *testing.T) {
+	t.Parallel()
+
+	nodeLog := New(0, WithHalfLife(0), WithHalfLifeUpdates(-1))
+
+	nodeLog.Update(1, 2)
+	nodeLog.Update(1, 2)
+
+	assert.Nil(t, nodeLog.decay)
+	assert.InDelta(t, 2.0, nodeLog.TotalAccesses, 0)
+	assert.InDelta(t, 1.0, nodeLog.Scale(), 0)
+}
+
+func TestNodeLog_decay_rescale(t *testing.T) {
+	t.Parallel()
+
+	nodeLog := New(0, WithHalfLifeUpdates(1))
+
+	for i := 0; i < 10000; i++ {
+		nodeLog.Update(uint64(i%2), uint64(i%3)) // #nosec
+	}
+
+	require.False(t, math.IsInf(nodeLog.TotalAccesses, 0), "weights should be rescaled before the overflow")
+	assert.LessOrEqual(t, nodeLog.TotalAccesses, math.Exp2(maxDecayExponent+1))
+	assert.InDelta(t, 2.0, nodeLog.TotalAccesses*nodeLog.Scale(), 1e-9, "sum of 1 + 1/2 + 1/4 + ...")
+}
+
+func TestNodeLog_decay_dump(t *testing.T) {
+	t.Parallel()
+
+	now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	clock := WithClock(func() time.Time { return now })
+	nodeLog := New(0, clock, WithHalfLife(time.Hour))
+
+	nodeLog.Update(1, 2)
+
+	now = now.Add(time.Hour)
+
+	var dumped bytes.Buffer
+
+	_, err := nodeLog.WriteTo(&dumped)
+	require.NoError(t, err)
+
+	// Without the decay, the loaded counts are the ones as of the dump.
+	raw := New(0)
+	_, err = raw.ReadFrom(bytes.NewReader(dumped.Bytes()))
+	require.NoError(t, err)
+	assert.InDelta(t, 0.5, raw.TotalAccesses, 1e-12)
+
+	// With the decay, the loaded counts keep decaying from the time of the dump.
+	now = now.Add(time.Hour)
+
+	decayed := New(0, clock, WithHalfLife(time.Hour))
+	_, err = decayed.ReadFrom(bytes.NewReader(dumped.Bytes()))
+	require.NoError(t, err)
+	assert.InDelta(t, 0.25, decayed.TotalAccesses*decayed.Scale(), 1e-12)
+}
@@ -0,0 +1,505 @@
+package logmem
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"io"
+	"sort"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// ----------------------------------------------------------------------------
+//  Binary format
+// ----------------------------------------------------------------------------
+//  The records of the NodeLog are dumped in the following binary format. All
+//  the numbers are in little endian.
+//
+//    Header:
+//      [4]byte  Magic  ("BNLG")
+//      uint16   Version
+//    Body:
+//      uint64   Node ID
+//      int64    Time of the decayed counts in Unix nanoseconds. 0 if the
+//               counts are not decayed by the elapsed time.
+//      float64  TotalAccesses
+//      uint64   Number of FromA entries, followed by the entries of:
+//                 uint64 node A, float64 count
+//      uint64   Number of ToB entries, followed by the entries of:
+//                 uint64 node B, float64 count
+//      uint64   Number of FromAToB entries, followed by the entries of:
+//                 uint64 node A, uint64 node B, float64 count
+//      uint64   Number of the updates in the sliding window, oldest first,
+//               followed by the entries of:
+//                 uint64 node A, uint64 node B, float64 weight,
+//                 int64 time of the update in Unix nanoseconds (or 0),
+//                 uint64 length + bytes of the source (or 0)
+//      uint64   Number of the entries of the provenance index, followed by
+//               the entries of:
+//                 uint64 node A, uint64 node B, uint64 length + bytes of the
+//                 source, uint64 number of the updates by the source
+//
+//  The counts are the decayed counts as of the time, not the internal weights.
+//  The entries are sorted by the node IDs (and the sources), so the same
+//  records always produce the same output.
+// ============================================================================
+
+// DumpVersion is the current version of the binary format written by WriteTo.
+const DumpVersion = uint16(1)
+
+// dumpMagic is the magic bytes of the binary format.
+var dumpMagic = [4]byte{'B', 'N', 'L', 'G'}
+
+// ----------------------------------------------------------------------------
+//  Methods
+// ----------------------------------------------------------------------------
+
+// ReadFrom replaces the records of the NodeLog, including the node ID, with the
+// ones read from r in the binary format written by WriteTo. It implements the
+// io.ReaderFrom interface.
+//
+// It reads exactly the bytes of the dump, so the reader can be shared with other
+// data following the dump. On error, the records are left unchanged.
+//
+// With the time-decay enabled, the loaded counts keep decaying from the time
+// they were dumped. With the sliding window enabled, the updates in the dumped
+// window are restored and expire as usual. The counts dumped without the window
+// never expire. With the provenance enabled, the dumped index and the sources
+// of the updates in the window are restored, so the sources can still be
+// untrained and expire along with their updates.
+func (n *NodeLog) ReadFrom(r io.Reader) (int64, error) {
+	reader := &countReader{reader: r}
+	loaded := New(0)
+
+	var (
+		magic   [4]byte
+		version uint16
+	)
+
+	if err := reader.read(&magic, &version); err != nil {
+		return reader.size, errors.Wrap(err, "failed to read the header")
+	}
+
+	if magic != dumpMagic {
+		return reader.size, errors.New("failed to read the header. Invalid magic bytes")
+	}
+
+	if version != DumpVersion {
+		return reader.size, errors.Errorf("failed to read the header. Unsupported version: %d", version)
+	}
+
+	decayedAt, err := loaded.readBody(reader)
+	if err != nil {
+		return reader.size, errors.Wrap(err, "failed to read the records")
+	}
+
+	entries, err := reader.readWindow()
+	if err != nil {
+		return reader.size, errors.Wrap(err, "failed to read the records of the sliding window")
+	}
+
+	sources := make(provenance)
+
+	if err = reader.readProvenance(sources); err != nil {
+		return reader.size, errors.Wrap(err, "failed to read the provenance index")
+	}
+
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	n.nodeID = loaded.nodeID
+	n.TotalAccesses = loaded.TotalAccesses
+	n.FromA = loaded.FromA
+	n.ToB = loaded.ToB
+	n.FromAToB = loaded.FromAToB
+	n.summary = nil
+
+	if n.provenance != nil {
+		n.provenance = sources
+	}
+
+	if n.decay != nil {
+		// The loaded counts are the decayed counts as of decayedAt.
+		n.decay.reference = n.clock()
+		n.decay.updates = 0
+
+		if decayedAt != 0 {
+			n.decay.reference = time.Unix(0, decayedAt)
+		}
+	}
+
+	if n.window != nil {
+		n.window.entries = entries
+		n.window.head = 0
+
+		n.evict()
+	}
+
+	return reader.size, nil
+}
+
+// WriteTo writes the records of the NodeLog, including the node ID, to w in a
+// versioned binary format. It implements the io.WriterTo interface.
+//
+// Use ReadFrom to restore the records.
+func (n *NodeLog) WriteTo(w io.Writer) (int64, error) {
+	n.mu.RLock()
+	defer n.mu.RUnlock()
+
+	buf := bufio.NewWriter(w)
+	writer := &countWriter{writer: buf, scale: n.scale()}
+	decayedAt := int64(0)
+
+	if n.decay != nil && n.decay.halfLife > 0 {
+		decayedAt = n.clock().UnixNano()
+	}
+
+	err := writer.write(dumpMagic, DumpVersion, n.nodeID, decayedAt, n.TotalAccesses*writer.scale)
+	if err == nil {
+		err = writer.writeCounts(n.FromA)
+	}
+
+	if err == nil {
+		err = writer.writeCounts(n.ToB)
+	}
+
+	if err == nil {
+		err = writer.writeTransitions(n.FromAToB)
+	}
+
+	if err == nil {
+		err = writer.writeWindow(n.window)
+	}
+
+	if err == nil {
+		err = writer.writeProvenance(n.provenance)
+	}
+
+	if err == nil {
+		err = buf.Flush()
+	}
+
+	return writer.size, errors.Wrap(err, "failed to write the records")
+}
+
+// ----------------------------------------------------------------------------
+//  Private methods
+// ----------------------------------------------------------------------------
+
+// readBody reads the counts of the body and returns the time of the decayed
+// counts in Unix nanoseconds.
+func (n *NodeLog) readBody(reader *countReader) (int64, error) {
+	var decayedAt int64
+
+	if err := reader.read(&n.nodeID, &decayedAt, &n.TotalAccesses); err != nil {
+		return 0, err
+	}
+
+	if err := reader.readCounts(n.FromA); err != nil {
+		return 0, err
+	}
+
+	if err := reader.readCounts(n.ToB); err != nil {
+		return 0, err
+	}
+
+	var lenEntries uint64
+
+	if err := reader.read(&lenEntries); err != nil {
+		return 0, err
+	}
+
+	for i := uint64(0); i < lenEntries; i++ {
+		var (
+			nodeA, nodeB uint64
+			count        float64
+		)
+
+		if err := reader.read(&nodeA, &nodeB, &count); err != nil {
+			return 0, err
+		}
+
+		if _, ok := n.FromAToB[nodeA]; !ok {
+			n.FromAToB[nodeA] = make(map[uint64]float64)
+		}
+
+		n.FromAToB[nodeA][nodeB] = count
+	}
+
+	return decayedAt, nil
+}
+
+// ----------------------------------------------------------------------------
+//  Type: countReader (private)
+// ----------------------------------------------------------------------------
+
+// countReader reads the binary data and counts the number of bytes read.
+type countReader struct {
+	reader io.Reader
+	size   int64
+}
+
+func (c *countReader) Read(p []byte) (int, error) {
+	n, err := c.reader.Read(p)
+	c.size += int64(n)
+
+	return n, err //nolint:wrapcheck // must return the original error such as io.EOF
+}
+
+func (c *countReader) read(data ...any) error {
+	for _, d := range data {
+		if err := binary.Read(c, binary.LittleEndian, d); err != nil {
+			return errors.Wrap(err, "failed to read the data")
+		}
+	}
+
+	return nil
+}
+
+func (c *countReader) readCounts(counts map[uint64]float64) error {
+	var lenEntries uint64
+
+	if err := c.read(&lenEntries); err != nil {
+		return err
+	}
+
+	for i := uint64(0); i < lenEntries; i++ {
+		var (
+			node  uint64
+			count float64
+		)
+
+		if err := c.read(&node, &count); err != nil {
+			return err
+		}
+
+		counts[node] = count
+	}
+
+	return nil
+}
+
+func (c *countReader) readProvenance(sources provenance) error {
+	var lenEntries uint64
+
+	if err := c.read(&lenEntries); err != nil {
+		return err
+	}
+
+	for i := uint64(0); i < lenEntries; i++ {
+		var nodeA, nodeB, updates uint64
+
+		if err := c.read(&nodeA, &nodeB); err != nil {
+			return err
+		}
+
+		source, err := c.readSource()
+		if err != nil {
+			return err
+		}
+
+		if err := c.read(&updates); err != nil {
+			return err
+		}
+
+		if _, ok := sources[nodeA]; !ok {
+			sources[nodeA] = make(map[uint64]map[string]int)
+		}
+
+		if _, ok := sources[nodeA][nodeB]; !ok {
+			sources[nodeA][nodeB] = make(map[string]int)
+		}
+
+		sources[nodeA][nodeB][source] = int(updates) // #nosec
+	}
+
+	return nil
+}
+
+// readSource reads a length-prefixed source.
+func (c *countReader) readSource() (string, error) {
+	var lenSource uint64
+
+	if err := c.read(&lenSource); err != nil {
+		return "", err
+	}
+
+	// Read through the buffer instead of allocating lenSource bytes
+	// beforehand, so that a broken length does not allocate a huge memory.
+	var source bytes.Buffer
+
+	if _, err := io.CopyN(&source, c, int64(lenSource)); err != nil { // #nosec
+		return "", errors.Wrap(err, "failed to read the source")
+	}
+
+	return source.String(), nil
+}
+
+func (c *countReader) readWindow() ([]windowEntry, error) {
+	var lenEntries uint64
+
+	if err := c.read(&lenEntries); err != nil {
+		return nil, err
+	}
+
+	entries := []windowEntry{}
+
+	for i := uint64(0); i < lenEntries; i++ {
+		var entry windowEntry
+
+		if err := c.read(&entry.fromA, &entry.toB, &entry.weight, &entry.at); err != nil {
+			return nil, err
+		}
+
+		source, err := c.readSource()
+		if err != nil {
+			return nil, err
+		}
+
+		entry.source = source
+
+		entries = append(entries, entry)
+	}
+
+	return entries, nil
+}
+
+// ----------------------------------------------------------------------------
+//  Type: countWriter (private)
+// ----------------------------------------------------------------------------
+
+// countWriter writes the binary data and counts the number of bytes written.
+// The counts are written multiplied by the scale.
+type countWriter struct {
+	writer io.Writer
+	size   int64
+	scale  float64
+}
+
+func (c *countWriter) Write(p []byte) (int, error) {
+	n, err := c.writer.Write(p)
+	c.size += int64(n)
+
+	return n, err //nolint:wrapcheck // the error is wrapped by the caller
+}
+
+func (c *countWriter) write(data ...any) error {
+	for _, d := range data {
+		if err := binary.Write(c, binary.LittleEndian, d); err != nil {
+			return errors.Wrap(err, "failed to write the data")
+		}
+	}
+
+	return nil
+}
+
+func (c *countWriter) writeCounts(counts map[uint64]float64) error {
+	if err := c.write(uint64(len(counts))); err != nil {
+		return err
+	}
+
+	for _, node := range sortedKeys(counts) {
+		if err := c.write(node, counts[node]*c.scale); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (c *countWriter) writeProvenance(sources provenance) error {
+	lenEntries := 0
+
+	for _, toB := range sources {
+		for _, bySource := range toB {
+			lenEntries += len(bySource)
+		}
+	}
+
+	if err := c.write(uint64(lenEntries)); err != nil {
+		return err
+	}
+
+	for _, nodeA := range sortedKeys(sources) {
+		for _, nodeB := range sortedKeys(sources[nodeA]) {
+			bySource := sources[nodeA][nodeB]
+			names := make([]string, 0, len(bySource))
+
+			for source := range bySource {
+				names = append(names, source)
+			}
+
+			sort.Strings(names)
+
+			for _, source := range names {
+				err := c.write(nodeA, nodeB, uint64(len(source)), []byte(source), uint64(bySource[source]))
+				if err != nil {
+					return err
+				}
+			}
+		}
+	}
+
+	return nil
+}
+
+func (c *countWriter) writeTransitions(transitions map[uint64]map[uint64]float64) error {
+	lenEntries := 0
+
+	for _, toB := range transitions {
+		lenEntries += len(toB)
+	}
+
+	if err := c.write(uint64(lenEntries)); err != nil {
+		return err
+	}
+
+	for _, nodeA := range sortedKeys(transitions) {
+		for _, nodeB := range sortedKeys(transitions[nodeA]) {
+			if err := c.write(nodeA, nodeB, transitions[nodeA][nodeB]*c.scale); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+func (c *countWriter) writeWindow(win *window) error {
+	if win == nil {
+		return c.write(uint64(0))
+	}
+
+	if err := c.write(uint64(win.len())); err != nil {
+		return err
+	}
+
+	for _, entry := range win.entries[win.head:] {
+		err := c.write(entry.fromA, entry.toB, entry.weight*c.scale, entry.at,
+			uint64(len(entry.source)), []byte(entry.source))
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// ----------------------------------------------------------------------------
+//  Private functions
+// ----------------------------------------------------------------------------
+
+// sortedKeys returns the keys of the given map in ascending order.
+func sortedKeys[V any](m map[uint64]V) []uint64 {
+	keys := make([]uint64, 0, len(m))
+
+	for key := range m {
+		keys = append(keys, key)
+	}
+
+	sort.Slice(keys, func(i, j int) bool { return keys[i] < keys[j] })
+
+	return keys
+}
@@ -0,0 +1,77 @@
+package logmem
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNodeLog_ReadFrom_broken_data(t *testing.T) {
+	t.Parallel()
+
+	nodeLog := New(12345)
+
+	nodeLog.Update(1, 2)
+	nodeLog.Update(2, 1)
+
+	var dumped bytes.Buffer
+
+	size, err := nodeLog.WriteTo(&dumped)
+	require.NoError(t, err)
+	require.Equal(t, int64(dumped.Len()), size)
+
+	data := dumped.Bytes()
+
+	//nolint:varnamelen // tt is short but descriptive
+	for _, tt := range []struct {
+		data   []byte
+		expect string
+	}{
+		{nil, "failed to read the header"},
+		{[]byte("XXXX\x01\x00"), "Invalid magic bytes"},
+		{[]byte("BNLG\xff\x00"), "Unsupported version: 255"},
+		{data[:len(data)-8-8-1], "failed to read the records"},
+		{data[:len(data)-8-1], "failed to read the records of the sliding window"},
+		{data[:len(data)-1], "failed to read the provenance index"},
+	} {
+		restored := New(1)
+
+		_, err := restored.ReadFrom(bytes.NewReader(tt.data))
+
+		require.Error(t, err, "broken data should be an error")
+		assert.Contains(t, err.Error(), tt.expect)
+
+		// On error, the records must be left unchanged
+		assert.Equal(t, uint64(1), restored.ID())
+		assert.Zero(t, restored.TotalAccesses)
+	}
+}
+
+func TestNodeLog_WriteTo_deterministic(t *testing.T) {
+	t.Parallel()
+
+	nodeLog := New(12345)
+
+	for i := uint64(0); i < 100; i++ {
+		nodeLog.Update(i%7, i%11)
+	}
+
+	var first, second bytes.Buffer
+
+	_, err := nodeLog.WriteTo(&first)
+	require.NoError(t, err)
+
+	_, err = nodeLog.WriteTo(&second)
+	require.NoError(t, err)
+
+	require.Equal(t, first.Bytes(), second.Bytes(), "same records should produce the same output")
+
+	restored := New(0)
+
+	size, err := restored.ReadFrom(&first)
+	require.NoError(t, err)
+	require.Equal(t, int64(second.Len()), size)
+	require.Equal(t, nodeLog, restored)
+}
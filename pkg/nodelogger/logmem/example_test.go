@@ -2,7 +2,9 @@
 package logmem_test
 
 import (
+	"bytes"
 	"fmt"
+	"log"
 
 	"github.com/KEINOS/go-bayes/pkg/nodelogger/logmem"
 )
@@ -159,3 +161,118 @@ func ExampleNodeLog_Update() {
 	// Number of outgoing node x: 0
 	// Number of outgoing node z: 1
 }
+
+func ExampleNodeLog_WriteTo() {
+	const (
+		x = uint64(1)
+		y = uint64(2)
+		z = uint64(3)
+	)
+
+	nodeY := logmem.New(y)
+
+	nodeY.Update(x, z)
+	nodeY.Update(z, x)
+
+	// Dump the records. Use os.File instead of bytes.Buffer to save it to a file.
+	var dumped bytes.Buffer
+
+	if _, err := nodeY.WriteTo(&dumped); err != nil {
+		log.Fatal(err)
+	}
+
+	// Restore the records to a new NodeLog. The node ID is also restored.
+	restored := logmem.New(0)
+
+	if _, err := restored.ReadFrom(&dumped); err != nil {
+		log.Fatal(err)
+	}
+
+	fmt.Println("Node ID:", restored.ID())
+	fmt.Println("Total access:", restored.TotalAccesses)
+	fmt.Println("Prediction of outgoing node to be z, on incoming node as x:", restored.Predict(x, z))
+
+	// Output:
+	// Node ID: 2
+	// Total access: 2
+	// Prediction of outgoing node to be z, on incoming node as x: 1
+}
+
+func ExampleWithSmoothing() {
+	const (
+		x = uint64(1)
+		y = uint64(2)
+		z = uint64(3)
+	)
+
+	raw := logmem.New(y)
+	smoothed := logmem.New(y, logmem.WithSmoothing(logmem.Laplace()))
+
+	for _, nodeLog := range []*logmem.NodeLog{raw, smoothed} {
+		nodeLog.Update(x, z)
+		nodeLog.Update(x, z)
+		nodeLog.Update(z, x)
+	}
+
+	// Node x has never followed node x. Without smoothing, the transition gets
+	// the probability of exactly 0.
+	fmt.Printf("Raw:      x->x %.2f, x->z %.2f\n", raw.Predict(x, x), raw.Predict(x, z))
+	fmt.Printf("Smoothed: x->x %.2f, x->z %.2f\n", smoothed.Predict(x, x), smoothed.Predict(x, z))
+
+	// Output:
+	// Raw:      x->x 0.00, x->z 1.00
+	// Smoothed: x->x 0.14, x->z 0.86
+}
+
+func ExampleWithHalfLifeUpdates() {
+	const (
+		home    = uint64(1)
+		oldPage = uint64(2)
+		newPage = uint64(3)
+	)
+
+	// The weight of an access halves every 2 updates.
+	nodeLog := logmem.New(0, logmem.WithHalfLifeUpdates(2))
+
+	for i := 0; i < 3; i++ {
+		nodeLog.Update(home, oldPage)
+	}
+
+	nodeLog.Update(home, newPage)
+	nodeLog.Update(home, newPage)
+
+	fmt.Printf("old page: %.2f\n", nodeLog.PriorPfromAtoB(home, oldPage))
+	fmt.Printf("new page: %.2f\n", nodeLog.PriorPfromAtoB(home, newPage))
+
+	// Decayed counts as of now.
+	fmt.Printf("total: %.2f\n", nodeLog.TotalAccesses*nodeLog.Scale())
+
+	// Output:
+	// old page: 0.39
+	// new page: 0.61
+	// total: 2.81
+}
+
+func ExampleWithWindow() {
+	const (
+		x = uint64(1)
+		y = uint64(2)
+		z = uint64(3)
+	)
+
+	// Only the last 2 updates count.
+	nodeLog := logmem.New(0, logmem.WithWindow(2))
+
+	nodeLog.Update(x, y)
+	nodeLog.Update(x, y)
+	nodeLog.Update(x, z) // the first update falls out of the window
+
+	fmt.Println("Total access:", nodeLog.TotalAccesses)
+	fmt.Println("x -> y:", nodeLog.FromAToB[x][y])
+	fmt.Println("x -> z:", nodeLog.FromAToB[x][z])
+
+	// Output:
+	// Total access: 2
+	// x -> y: 1
+	// x -> z: 1
+}
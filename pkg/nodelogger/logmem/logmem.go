@@ -4,7 +4,10 @@ Package logmem is an implementation of bayes.NodeLogger for memory-based logging
 package logmem
 
 import (
+	"math"
 	"strconv"
+	"sync"
+	"time"
 
 	"github.com/KEINOS/go-bayes/pkg/theorem"
 )
@@ -16,19 +19,41 @@ import (
 // NodeLog holds the records of a node. It is an implementation of bayes.NodeLogger
 // for memory-based logging.
 //
-//nolint:recvcheck // allow pointer receiver for setter method
+// The methods are safe for concurrent use. Reading methods do not block each
+// other. Note that accessing the exported fields directly is not protected.
+//
+// The counts are float64 to support the time-decay. With the decay enabled, the
+// exported fields hold the weights in an internal unit, whose ratios are the
+// same as the decayed counts. Multiply them by `Scale()` to get the decayed
+// counts as of now. See `WithHalfLife()`.
 type NodeLog struct {
 	// FromAtoB is the number of accesses from node A to node B as map[A]map[B].
 	// A is the incoming access and B is the outgoing access.
-	FromAToB map[uint64]map[uint64]int
+	FromAToB map[uint64]map[uint64]float64
 	// FromA is the number of incoming accesses from node A as map[A].
-	FromA map[uint64]int
+	FromA map[uint64]float64
 	// ToB is the number of outgoing accesses to node B as map[B].
-	ToB map[uint64]int
+	ToB map[uint64]float64
+	// decay holds the state of the time-decay. Nil means no decay.
+	decay *decay
+	// now returns the current time. Nil means time.Now.
+	now func() time.Time
+	// provenance holds the sources of the transitions. Nil means disabled.
+	provenance provenance
+	// window holds the updates in the sliding window. Nil means no window.
+	window *window
+	// smoother smooths the conditional probability. Nil means no smoothing.
+	smoother Smoother
+	// summary caches the statistics over all the transitions. Nil means stale.
+	summary *summary
+	// mu protects the records.
+	mu sync.RWMutex
+	// summaryMu protects the summary cache which is built under the read lock.
+	summaryMu sync.Mutex
 	// nodeID is the node ID of the current node.
 	nodeID uint64
 	// TotalAccesses is the total number of accesses to the node.
-	TotalAccesses int
+	TotalAccesses float64
 }
 
 // ----------------------------------------------------------------------------
@@ -36,13 +61,118 @@ type NodeLog struct {
 // ----------------------------------------------------------------------------
 
 // New returns a new NodeLog instance.
-func New(nodeID uint64) *NodeLog {
-	return &NodeLog{
+func New(nodeID uint64, opts ...Option) *NodeLog {
+	nodeLog := &NodeLog{
 		nodeID:        nodeID,
 		TotalAccesses: 0,
-		FromAToB:      make(map[uint64]map[uint64]int),
-		FromA:         make(map[uint64]int),
-		ToB:           make(map[uint64]int),
+		FromAToB:      make(map[uint64]map[uint64]float64),
+		FromA:         make(map[uint64]float64),
+		ToB:           make(map[uint64]float64),
+	}
+
+	for _, opt := range opts {
+		opt(nodeLog)
+	}
+
+	return nodeLog
+}
+
+// ----------------------------------------------------------------------------
+//  Type: Option
+// ----------------------------------------------------------------------------
+
+// Option is a functional option of `New()`.
+type Option func(*NodeLog)
+
+// WithClock sets the function which returns the current time used by the
+// time-decay. Default: time.Now. Mostly for testing.
+func WithClock(now func() time.Time) Option {
+	return func(n *NodeLog) {
+		n.now = now
+	}
+}
+
+// WithHalfLife enables the time-decay of the counts by the elapsed time. The
+// weight of an access halves every halfLife, so the recent accesses dominate
+// the probabilities. Zero or negative disables it. Default: 0 (no decay).
+//
+// It can be combined with `WithHalfLifeUpdates()`, then both decays apply.
+func WithHalfLife(halfLife time.Duration) Option {
+	return func(n *NodeLog) {
+		if halfLife <= 0 {
+			return
+		}
+
+		n.getDecay().halfLife = halfLife
+	}
+}
+
+// WithHalfLifeUpdates enables the time-decay of the counts by the number of
+// updates. The weight of an access halves every numUpdates of the following
+// updates. Zero or negative disables it. Default: 0 (no decay).
+func WithHalfLifeUpdates(numUpdates int) Option {
+	return func(n *NodeLog) {
+		if numUpdates <= 0 {
+			return
+		}
+
+		n.getDecay().halfLifeUpdates = float64(numUpdates)
+	}
+}
+
+// WithProvenance enables the provenance index, which records the sources, such
+// as the document IDs, contributed to each transition via `UpdateFrom()`. Use
+// `Sources()` to query them. Default: disabled.
+//
+// Note that the index costs memory per transition and source. The index is
+// included in the dump of `WriteTo()`.
+func WithProvenance() Option {
+	return func(n *NodeLog) {
+		n.provenance = make(provenance)
+	}
+}
+
+// WithSmoothing sets the smoothing strategy of the conditional probability of
+// the outgoing node B given the incoming node A. Default: nil (no smoothing).
+//
+// Without smoothing, the transitions never seen get the probability of exactly
+// 0. With smoothing, they get a small share of the probability as long as the
+// incoming node A has been seen. Transitions from an unseen node A are still 0
+// so that the caller can back off to the other contexts.
+func WithSmoothing(smoother Smoother) Option {
+	return func(n *NodeLog) {
+		n.smoother = smoother
+	}
+}
+
+// WithWindow enables the sliding window over the last numUpdates updates. Only
+// the updates in the window count toward the records. Once an update falls out
+// of the window, it is subtracted from the counts exactly. Zero or negative
+// disables it. Default: 0 (no window).
+//
+// It can be combined with `WithWindowDuration()` and the time-decay. Note that
+// the window keeps every update in it, which costs memory of the window size.
+func WithWindow(numUpdates int) Option {
+	return func(n *NodeLog) {
+		if numUpdates <= 0 {
+			return
+		}
+
+		n.getWindow().maxUpdates = numUpdates
+	}
+}
+
+// WithWindowDuration enables the sliding window over the last duration. The
+// updates older than the duration are subtracted from the counts exactly on
+// the next update or read, such as `Predict()`, or on `Expire()`. Zero or
+// negative disables it. Default: 0 (no window).
+func WithWindowDuration(duration time.Duration) Option {
+	return func(n *NodeLog) {
+		if duration <= 0 {
+			return
+		}
+
+		n.getWindow().maxAge = duration
 	}
 }
 
@@ -50,72 +180,249 @@ func New(nodeID uint64) *NodeLog {
 //  Methods
 // ----------------------------------------------------------------------------
 
+// Decrement reverses an update from node A to node B made via `Update()`. It
+// does nothing if there is no such update in the records, so the counts never
+// go negative.
+//
+// With the sliding window, the newest matching update in the window is removed,
+// which is exact. Without the window but with the time-decay, the weight of an
+// update made now is subtracted, up to the remaining count of the transition.
+func (n *NodeLog) Decrement(fromA, toB uint64) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	n.decrementFrom("", fromA, toB)
+}
+
 // ID returns the node ID of the current node.
-func (n NodeLog) ID() uint64 {
+func (n *NodeLog) ID() uint64 {
+	n.mu.RLock()
+	defer n.mu.RUnlock()
+
 	return n.nodeID
 }
 
 // Predict returns the probability of the next node to be toNodeB if the incoming
 // node is fromNodeA.
-func (n NodeLog) Predict(fromNodeA, toNodeB uint64) float64 {
+func (n *NodeLog) Predict(fromNodeA, toNodeB uint64) float64 {
+	n.rlockFresh()
+	defer n.mu.RUnlock()
+
 	// Prior probability of the next node to be node B.
-	PriorProbToB := n.PriorPtoB(toNodeB)
+	PriorProbToB := n.priorPtoB(toNodeB)
 	// Prior probability of the incoming node to be node B if the previous node was A.
-	PriorProbFromAtoB := n.PriorPfromAtoB(fromNodeA, toNodeB)
+	PriorProbFromAtoB := n.priorPfromAtoB(fromNodeA, toNodeB)
 	// Prior probability of the incoming node not to be node B if the previous node was A.
-	PriorProbNotFromAtoB := n.PriorPNotFromAtoB(fromNodeA, toNodeB)
+	PriorProbNotFromAtoB := n.priorPNotFromAtoB(fromNodeA, toNodeB)
 
 	return theorem.Bayes(PriorProbToB, PriorProbFromAtoB, PriorProbNotFromAtoB)
 }
 
 // PriorPfromAtoB returns the prior probability of the node to be B if the
 // previous node is A.
-func (n NodeLog) PriorPfromAtoB(fromA, toB uint64) float64 {
-	if n.TotalAccesses == 0 {
-		return 0
-	}
+func (n *NodeLog) PriorPfromAtoB(fromA, toB uint64) float64 {
+	n.rlockFresh()
+	defer n.mu.RUnlock()
 
-	return float64(n.FromAToB[fromA][toB]) / float64(n.TotalAccesses)
+	return n.priorPfromAtoB(fromA, toB)
 }
 
 // PriorPNotFromAtoB returns the prior probability of the node not to be B
 // if the previous node is A.
-func (n NodeLog) PriorPNotFromAtoB(fromA, toB uint64) float64 {
-	if n.TotalAccesses == 0 {
-		return 0
-	}
+func (n *NodeLog) PriorPNotFromAtoB(fromA, toB uint64) float64 {
+	n.rlockFresh()
+	defer n.mu.RUnlock()
 
-	notA := n.FromA[fromA] - n.FromAToB[fromA][toB]
-
-	return float64(notA) / float64(n.TotalAccesses)
+	return n.priorPNotFromAtoB(fromA, toB)
 }
 
 // PriorPtoB returns the prior probability of the outgoing node to be nodeB.
 //
 // Which is the number of outgoing accesses to the node B out of the total number
 // of accesses of current node.
-func (n NodeLog) PriorPtoB(nodeB uint64) float64 {
-	if n.TotalAccesses == 0 {
-		return 0
-	}
+func (n *NodeLog) PriorPtoB(nodeB uint64) float64 {
+	n.rlockFresh()
+	defer n.mu.RUnlock()
 
-	return float64(n.ToB[nodeB]) / float64(n.TotalAccesses)
+	return n.priorPtoB(nodeB)
+}
+
+// Scale returns the factor to convert the values of the exported fields into
+// the decayed counts as of now. It is always 1 without the time-decay.
+func (n *NodeLog) Scale() float64 {
+	n.mu.RLock()
+	defer n.mu.RUnlock()
+
+	return n.scale()
 }
 
 // String returns a string representation of the NodeLog which is the node ID.
-func (n NodeLog) String() string {
-	return strconv.FormatUint(n.nodeID, 10)
+func (n *NodeLog) String() string {
+	return strconv.FormatUint(n.ID(), 10)
 }
 
 // Update updates the records of a node.
 // It must be called by the next node accessed.
 func (n *NodeLog) Update(fromA, toB uint64) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	n.updateFrom("", fromA, toB, 1)
+}
+
+// UpdateN is the same as calling `Update()` n times at once, such as for the
+// pre-aggregated sequences. The count may be fractional. It does nothing if the
+// count is not a positive finite number.
+//
+// It is a single update for the sliding window and the half-life by the number
+// of the updates. So the whole count falls out of the window at once.
+func (n *NodeLog) UpdateN(fromA, toB uint64, count float64) {
+	if count <= 0 || math.IsInf(count, 0) || math.IsNaN(count) {
+		return
+	}
+
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	n.updateFrom("", fromA, toB, count)
+}
+
+// ----------------------------------------------------------------------------
+//  Private methods
+// ----------------------------------------------------------------------------
+//  The private methods do not lock the records. The caller must hold the lock.
+
+// decrementFrom reverses an update. If the source is given, only the update
+// from the source is reversed.
+func (n *NodeLog) decrementFrom(source string, fromA, toB uint64) {
+	count := n.FromAToB[fromA][toB]
+	if count <= 0 {
+		return
+	}
+
+	if n.provenance == nil {
+		source = ""
+	}
+
+	if n.window != nil {
+		if entry, ok := n.window.remove(source, fromA, toB); ok {
+			n.removeSource(entry.source, fromA, toB)
+			n.decrement(fromA, toB, entry.weight)
+		}
+
+		return
+	}
+
+	if source != "" && n.provenance[fromA][toB][source] == 0 {
+		return // the source has not contributed to the transition
+	}
+
+	n.removeSource(source, fromA, toB)
+	n.decrement(fromA, toB, math.Min(math.Exp2(n.exponent()), count))
+}
+
+// updateFrom adds the count to the records. The source is recorded if the
+// provenance is enabled.
+func (n *NodeLog) updateFrom(source string, fromA, toB uint64, count float64) {
+	if n.provenance == nil {
+		source = ""
+	}
+
 	if _, ok := n.FromAToB[fromA]; !ok {
-		n.FromAToB[fromA] = make(map[uint64]int)
+		n.FromAToB[fromA] = make(map[uint64]float64)
+	}
+
+	weight := n.nextWeight() * count
+
+	n.summary = nil
+	n.TotalAccesses += weight
+	n.FromA[fromA] += weight
+	n.ToB[toB] += weight
+	n.FromAToB[fromA][toB] += weight
+
+	n.addSource(source, fromA, toB)
+	n.pushWindow(source, fromA, toB, weight)
+	n.evict()
+}
+
+// decrement subtracts the weight of an update from the counts. The entries
+// reaching 0 are removed, so the removed updates leave no trace.
+func (n *NodeLog) decrement(fromA, toB uint64, weight float64) {
+	n.summary = nil
+
+	subtract := func(counts map[uint64]float64, node uint64) {
+		count := counts[node] - weight
+
+		// The float weights of the time-decay may leave a tiny error.
+		if count <= weight*1e-9 {
+			delete(counts, node)
+
+			return
+		}
+
+		counts[node] = count
+	}
+
+	subtract(n.FromA, fromA)
+	subtract(n.ToB, toB)
+
+	if toBs, ok := n.FromAToB[fromA]; ok {
+		subtract(toBs, toB)
+
+		if _, ok := toBs[toB]; !ok {
+			n.clearSources(fromA, toB)
+		}
+
+		if len(toBs) == 0 {
+			delete(n.FromAToB, fromA)
+		}
+	}
+
+	n.TotalAccesses -= weight
+	if len(n.FromA) == 0 || n.TotalAccesses < 0 {
+		n.TotalAccesses = 0
+	}
+}
+
+// The smoothed priors split the share of the incoming node A, P(A), into the
+// smoothed P(B|A) and 1-P(B|A). So, the Bayes' theorem gives the same result as
+// the raw counts when the smoothed P(B|A) equals the relative frequency.
+
+func (n *NodeLog) priorPfromAtoB(fromA, toB uint64) float64 {
+	if n.TotalAccesses == 0 {
+		return 0
+	}
+
+	if n.smoother != nil {
+		return n.smoothed(fromA, toB) * n.priorPfromA(fromA)
+	}
+
+	return n.FromAToB[fromA][toB] / n.TotalAccesses
+}
+
+func (n *NodeLog) priorPfromA(fromA uint64) float64 {
+	return n.FromA[fromA] / n.TotalAccesses
+}
+
+func (n *NodeLog) priorPNotFromAtoB(fromA, toB uint64) float64 {
+	if n.TotalAccesses == 0 {
+		return 0
+	}
+
+	if n.smoother != nil {
+		return (1 - n.smoothed(fromA, toB)) * n.priorPfromA(fromA)
+	}
+
+	// The decayed weights may leave a tiny negative error on subtraction.
+	notA := math.Max(n.FromA[fromA]-n.FromAToB[fromA][toB], 0)
+
+	return notA / n.TotalAccesses
+}
+
+func (n *NodeLog) priorPtoB(nodeB uint64) float64 {
+	if n.TotalAccesses == 0 {
+		return 0
 	}
 
-	n.TotalAccesses++
-	n.FromA[fromA]++
-	n.ToB[toB]++
-	n.FromAToB[fromA][toB]++
+	return n.ToB[nodeB] / n.TotalAccesses
 }
@@ -1,6 +1,8 @@
 package logmem
 
 import (
+	"math"
+	"sync"
 	"testing"
 
 	"github.com/stretchr/testify/require"
@@ -17,3 +19,168 @@ func TestNodeLog_zero_division(t *testing.T) {
 	require.InDelta(t, float64(0), nodeLog.PriorPtoB(1), 0)
 	require.NotPanics(t, func() { nodeLog.Update(1, 2) })
 }
+
+func TestNodeLog_concurrent_access(t *testing.T) {
+	t.Parallel()
+
+	const (
+		numWriters = 4
+		numReaders = 4
+		numLoops   = 1000
+	)
+
+	nodeLog := New(12345)
+
+	var wg sync.WaitGroup
+
+	for i := 0; i < numWriters; i++ {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			for j := 0; j < numLoops; j++ {
+				nodeLog.Update(uint64(j%3), uint64(j%5)) // #nosec
+			}
+		}()
+	}
+
+	for i := 0; i < numReaders; i++ {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			for j := 0; j < numLoops; j++ {
+				_ = nodeLog.Predict(uint64(j%3), uint64(j%5)) // #nosec
+				_ = nodeLog.PriorPtoB(uint64(j % 5))          // #nosec
+				_ = nodeLog.String()
+			}
+		}()
+	}
+
+	wg.Wait()
+
+	require.InDelta(t, float64(numWriters*numLoops), nodeLog.TotalAccesses, 0)
+}
+
+func TestNodeLog_Decrement(t *testing.T) {
+	t.Parallel()
+
+	nodeLog := New(0)
+
+	nodeLog.Update(1, 2)
+	nodeLog.Update(1, 2)
+	nodeLog.Update(3, 4)
+
+	nodeLog.Decrement(1, 2)
+	nodeLog.Decrement(5, 6) // never updated
+	nodeLog.Decrement(3, 2) // never updated
+
+	expect := New(0)
+
+	expect.Update(1, 2)
+	expect.Update(3, 4)
+
+	require.Equal(t, expect, nodeLog)
+
+	nodeLog.Decrement(1, 2)
+	nodeLog.Decrement(1, 2) // already removed
+	nodeLog.Decrement(3, 4)
+
+	require.Equal(t, New(0), nodeLog, "all the records should be removed without trace")
+}
+
+func TestNodeLog_Decrement_with_decay(t *testing.T) {
+	t.Parallel()
+
+	nodeLog := New(0, WithHalfLifeUpdates(1))
+
+	nodeLog.Update(1, 2)
+	nodeLog.Update(1, 3)
+	nodeLog.Update(1, 3)
+
+	// The weight of an update made now is larger than the old update of 1->2.
+	nodeLog.Decrement(1, 2)
+
+	require.NotContains(t, nodeLog.FromAToB[1], uint64(2), "it should be subtracted up to the remaining count")
+	require.InDelta(t, nodeLog.FromA[1], nodeLog.FromAToB[1][3], 1e-9, "counts should stay consistent")
+	require.InDelta(t, nodeLog.TotalAccesses, nodeLog.FromAToB[1][3], 1e-9, "counts should stay consistent")
+}
+
+func TestNodeLog_Decrement_with_window(t *testing.T) {
+	t.Parallel()
+
+	nodeLog := New(0, WithWindow(2))
+
+	nodeLog.Update(1, 2)
+	nodeLog.Update(1, 2)
+	nodeLog.Update(3, 4) // 1->2 of the first update falls out of the window
+
+	nodeLog.Decrement(1, 2)
+
+	require.Equal(t, 1, nodeLog.WindowLen())
+	require.NotContains(t, nodeLog.FromA, uint64(1))
+
+	nodeLog.Update(5, 6)
+	nodeLog.Update(5, 6) // 3->4 falls out of the window
+
+	require.NotContains(t, nodeLog.FromA, uint64(3))
+	require.InDelta(t, 2.0, nodeLog.TotalAccesses, 0, "removed update should not be subtracted twice")
+}
+
+func TestNodeLog_UpdateN(t *testing.T) {
+	t.Parallel()
+
+	nodeLog := New(0)
+
+	nodeLog.UpdateN(1, 2, 3)
+	nodeLog.UpdateN(3, 4, 1)
+
+	// Invalid counts should be ignored
+	nodeLog.UpdateN(1, 2, 0)
+	nodeLog.UpdateN(1, 2, -1)
+	nodeLog.UpdateN(1, 2, math.NaN())
+	nodeLog.UpdateN(1, 2, math.Inf(1))
+
+	expect := New(0)
+
+	for i := 0; i < 3; i++ {
+		expect.Update(1, 2)
+	}
+
+	expect.Update(3, 4)
+
+	require.Equal(t, expect, nodeLog, "it should be the same as updating n times")
+}
+
+func TestNodeLog_UpdateN_fractional(t *testing.T) {
+	t.Parallel()
+
+	nodeLog := New(0)
+
+	nodeLog.UpdateN(1, 2, 1.5)
+	nodeLog.UpdateN(1, 3, 0.5)
+
+	require.InDelta(t, 2.0, nodeLog.TotalAccesses, 0)
+	require.InDelta(t, 0.75, nodeLog.PriorPfromAtoB(1, 2), 1e-9)
+	require.InDelta(t, 0.25, nodeLog.PriorPtoB(3), 1e-9)
+
+	// Decrement should subtract only the remaining count below 1
+	nodeLog.Decrement(1, 3)
+
+	require.NotContains(t, nodeLog.ToB, uint64(3))
+	require.InDelta(t, 1.5, nodeLog.TotalAccesses, 1e-9)
+}
+
+func TestNodeLog_UpdateN_with_window(t *testing.T) {
+	t.Parallel()
+
+	nodeLog := New(0, WithWindow(1))
+
+	nodeLog.UpdateN(1, 2, 5)
+	nodeLog.Update(3, 4) // the whole count of 1->2 falls out of the window
+
+	require.NotContains(t, nodeLog.FromA, uint64(1))
+	require.InDelta(t, 1.0, nodeLog.TotalAccesses, 0)
+}
@@ -0,0 +1,118 @@
+package logmem
+
+import "sort"
+
+// ----------------------------------------------------------------------------
+//  Provenance
+// ----------------------------------------------------------------------------
+//  The provenance index holds the number of updates of each transition by the
+//  source as map[A]map[B]map[source]. The sources are removed along with their
+//  updates, so the index only holds the sources of the records in the model.
+// ============================================================================
+
+// provenance is the index of the sources of the transitions.
+type provenance map[uint64]map[uint64]map[string]int
+
+// ----------------------------------------------------------------------------
+//  Methods
+// ----------------------------------------------------------------------------
+
+// DecrementFrom reverses an update from node A to node B made via
+// `UpdateFrom()` with the same source. It does nothing if the source has not
+// contributed to the transition. Without the provenance, it is the same as
+// `Decrement()`.
+func (n *NodeLog) DecrementFrom(source string, fromA, toB uint64) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	n.decrementFrom(source, fromA, toB)
+}
+
+// Sources returns the sources contributed to the transition from node A to
+// node B in ascending order. It returns nil without the provenance. See
+// `WithProvenance()`.
+//
+// Note that `Decrement()` without the source can not tell which source to
+// remove, unless the sliding window is enabled. The sources are cleared once
+// the transition is removed from the records.
+func (n *NodeLog) Sources(fromA, toB uint64) []string {
+	n.rlockFresh()
+	defer n.mu.RUnlock()
+
+	bySource := n.provenance[fromA][toB]
+	if len(bySource) == 0 {
+		return nil
+	}
+
+	sources := make([]string, 0, len(bySource))
+
+	for source := range bySource {
+		sources = append(sources, source)
+	}
+
+	sort.Strings(sources)
+
+	return sources
+}
+
+// UpdateFrom is the same as `Update()` but also records the source of the
+// update, such as the ID of the training document, if the provenance is
+// enabled. An empty source is not recorded.
+func (n *NodeLog) UpdateFrom(source string, fromA, toB uint64) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	n.updateFrom(source, fromA, toB, 1)
+}
+
+// ----------------------------------------------------------------------------
+//  Private methods
+// ----------------------------------------------------------------------------
+
+// addSource records an update of the transition by the source.
+func (n *NodeLog) addSource(source string, fromA, toB uint64) {
+	if n.provenance == nil || source == "" {
+		return
+	}
+
+	if _, ok := n.provenance[fromA]; !ok {
+		n.provenance[fromA] = make(map[uint64]map[string]int)
+	}
+
+	if _, ok := n.provenance[fromA][toB]; !ok {
+		n.provenance[fromA][toB] = make(map[string]int)
+	}
+
+	n.provenance[fromA][toB][source]++
+}
+
+// clearSources removes all the sources of the transition.
+func (n *NodeLog) clearSources(fromA, toB uint64) {
+	if n.provenance == nil {
+		return
+	}
+
+	delete(n.provenance[fromA], toB)
+
+	if len(n.provenance[fromA]) == 0 {
+		delete(n.provenance, fromA)
+	}
+}
+
+// removeSource removes an update of the transition by the source.
+func (n *NodeLog) removeSource(source string, fromA, toB uint64) {
+	bySource := n.provenance[fromA][toB]
+	if bySource == nil || source == "" {
+		return
+	}
+
+	bySource[source]--
+
+	if bySource[source] <= 0 {
+		delete(bySource, source)
+	}
+
+	if len(bySource) == 0 {
+		n.clearSources(fromA, toB)
+	}
+}
@@ -0,0 +1,168 @@
+package logmem
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWithProvenance(t *testing.T) {
+	t.Parallel()
+
+	nodeLog := New(0, WithProvenance())
+
+	nodeLog.UpdateFrom("doc-2", 1, 2)
+	nodeLog.UpdateFrom("doc-1", 1, 2)
+	nodeLog.UpdateFrom("doc-1", 1, 2)
+	nodeLog.UpdateFrom("doc-3", 1, 3)
+	nodeLog.UpdateFrom("", 1, 3) // not recorded
+	nodeLog.Update(1, 3)         // not recorded
+
+	assert.Equal(t, []string{"doc-1", "doc-2"}, nodeLog.Sources(1, 2))
+	assert.Equal(t, []string{"doc-3"}, nodeLog.Sources(1, 3))
+	assert.Nil(t, nodeLog.Sources(2, 1))
+
+	// Not contributed
+	nodeLog.DecrementFrom("doc-3", 1, 2)
+
+	assert.InDelta(t, 3.0, nodeLog.FromAToB[1][2], 0, "update of the other sources should be kept")
+
+	nodeLog.DecrementFrom("doc-1", 1, 2)
+
+	assert.Equal(t, []string{"doc-1", "doc-2"}, nodeLog.Sources(1, 2), "doc-1 still has an update")
+
+	nodeLog.DecrementFrom("doc-1", 1, 2)
+
+	assert.Equal(t, []string{"doc-2"}, nodeLog.Sources(1, 2))
+	assert.InDelta(t, 1.0, nodeLog.FromAToB[1][2], 0)
+
+	// Decrement without the source clears the sources once the transition is
+	// removed.
+	nodeLog.Decrement(1, 2)
+
+	assert.Nil(t, nodeLog.Sources(1, 2))
+	assert.NotContains(t, nodeLog.provenance[1], uint64(2))
+
+	nodeLog.DecrementFrom("doc-3", 1, 3)
+	nodeLog.Decrement(1, 3)
+	nodeLog.Decrement(1, 3)
+
+	assert.Empty(t, nodeLog.provenance, "index should be empty without records")
+}
+
+func TestWithProvenance_disabled(t *testing.T) {
+	t.Parallel()
+
+	nodeLog := New(0)
+
+	nodeLog.UpdateFrom("doc-1", 1, 2)
+	nodeLog.UpdateFrom("doc-2", 1, 2)
+
+	assert.Nil(t, nodeLog.Sources(1, 2))
+
+	// Same as Decrement without the provenance
+	nodeLog.DecrementFrom("doc-3", 1, 2)
+
+	assert.InDelta(t, 1.0, nodeLog.TotalAccesses, 0)
+}
+
+func TestWithProvenance_window(t *testing.T) {
+	t.Parallel()
+
+	nodeLog := New(0, WithProvenance(), WithWindow(2))
+
+	nodeLog.UpdateFrom("doc-1", 1, 2)
+	nodeLog.UpdateFrom("doc-2", 1, 2)
+
+	// Without the source, the newest update is removed with its source.
+	nodeLog.Decrement(1, 2)
+
+	assert.Equal(t, []string{"doc-1"}, nodeLog.Sources(1, 2))
+
+	nodeLog.UpdateFrom("doc-2", 1, 2)
+	nodeLog.UpdateFrom("doc-3", 1, 2) // doc-1 falls out of the window
+
+	assert.Equal(t, []string{"doc-2", "doc-3"}, nodeLog.Sources(1, 2))
+
+	nodeLog.DecrementFrom("doc-3", 1, 2)
+	nodeLog.DecrementFrom("doc-1", 1, 2) // already out of the window
+
+	assert.Equal(t, []string{"doc-2"}, nodeLog.Sources(1, 2))
+	assert.Equal(t, 1, nodeLog.WindowLen())
+}
+
+func TestWithProvenance_dump(t *testing.T) {
+	t.Parallel()
+
+	nodeLog := New(0, WithProvenance())
+
+	nodeLog.UpdateFrom("doc-2", 1, 2)
+	nodeLog.UpdateFrom("doc-1", 1, 2)
+	nodeLog.UpdateFrom("doc-1", 1, 2)
+	nodeLog.UpdateFrom("doc-1", 3, 4)
+
+	var dumped bytes.Buffer
+
+	_, err := nodeLog.WriteTo(&dumped)
+	require.NoError(t, err)
+
+	restored := New(0, WithProvenance())
+
+	size, err := restored.ReadFrom(bytes.NewReader(dumped.Bytes()))
+	require.NoError(t, err)
+	assert.Equal(t, int64(dumped.Len()), size)
+
+	assert.Equal(t, nodeLog.provenance, restored.provenance, "provenance should be restored on load")
+
+	// The restored sources can be untrained
+	restored.DecrementFrom("doc-1", 1, 2)
+	restored.DecrementFrom("doc-1", 1, 2)
+
+	assert.Equal(t, []string{"doc-2"}, restored.Sources(1, 2))
+	assert.InDelta(t, 1.0, restored.FromAToB[1][2], 0)
+
+	// Without the provenance, the dumped index is ignored
+	disabled := New(0)
+
+	_, err = disabled.ReadFrom(bytes.NewReader(dumped.Bytes()))
+	require.NoError(t, err)
+	assert.Nil(t, disabled.Sources(1, 2))
+	assert.InDelta(t, 3.0, disabled.FromAToB[1][2], 0)
+}
+
+func TestWithProvenance_dump_window(t *testing.T) {
+	t.Parallel()
+
+	nodeLog := New(0, WithProvenance(), WithWindow(2))
+
+	nodeLog.UpdateFrom("doc-1", 1, 2)
+	nodeLog.UpdateFrom("doc-2", 3, 4)
+
+	var dumped bytes.Buffer
+
+	_, err := nodeLog.WriteTo(&dumped)
+	require.NoError(t, err)
+
+	restored := New(0, WithProvenance(), WithWindow(2))
+
+	size, err := restored.ReadFrom(bytes.NewReader(dumped.Bytes()))
+	require.NoError(t, err)
+	assert.Equal(t, int64(dumped.Len()), size)
+	assert.Equal(t, nodeLog.window, restored.window, "sources in the window should be restored on load")
+
+	// The restored source is untrained along with its update in the window
+	restored.DecrementFrom("doc-2", 3, 4)
+
+	assert.Nil(t, restored.Sources(3, 4))
+	assert.Zero(t, restored.FromAToB[3][4])
+	assert.Equal(t, 1, restored.WindowLen())
+
+	// The restored source is removed once its update falls out of the window
+	restored.UpdateFrom("doc-3", 5, 6)
+	restored.UpdateFrom("doc-3", 5, 6)
+
+	assert.Nil(t, restored.Sources(1, 2))
+	assert.Equal(t, []string{"doc-3"}, restored.Sources(5, 6))
+}
@@ -0,0 +1,331 @@
+package logmem
+
+import "math"
+
+// ----------------------------------------------------------------------------
+//  Type: Smoother
+// ----------------------------------------------------------------------------
+
+// Smoother is a smoothing strategy which estimates the conditional probability
+// of the outgoing node B given the incoming node A, P(B|A), from the records.
+//
+// Smooth is called only if the incoming node A has been seen. The stats are
+// valid only during the call, while the records are locked for reading.
+type Smoother interface {
+	Smooth(stats Stats, fromA, toB uint64) float64
+}
+
+// Stats is a read-only view of the records of a NodeLog given to the Smoother.
+type Stats interface {
+	// Count returns the number of transitions from node A to node B, c(A,B).
+	Count(fromA, toB uint64) float64
+	// CountFrom returns the number of transitions from node A, c(A).
+	CountFrom(fromA uint64) float64
+	// CountTo returns the number of transitions to node B, c(B).
+	CountTo(toB uint64) float64
+	// CountOfCounts returns the number of distinct transitions seen exactly r
+	// times, N_r.
+	CountOfCounts(r int) int
+	// Followers returns the number of distinct nodes that followed node A.
+	Followers(fromA uint64) int
+	// ForEachFollower calls fn for each node that followed node A with the
+	// number of the transitions.
+	ForEachFollower(fromA uint64, fn func(toB uint64, count float64))
+	// Predecessors returns the number of distinct nodes that preceded node B.
+	Predecessors(toB uint64) int
+	// Total returns the total number of transitions.
+	Total() float64
+	// Transitions returns the number of distinct transitions.
+	Transitions() int
+	// Vocabulary returns the number of distinct outgoing nodes.
+	Vocabulary() int
+}
+
+// ----------------------------------------------------------------------------
+//  Type: Additive
+// ----------------------------------------------------------------------------
+
+// Additive is the additive (Lidstone) smoothing, which adds Alpha to the count
+// of every outgoing node.
+//
+//	P(B|A) = (c(A,B) + Alpha) / (c(A) + Alpha * V)
+//
+// Where V is the number of distinct outgoing nodes. Alpha of 1 is the Laplace
+// smoothing. See `Laplace()`.
+type Additive struct {
+	Alpha float64
+}
+
+// Laplace returns the additive smoothing with Alpha of 1, also known as the
+// add-one smoothing.
+func Laplace() Additive {
+	return Additive{Alpha: 1}
+}
+
+// Smooth implements the Smoother interface.
+func (a Additive) Smooth(stats Stats, fromA, toB uint64) float64 {
+	denominator := stats.CountFrom(fromA) + a.Alpha*float64(stats.Vocabulary())
+	if denominator == 0 {
+		return 0
+	}
+
+	return (stats.Count(fromA, toB) + a.Alpha) / denominator
+}
+
+// ----------------------------------------------------------------------------
+//  Type: GoodTuring
+// ----------------------------------------------------------------------------
+
+// GoodTuringThreshold is the default count above which GoodTuring trusts the
+// raw counts as reliable.
+const GoodTuringThreshold = 5
+
+// GoodTuring is the Good-Turing smoothing, which re-estimates the count r of a
+// transition from the number of distinct transitions seen r+1 times.
+//
+//	r* = (r + 1) * N_{r+1} / N_r
+//
+// The counts above Threshold, or the ones without N_{r+1}, are used as is. The
+// unseen transitions from node A share the probability mass of N_1 / N equally,
+// then the estimates are normalized over the outgoing nodes.
+type GoodTuring struct {
+	// Threshold is the count above which the raw counts are used as is. Zero
+	// means GoodTuringThreshold.
+	Threshold int
+}
+
+// Smooth implements the Smoother interface.
+func (g GoodTuring) Smooth(stats Stats, fromA, toB uint64) float64 {
+	threshold := g.Threshold
+	if threshold <= 0 {
+		threshold = GoodTuringThreshold
+	}
+
+	adjusted := func(count float64) float64 {
+		r := int(math.Round(count))
+		if r > threshold {
+			return count
+		}
+
+		nextN, currN := stats.CountOfCounts(r+1), stats.CountOfCounts(r)
+		if nextN == 0 || currN == 0 {
+			return count
+		}
+
+		return float64(r+1) * float64(nextN) / float64(currN)
+	}
+
+	var seenMass float64
+
+	stats.ForEachFollower(fromA, func(_ uint64, count float64) {
+		seenMass += adjusted(count)
+	})
+
+	// Mass of the unseen transitions in the count of node A.
+	unseen := stats.Vocabulary() - stats.Followers(fromA)
+	unseenMass := 0.0
+
+	if unseen > 0 && stats.Total() > 0 {
+		unseenMass = stats.CountFrom(fromA) * float64(stats.CountOfCounts(1)) / stats.Total()
+	}
+
+	total := seenMass + unseenMass
+	if total == 0 {
+		return 0
+	}
+
+	if count := stats.Count(fromA, toB); count > 0 {
+		return adjusted(count) / total
+	}
+
+	if unseen == 0 {
+		return 0
+	}
+
+	return unseenMass / float64(unseen) / total
+}
+
+// ----------------------------------------------------------------------------
+//  Type: WittenBell
+// ----------------------------------------------------------------------------
+
+// WittenBell is the Witten-Bell smoothing, which interpolates with the prior
+// probability of node B by the number of distinct followers of node A.
+//
+//	P(B|A) = (c(A,B) + T(A) * P(B)) / (c(A) + T(A))
+//
+// Where T(A) is the number of distinct followers of node A and P(B) is c(B)/N.
+// The more various nodes have followed A, the more likely an unseen one is.
+type WittenBell struct{}
+
+// Smooth implements the Smoother interface.
+func (WittenBell) Smooth(stats Stats, fromA, toB uint64) float64 {
+	followers := float64(stats.Followers(fromA))
+	denominator := stats.CountFrom(fromA) + followers
+
+	if denominator == 0 || stats.Total() == 0 {
+		return 0
+	}
+
+	priorB := stats.CountTo(toB) / stats.Total()
+
+	return (stats.Count(fromA, toB) + followers*priorB) / denominator
+}
+
+// ----------------------------------------------------------------------------
+//  Type: KneserNey
+// ----------------------------------------------------------------------------
+
+// KneserNeyDiscount is the default discount of KneserNey.
+const KneserNeyDiscount = 0.75
+
+// KneserNey is the interpolated Kneser-Ney smoothing, which subtracts a fixed
+// discount from every seen count and gives the mass to the continuation
+// probability of node B.
+//
+//	P(B|A) = max(c(A,B) - D, 0) / c(A) + D * T(A) / c(A) * Pcont(B)
+//
+// Where T(A) is the number of distinct followers of node A and Pcont(B) is the
+// number of distinct predecessors of node B out of the distinct transitions. So
+// a node following many different nodes gets more share than the one which is
+// frequent only after a few nodes.
+type KneserNey struct {
+	// Discount is the absolute discount between 0 and 1. Zero means
+	// KneserNeyDiscount.
+	Discount float64
+}
+
+// Smooth implements the Smoother interface.
+func (k KneserNey) Smooth(stats Stats, fromA, toB uint64) float64 {
+	discount := k.Discount
+	if discount <= 0 {
+		discount = KneserNeyDiscount
+	}
+
+	countA := stats.CountFrom(fromA)
+	transitions := stats.Transitions()
+
+	if countA == 0 || transitions == 0 {
+		return 0
+	}
+
+	continuation := float64(stats.Predecessors(toB)) / float64(transitions)
+	lambda := discount * float64(stats.Followers(fromA)) / countA
+
+	return math.Max(stats.Count(fromA, toB)-discount, 0)/countA + lambda*continuation
+}
+
+// ----------------------------------------------------------------------------
+//  Type: summary (private)
+// ----------------------------------------------------------------------------
+
+// summary holds the statistics over all the transitions, which are expensive
+// to compute on every prediction.
+type summary struct {
+	countOfCounts map[int]int
+	predecessors  map[uint64]int
+	transitions   int
+}
+
+// newSummary returns the summary of the transitions. The scale converts the
+// stored weights into the decayed counts.
+func newSummary(fromAToB map[uint64]map[uint64]float64, scale float64) *summary {
+	sum := &summary{
+		countOfCounts: make(map[int]int),
+		predecessors:  make(map[uint64]int),
+	}
+
+	for _, toB := range fromAToB {
+		for nodeB, count := range toB {
+			if count <= 0 {
+				continue
+			}
+
+			sum.countOfCounts[int(math.Round(count*scale))]++
+			sum.predecessors[nodeB]++
+			sum.transitions++
+		}
+	}
+
+	return sum
+}
+
+// ----------------------------------------------------------------------------
+//  Type: stats (private)
+// ----------------------------------------------------------------------------
+
+// stats implements the Stats interface over the records of a NodeLog. The
+// caller must hold the lock of the NodeLog. The scale converts the stored
+// weights into the decayed counts.
+type stats struct {
+	nodeLog *NodeLog
+	scale   float64
+}
+
+func (s stats) Count(fromA, toB uint64) float64 {
+	return s.nodeLog.FromAToB[fromA][toB] * s.scale
+}
+
+func (s stats) CountFrom(fromA uint64) float64 {
+	return s.nodeLog.FromA[fromA] * s.scale
+}
+
+func (s stats) CountTo(toB uint64) float64 {
+	return s.nodeLog.ToB[toB] * s.scale
+}
+
+func (s stats) CountOfCounts(r int) int {
+	return s.nodeLog.getSummary().countOfCounts[r]
+}
+
+func (s stats) Followers(fromA uint64) int {
+	return len(s.nodeLog.FromAToB[fromA])
+}
+
+func (s stats) ForEachFollower(fromA uint64, fn func(toB uint64, count float64)) {
+	for toB, count := range s.nodeLog.FromAToB[fromA] {
+		fn(toB, count*s.scale)
+	}
+}
+
+func (s stats) Predecessors(toB uint64) int {
+	return s.nodeLog.getSummary().predecessors[toB]
+}
+
+func (s stats) Total() float64 {
+	return s.nodeLog.TotalAccesses * s.scale
+}
+
+func (s stats) Transitions() int {
+	return s.nodeLog.getSummary().transitions
+}
+
+func (s stats) Vocabulary() int {
+	return len(s.nodeLog.ToB)
+}
+
+// ----------------------------------------------------------------------------
+//  Private methods
+// ----------------------------------------------------------------------------
+
+// getSummary returns the cached summary of the transitions, building it if it
+// is stale. The caller must hold the lock, either read or write.
+func (n *NodeLog) getSummary() *summary {
+	n.summaryMu.Lock()
+	defer n.summaryMu.Unlock()
+
+	if n.summary == nil {
+		n.summary = newSummary(n.FromAToB, n.scale())
+	}
+
+	return n.summary
+}
+
+// smoothed returns the smoothed P(B|A). It returns 0 if node A is unseen.
+func (n *NodeLog) smoothed(fromA, toB uint64) float64 {
+	if n.FromA[fromA] == 0 {
+		return 0
+	}
+
+	return n.smoother.Smooth(stats{nodeLog: n, scale: n.scale()}, fromA, toB)
+}
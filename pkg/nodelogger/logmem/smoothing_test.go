@@ -0,0 +1,90 @@
+package logmem
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSmoother_distribution(t *testing.T) {
+	t.Parallel()
+
+	for _, smoother := range []Smoother{
+		Laplace(),
+		Additive{Alpha: 0.5},
+		GoodTuring{},
+		WittenBell{},
+		KneserNey{},
+		KneserNey{Discount: 0.5},
+	} {
+		smoother := smoother
+
+		t.Run(fmt.Sprintf("%T%v", smoother, smoother), func(t *testing.T) {
+			t.Parallel()
+
+			nodeLog := New(0, WithSmoothing(smoother))
+
+			// Node 1 is followed by 2 and 3 but never by 4.
+			for _, transition := range [][2]uint64{
+				{1, 2}, {1, 2}, {1, 2}, {1, 3},
+				{5, 4}, {6, 4}, {5, 3}, {6, 2},
+			} {
+				nodeLog.Update(transition[0], transition[1])
+			}
+
+			stats := stats{nodeLog: nodeLog, scale: 1}
+			sum := 0.0
+
+			for _, toB := range []uint64{2, 3, 4} {
+				prob := nodeLog.smoothed(1, toB)
+
+				require.Greater(t, prob, 0.0, "every outgoing node should have a share")
+				require.LessOrEqual(t, prob, 1.0)
+
+				sum += prob
+			}
+
+			assert.InDelta(t, 1.0, sum, 1e-9, "smoothed probabilities should sum up to 1")
+			assert.Greater(t, nodeLog.smoothed(1, 2), nodeLog.smoothed(1, 3),
+				"smoothing should keep the order of the seen transitions")
+			assert.Greater(t, nodeLog.Predict(1, 4), 0.0, "unseen transition should not be 0")
+			assert.InDelta(t, 0.0, nodeLog.Predict(7, 2), 0, "unseen incoming node should be left to the caller")
+			assert.Equal(t, 8.0, stats.Total())
+		})
+	}
+}
+
+func TestNodeLog_summary_is_updated(t *testing.T) {
+	t.Parallel()
+
+	nodeLog := New(0, WithSmoothing(KneserNey{}))
+
+	nodeLog.Update(1, 2)
+
+	before := nodeLog.Predict(1, 3)
+
+	nodeLog.Update(4, 3)
+	nodeLog.Update(1, 4)
+
+	stats := stats{nodeLog: nodeLog, scale: 1}
+
+	assert.Equal(t, 3, stats.Transitions())
+	assert.Equal(t, 1, stats.Predecessors(3))
+	assert.Equal(t, 3, stats.CountOfCounts(1))
+	assert.InDelta(t, 0.0, before, 0, "node 3 was not an outgoing node yet")
+	assert.Greater(t, nodeLog.Predict(1, 3), 0.0, "the summary should be rebuilt after the update")
+}
+
+func TestNodeLog_smoothing_without_records(t *testing.T) {
+	t.Parallel()
+
+	for _, smoother := range []Smoother{Additive{}, GoodTuring{}, WittenBell{}, KneserNey{}} {
+		nodeLog := New(0, WithSmoothing(smoother))
+		stats := stats{nodeLog: nodeLog, scale: 1}
+
+		require.InDelta(t, 0.0, nodeLog.Predict(1, 2), 0)
+		require.InDelta(t, 0.0, smoother.Smooth(stats, 1, 2), 0, "%T should avoid zero division", smoother)
+	}
+}
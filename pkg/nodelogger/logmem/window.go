@@ -0,0 +1,192 @@
+package logmem
+
+import (
+	"time"
+)
+
+// ----------------------------------------------------------------------------
+//  Sliding window
+// ----------------------------------------------------------------------------
+//  With the window enabled, every update is queued with its weight. Once an
+//  update falls out of the window, the same weight is subtracted from the
+//  counts, so the counts are always the exact sum of the updates in the window.
+// ============================================================================
+
+// windowEntry is an update in the window.
+type windowEntry struct {
+	// at is the time of the update in Unix nanoseconds. Zero if the window is
+	// not limited by the duration.
+	at int64
+	// source is the source of the update. Empty if not given.
+	source string
+	// weight is the weight added by the update.
+	weight float64
+	fromA  uint64
+	toB    uint64
+}
+
+// window holds the updates in the sliding window.
+type window struct {
+	// entries is the queue of the updates from entries[head], oldest first.
+	entries []windowEntry
+	// head is the index of the oldest update in entries.
+	head int
+	// maxAge is the duration of the window. Zero means not limited.
+	maxAge time.Duration
+	// maxUpdates is the number of the updates in the window. Zero means not
+	// limited.
+	maxUpdates int
+}
+
+// len returns the number of the updates in the window.
+func (w *window) len() int {
+	return len(w.entries) - w.head
+}
+
+// pop removes the oldest update from the window and returns it.
+func (w *window) pop() windowEntry {
+	entry := w.entries[w.head]
+
+	w.entries[w.head] = windowEntry{}
+	w.head++
+
+	// Compact the queue once the half of it is consumed, so that the memory is
+	// bounded by the size of the window.
+	if w.head > len(w.entries)/2 {
+		w.entries = append(w.entries[:0], w.entries[w.head:]...)
+		w.head = 0
+	}
+
+	return entry
+}
+
+// remove removes the newest update from node A to node B in the window and
+// returns it. If the source is given, only the update from the source matches.
+// It returns false if there is no such update.
+func (w *window) remove(source string, fromA, toB uint64) (windowEntry, bool) {
+	for i := len(w.entries) - 1; i >= w.head; i-- {
+		entry := w.entries[i]
+
+		if entry.fromA == fromA && entry.toB == toB && (source == "" || entry.source == source) {
+			w.entries = append(w.entries[:i], w.entries[i+1:]...)
+
+			return entry, true
+		}
+	}
+
+	return windowEntry{}, false
+}
+
+// ----------------------------------------------------------------------------
+//  Methods
+// ----------------------------------------------------------------------------
+
+// Expire removes the updates older than the duration of the window from the
+// records. See `WithWindowDuration()`.
+//
+// The updates and the reading methods, such as `Predict()`, expire the old
+// updates automatically. Call it to release the memory of the old updates if
+// there may have been no access for a while.
+func (n *NodeLog) Expire() {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	n.evict()
+}
+
+// WindowLen returns the number of the updates in the sliding window. It is
+// always 0 without the window.
+func (n *NodeLog) WindowLen() int {
+	n.rlockFresh()
+	defer n.mu.RUnlock()
+
+	if n.window == nil {
+		return 0
+	}
+
+	return n.window.len()
+}
+
+// ----------------------------------------------------------------------------
+//  Private methods
+// ----------------------------------------------------------------------------
+
+// evict removes the updates fallen out of the window from the records.
+func (n *NodeLog) evict() {
+	if n.window == nil {
+		return
+	}
+
+	win := n.window
+
+	for win.maxUpdates > 0 && win.len() > win.maxUpdates {
+		entry := win.pop()
+		n.removeSource(entry.source, entry.fromA, entry.toB)
+		n.decrement(entry.fromA, entry.toB, entry.weight)
+	}
+
+	if win.maxAge <= 0 {
+		return
+	}
+
+	oldest := n.clock().Add(-win.maxAge).UnixNano()
+
+	for win.len() > 0 && win.entries[win.head].at <= oldest {
+		entry := win.pop()
+		n.removeSource(entry.source, entry.fromA, entry.toB)
+		n.decrement(entry.fromA, entry.toB, entry.weight)
+	}
+}
+
+// expired returns true if the oldest update in the window is older than the
+// duration of the window.
+func (n *NodeLog) expired() bool {
+	if n.window == nil || n.window.maxAge <= 0 || n.window.len() == 0 {
+		return false
+	}
+
+	oldest := n.clock().Add(-n.window.maxAge).UnixNano()
+
+	return n.window.entries[n.window.head].at <= oldest
+}
+
+// rlockFresh takes the read lock after evicting the updates fallen out of the
+// window by the elapsed time, so that the readers never see them. Unlike the
+// other private methods, the caller must not hold the lock.
+func (n *NodeLog) rlockFresh() {
+	n.mu.RLock()
+
+	if !n.expired() {
+		return
+	}
+
+	n.mu.RUnlock()
+	n.mu.Lock()
+	n.evict()
+	n.mu.Unlock()
+	n.mu.RLock()
+}
+
+// getWindow returns the sliding window, creating it if not exists.
+func (n *NodeLog) getWindow() *window {
+	if n.window == nil {
+		n.window = new(window)
+	}
+
+	return n.window
+}
+
+// pushWindow queues the update to the window if enabled.
+func (n *NodeLog) pushWindow(source string, fromA, toB uint64, weight float64) {
+	if n.window == nil {
+		return
+	}
+
+	entry := windowEntry{source: source, fromA: fromA, toB: toB, weight: weight}
+
+	if n.window.maxAge > 0 {
+		entry.at = n.clock().UnixNano()
+	}
+
+	n.window.entries = append(n.window.entries, entry)
+}
@@ -0,0 +1,165 @@
+package logmem
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWithWindow(t *testing.T) {
+	t.Parallel()
+
+	const windowSize = 3
+
+	updates := [][2]uint64{{1, 2}, {1, 2}, {1, 3}, {4, 5}, {4, 2}, {5, 4}, {4, 5}}
+	nodeLog := New(0, WithWindow(windowSize))
+
+	for i, update := range updates {
+		nodeLog.Update(update[0], update[1])
+
+		// Must be the same as the records of the updates in the window only.
+		expect := New(0)
+
+		for _, inWindow := range updates[max(0, i+1-windowSize) : i+1] {
+			expect.Update(inWindow[0], inWindow[1])
+		}
+
+		require.Equal(t, expect.TotalAccesses, nodeLog.TotalAccesses, "update #%d", i)
+		require.Equal(t, expect.FromA, nodeLog.FromA, "update #%d", i)
+		require.Equal(t, expect.ToB, nodeLog.ToB, "update #%d", i)
+		require.Equal(t, expect.FromAToB, nodeLog.FromAToB, "update #%d", i)
+		require.Equal(t, min(i+1, windowSize), nodeLog.WindowLen())
+	}
+
+	_, ok := nodeLog.FromA[1]
+	assert.False(t, ok, "node fallen out of the window should leave no trace")
+	assert.InDelta(t, 0.0, nodeLog.Predict(1, 2), 0)
+}
+
+func TestWithWindowDuration(t *testing.T) {
+	t.Parallel()
+
+	now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	nodeLog := New(0, WithClock(func() time.Time { return now }), WithWindowDuration(time.Hour))
+
+	nodeLog.Update(1, 2)
+
+	now = now.Add(30 * time.Minute)
+
+	nodeLog.Update(1, 3)
+
+	assert.InDelta(t, 2.0, nodeLog.TotalAccesses, 0)
+
+	now = now.Add(30 * time.Minute)
+
+	nodeLog.Expire()
+
+	assert.InDelta(t, 1.0, nodeLog.TotalAccesses, 0, "update of an hour ago should be expired")
+	assert.InDelta(t, 0.0, nodeLog.PriorPfromAtoB(1, 2), 0)
+	assert.InDelta(t, 1.0, nodeLog.Predict(1, 3), 0)
+
+	now = now.Add(time.Hour)
+
+	nodeLog.Expire()
+
+	assert.Zero(t, nodeLog.WindowLen())
+	assert.Zero(t, nodeLog.TotalAccesses)
+	assert.Empty(t, nodeLog.FromAToB)
+}
+
+func TestWithWindowDuration_read(t *testing.T) {
+	t.Parallel()
+
+	now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	nodeLog := New(0, WithClock(func() time.Time { return now }), WithWindowDuration(time.Hour),
+		WithProvenance())
+
+	nodeLog.UpdateFrom("doc1", 1, 2)
+
+	require.InDelta(t, 1.0, nodeLog.Predict(1, 2), 0)
+
+	now = now.Add(2 * time.Hour)
+
+	// Reading without Update() nor Expire()
+	assert.Zero(t, nodeLog.Predict(1, 2), "expired update should not be predicted")
+	assert.Zero(t, nodeLog.PriorPtoB(2))
+	assert.Zero(t, nodeLog.PriorPfromAtoB(1, 2))
+	assert.Zero(t, nodeLog.PriorPNotFromAtoB(1, 2))
+	assert.Nil(t, nodeLog.Sources(1, 2))
+	assert.Zero(t, nodeLog.WindowLen())
+	assert.Zero(t, nodeLog.TotalAccesses, "expired update should be evicted on read")
+}
+
+func TestWithWindow_disabled(t *testing.T) {
+	t.Parallel()
+
+	nodeLog := New(0, WithWindow(0), WithWindowDuration(-1))
+
+	nodeLog.Update(1, 2)
+	nodeLog.Expire()
+
+	assert.Nil(t, nodeLog.window)
+	assert.Zero(t, nodeLog.WindowLen())
+	assert.InDelta(t, 1.0, nodeLog.TotalAccesses, 0)
+}
+
+func TestWithWindow_with_decay(t *testing.T) {
+	t.Parallel()
+
+	nodeLog := New(0, WithWindow(5), WithHalfLifeUpdates(1))
+
+	// Rescales the weights several times on the way
+	for i := 0; i < 1000; i++ {
+		nodeLog.Update(uint64(i%2), uint64(i%3)) // #nosec
+	}
+
+	assert.InDelta(t, 1+0.5+0.25+0.125+0.0625, nodeLog.TotalAccesses*nodeLog.Scale(), 1e-9)
+	assert.Len(t, nodeLog.FromA, 2)
+	assert.Equal(t, 5, nodeLog.WindowLen())
+}
+
+func TestWithWindow_dump(t *testing.T) {
+	t.Parallel()
+
+	nodeLog := New(0, WithWindow(3))
+
+	for _, update := range [][2]uint64{{1, 2}, {1, 3}, {2, 3}, {3, 1}} {
+		nodeLog.Update(update[0], update[1])
+	}
+
+	var dumped bytes.Buffer
+
+	_, err := nodeLog.WriteTo(&dumped)
+	require.NoError(t, err)
+
+	// Restored window expires as usual
+	restored := New(0, WithWindow(3))
+
+	_, err = restored.ReadFrom(bytes.NewReader(dumped.Bytes()))
+	require.NoError(t, err)
+	require.Equal(t, 3, restored.WindowLen())
+
+	restored.Update(5, 6)
+	nodeLog.Update(5, 6)
+
+	assert.Equal(t, nodeLog.FromAToB, restored.FromAToB)
+
+	// Smaller window evicts the overflow on load
+	smaller := New(0, WithWindow(1))
+
+	_, err = smaller.ReadFrom(bytes.NewReader(dumped.Bytes()))
+	require.NoError(t, err)
+	assert.Equal(t, 1, smaller.WindowLen())
+	assert.InDelta(t, 1.0, smaller.TotalAccesses, 0)
+	assert.InDelta(t, 1.0, smaller.FromAToB[3][1], 0)
+
+	// Without the window, the window is ignored
+	raw := New(0)
+
+	_, err = raw.ReadFrom(bytes.NewReader(dumped.Bytes()))
+	require.NoError(t, err)
+	assert.InDelta(t, 3.0, raw.TotalAccesses, 0)
+}
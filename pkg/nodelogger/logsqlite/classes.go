@@ -0,0 +1,75 @@
+package logsqlite
+
+import (
+	"database/sql"
+
+	"github.com/pkg/errors"
+)
+
+// ============================================================================
+//  Classes
+// ============================================================================
+//  Besides the counts, the NodeLog keeps the classes of the current scope, such
+//  as the original values of the items of bayes.Predictor, so they survive the
+//  process restarts along with the records. The values are opaque to the
+//  NodeLog and stored as is.
+// ============================================================================
+
+// ----------------------------------------------------------------------------
+//  Methods
+// ----------------------------------------------------------------------------
+
+// Classes returns the values of the classes stored via StoreClass in the current
+// scope by their class IDs.
+func (n *NodeLog) Classes() (map[uint64][]byte, error) {
+	classes := make(map[uint64][]byte)
+
+	err := n.queryRows(
+		`SELECT class_id, value FROM classes WHERE scope_id = ?`,
+		[]any{toInt64(n.nodeID)},
+		func(rows *sql.Rows) error {
+			var (
+				classID int64
+				value   []byte
+			)
+
+			err := rows.Scan(&classID, &value)
+			classes[toUint64(classID)] = value
+
+			return err //nolint:wrapcheck // wrapped by queryRows
+		},
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return classes, nil
+}
+
+// DeleteClass deletes the class stored via StoreClass. It does nothing if there
+// is no such class.
+//
+// On error, the error is kept and returned by `Err()`, or by `Commit()` during
+// the batch.
+func (n *NodeLog) DeleteClass(classID uint64) {
+	_, err := n.conn().Exec(
+		`DELETE FROM classes WHERE scope_id = ? AND class_id = ?`,
+		toInt64(n.nodeID), toInt64(classID),
+	)
+
+	n.setErr(errors.Wrap(err, "failed to delete the class"))
+}
+
+// StoreClass stores the value of the class in the current scope. The value of
+// the same class ID is replaced.
+//
+// On error, the error is kept and returned by `Err()`, or by `Commit()` during
+// the batch.
+func (n *NodeLog) StoreClass(classID uint64, value []byte) {
+	_, err := n.conn().Exec(
+		`INSERT OR REPLACE INTO classes (scope_id, class_id, value) VALUES (?, ?, ?)`,
+		toInt64(n.nodeID), toInt64(classID), value,
+	)
+
+	n.setErr(errors.Wrap(err, "failed to store the class"))
+}
@@ -0,0 +1,76 @@
+package logsqlite
+
+import (
+	"bytes"
+	"path/filepath"
+	"testing"
+
+	"github.com/KEINOS/go-bayes/pkg/nodelogger/logmem"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNodeLog_classes(t *testing.T) {
+	t.Parallel()
+
+	pathDB := filepath.Join(t.TempDir(), "test.db")
+
+	nodeLog, err := New(pathDB, 100)
+	require.NoError(t, err)
+
+	nodeLog.StoreClass(1, []byte("foo"))
+	nodeLog.StoreClass(2, []byte("bar"))
+	nodeLog.StoreClass(2, []byte("baz")) // replace
+	nodeLog.DeleteClass(1)
+	nodeLog.DeleteClass(3) // no such class
+
+	// Other scope in the same file
+	other, err := New(pathDB, 200)
+	require.NoError(t, err)
+
+	other.StoreClass(1, []byte("other"))
+
+	require.NoError(t, other.Close())
+	require.NoError(t, nodeLog.Err())
+	require.NoError(t, nodeLog.Close())
+
+	// Reopen the same file. The classes should survive.
+	nodeLog, err = New(pathDB, 100)
+	require.NoError(t, err)
+
+	defer nodeLog.Close()
+
+	classes, err := nodeLog.Classes()
+	require.NoError(t, err)
+	assert.Equal(t, map[uint64][]byte{2: []byte("baz")}, classes)
+
+	// ReadFrom replaces the classes as well
+	var dump bytes.Buffer
+
+	_, err = logmem.New(100).WriteTo(&dump)
+	require.NoError(t, err)
+
+	_, err = nodeLog.ReadFrom(&dump)
+	require.NoError(t, err)
+
+	classes, err = nodeLog.Classes()
+	require.NoError(t, err)
+	assert.Empty(t, classes)
+}
+
+func TestNodeLog_classes_closed_database(t *testing.T) {
+	t.Parallel()
+
+	nodeLog, err := New(MemoryPath, 100)
+	require.NoError(t, err)
+	require.NoError(t, nodeLog.Close())
+
+	_, err = nodeLog.Classes()
+	require.Error(t, err, "closed database should be an error")
+
+	nodeLog.StoreClass(1, []byte("foo"))
+
+	err = nodeLog.Err()
+	require.Error(t, err, "closed database should be an error")
+	assert.Contains(t, err.Error(), "failed to store the class")
+}
@@ -0,0 +1,190 @@
+package logsqlite
+
+import (
+	"database/sql"
+	"io"
+
+	"github.com/KEINOS/go-bayes/pkg/nodelogger/logmem"
+	"github.com/pkg/errors"
+)
+
+// ----------------------------------------------------------------------------
+//  Methods
+// ----------------------------------------------------------------------------
+
+// ReadFrom replaces the records of the current scope with the ones read from r.
+// It implements the io.ReaderFrom interface.
+//
+// The data must be in the binary format of logmem.NodeLog.WriteTo. Note that the
+// records are stored under the node ID of the current NodeLog, regardless of the
+// node ID in the data. The classes stored via StoreClass are deleted as well,
+// since the data does not hold them. On error, the records are left unchanged.
+func (n *NodeLog) ReadFrom(r io.Reader) (int64, error) {
+	loaded := logmem.New(n.nodeID)
+
+	size, err := loaded.ReadFrom(r)
+	if err != nil {
+		return size, errors.Wrap(err, "failed to read the dump")
+	}
+
+	return size, n.replace(loaded)
+}
+
+// WriteTo writes the records of the current scope to w. It implements the
+// io.WriterTo interface.
+//
+// The output is in the same binary format as logmem.NodeLog.WriteTo, so the
+// records can be moved between the storages.
+func (n *NodeLog) WriteTo(w io.Writer) (int64, error) {
+	dumped, err := n.export()
+	if err != nil {
+		return 0, err
+	}
+
+	size, err := dumped.WriteTo(w)
+
+	return size, errors.Wrap(err, "failed to write the dump")
+}
+
+// ----------------------------------------------------------------------------
+//  Private methods
+// ----------------------------------------------------------------------------
+
+// export returns the records of the current scope as logmem.NodeLog.
+func (n *NodeLog) export() (*logmem.NodeLog, error) {
+	scopeID := toInt64(n.nodeID)
+	dumped := logmem.New(n.nodeID)
+
+	total, err := n.count(`SELECT count FROM total_accesses WHERE scope_id = ?`, scopeID)
+	if err != nil {
+		return nil, err
+	}
+
+	dumped.TotalAccesses = total
+
+	err = n.queryRows(
+		`SELECT node_a, count FROM from_a WHERE scope_id = ?`,
+		[]any{scopeID},
+		func(rows *sql.Rows) error {
+			var (
+				nodeA int64
+				count float64
+			)
+
+			err := rows.Scan(&nodeA, &count)
+			dumped.FromA[toUint64(nodeA)] = count
+
+			return err //nolint:wrapcheck // wrapped by queryRows
+		},
+	)
+	if err == nil {
+		err = n.queryRows(
+			`SELECT node_b, count FROM to_b WHERE scope_id = ?`,
+			[]any{scopeID},
+			func(rows *sql.Rows) error {
+				var (
+					nodeB int64
+					count float64
+				)
+
+				err := rows.Scan(&nodeB, &count)
+				dumped.ToB[toUint64(nodeB)] = count
+
+				return err //nolint:wrapcheck // wrapped by queryRows
+			},
+		)
+	}
+
+	if err == nil {
+		err = n.queryRows(
+			`SELECT node_a, node_b, count FROM from_a_to_b WHERE scope_id = ?`,
+			[]any{scopeID},
+			func(rows *sql.Rows) error {
+				var (
+					nodeA, nodeB int64
+					count        float64
+				)
+
+				err := rows.Scan(&nodeA, &nodeB, &count)
+				if _, ok := dumped.FromAToB[toUint64(nodeA)]; !ok {
+					dumped.FromAToB[toUint64(nodeA)] = make(map[uint64]float64)
+				}
+
+				dumped.FromAToB[toUint64(nodeA)][toUint64(nodeB)] = count
+
+				return err //nolint:wrapcheck // wrapped by queryRows
+			},
+		)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return dumped, nil
+}
+
+// queryRows calls scan for each row of the query result.
+func (n *NodeLog) queryRows(query string, args []any, scan func(rows *sql.Rows) error) error {
+	rows, err := n.conn().Query(query, args...)
+	if err != nil {
+		return errors.Wrap(err, "failed to query the records")
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		if err := scan(rows); err != nil {
+			return errors.Wrap(err, "failed to scan the records")
+		}
+	}
+
+	return errors.Wrap(rows.Err(), "failed to iterate the records")
+}
+
+// replace replaces the records of the current scope with the given records in
+// a single transaction, or in the transaction of the batch if begun.
+func (n *NodeLog) replace(records *logmem.NodeLog) error {
+	scopeID := toInt64(n.nodeID)
+
+	txn, owned, err := n.begin()
+	if err != nil {
+		return err
+	}
+
+	exec := func(query string, args ...any) {
+		if err == nil {
+			_, err = txn.Exec(query, args...)
+		}
+	}
+
+	exec(`DELETE FROM total_accesses WHERE scope_id = ?`, scopeID)
+	exec(`DELETE FROM from_a WHERE scope_id = ?`, scopeID)
+	exec(`DELETE FROM to_b WHERE scope_id = ?`, scopeID)
+	exec(`DELETE FROM from_a_to_b WHERE scope_id = ?`, scopeID)
+	exec(`DELETE FROM classes WHERE scope_id = ?`, scopeID)
+	exec(`INSERT INTO total_accesses (scope_id, count) VALUES (?, ?)`, scopeID, records.TotalAccesses)
+
+	for nodeA, count := range records.FromA {
+		exec(`INSERT INTO from_a (scope_id, node_a, count) VALUES (?, ?, ?)`,
+			scopeID, toInt64(nodeA), count)
+	}
+
+	for nodeB, count := range records.ToB {
+		exec(`INSERT INTO to_b (scope_id, node_b, count) VALUES (?, ?, ?)`,
+			scopeID, toInt64(nodeB), count)
+	}
+
+	for nodeA, toB := range records.FromAToB {
+		for nodeB, count := range toB {
+			exec(`INSERT INTO from_a_to_b (scope_id, node_a, node_b, count) VALUES (?, ?, ?, ?)`,
+				scopeID, toInt64(nodeA), toInt64(nodeB), count)
+		}
+	}
+
+	if err != nil {
+		err = errors.Wrap(err, "failed to replace the records")
+	}
+
+	return n.end(txn, owned, err)
+}
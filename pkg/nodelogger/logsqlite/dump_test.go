@@ -0,0 +1,99 @@
+package logsqlite
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/KEINOS/go-bayes/pkg/nodelogger/logmem"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNodeLog_ReadFrom_broken_data(t *testing.T) {
+	t.Parallel()
+
+	nodeLog, err := New(MemoryPath, 12345)
+	require.NoError(t, err)
+
+	defer nodeLog.Close()
+
+	nodeLog.Update(1, 2)
+
+	_, err = nodeLog.ReadFrom(bytes.NewReader([]byte("XXXX\x01\x00")))
+
+	require.Error(t, err, "broken data should be an error")
+	assert.Contains(t, err.Error(), "failed to read the dump")
+
+	// On error, the records must be left unchanged
+	assert.Equal(t, 1.0, nodeLog.FromAToB(1, 2))
+}
+
+func TestNodeLog_WriteTo_closed_database(t *testing.T) {
+	t.Parallel()
+
+	nodeLog, err := New(MemoryPath, 12345)
+	require.NoError(t, err)
+	require.NoError(t, nodeLog.Close())
+
+	_, err = nodeLog.WriteTo(new(bytes.Buffer))
+	require.Error(t, err, "closed database should be an error")
+
+	_, err = nodeLog.ReadFrom(bytes.NewReader(dumpOf(t, logmem.New(12345))))
+	require.Error(t, err, "closed database should be an error")
+	assert.Contains(t, err.Error(), "failed to begin the transaction")
+}
+
+func TestNodeLog_WriteTo_and_ReadFrom(t *testing.T) {
+	t.Parallel()
+
+	const maxID = uint64(0xffffffffffffffff)
+
+	// Records in memory
+	memLog := logmem.New(12345)
+
+	memLog.Update(1, 2)
+	memLog.Update(1, 2)
+	memLog.Update(2, maxID)
+	memLog.Update(maxID, 1)
+
+	// Move the records from the memory to SQLite3
+	sqlLog, err := New(MemoryPath, 12345)
+	require.NoError(t, err)
+
+	defer sqlLog.Close()
+
+	sqlLog.Update(3, 3) // must be replaced
+
+	size, err := sqlLog.ReadFrom(bytes.NewReader(dumpOf(t, memLog)))
+	require.NoError(t, err)
+	require.Positive(t, size)
+
+	assert.Equal(t, 4.0, sqlLog.TotalAccesses())
+	assert.Equal(t, 2.0, sqlLog.FromAToB(1, 2))
+	assert.Equal(t, 1.0, sqlLog.FromAToB(maxID, 1))
+	assert.Equal(t, 0.0, sqlLog.FromAToB(3, 3))
+
+	// Move them back to the memory
+	var dumped bytes.Buffer
+
+	_, err = sqlLog.WriteTo(&dumped)
+	require.NoError(t, err)
+
+	restored := logmem.New(0)
+
+	_, err = restored.ReadFrom(&dumped)
+	require.NoError(t, err)
+
+	require.Equal(t, memLog, restored)
+}
+
+func dumpOf(t *testing.T, nodeLog *logmem.NodeLog) []byte {
+	t.Helper()
+
+	var dumped bytes.Buffer
+
+	_, err := nodeLog.WriteTo(&dumped)
+	require.NoError(t, err)
+
+	return dumped.Bytes()
+}
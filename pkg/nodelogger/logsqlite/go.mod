@@ -0,0 +1,32 @@
+module github.com/KEINOS/go-bayes/pkg/nodelogger/logsqlite
+
+go 1.26.0
+
+require (
+	github.com/KEINOS/go-bayes v0.0.0-00010101000000-000000000000
+	github.com/pkg/errors v0.9.1
+	github.com/stretchr/testify v1.11.1
+	modernc.org/sqlite v1.60.1
+)
+
+require (
+	github.com/davecgh/go-spew v1.1.1 // indirect
+	github.com/dustin/go-humanize v1.0.1 // indirect
+	github.com/google/uuid v1.6.0 // indirect
+	github.com/klauspost/cpuid/v2 v2.0.12 // indirect
+	github.com/mattn/go-isatty v0.0.24 // indirect
+	github.com/ncruces/go-strftime v1.0.0 // indirect
+	github.com/pmezard/go-difflib v1.0.0 // indirect
+	github.com/remyoudompheng/bigfft v0.0.0-20230129092748-24d4a6f8daec // indirect
+	github.com/zeebo/blake3 v0.2.4 // indirect
+	golang.org/x/sys v0.48.0 // indirect
+	gopkg.in/yaml.v3 v3.0.1 // indirect
+	modernc.org/libc v1.77.1 // indirect
+	modernc.org/mathutil v1.7.1 // indirect
+	modernc.org/memory v1.12.1 // indirect
+)
+
+// Use the bayes package of the same tree. The consumers ignore this directive,
+// so before tagging this module, tag the root module first and set the required
+// version above to it. See "Contribute" in README.md.
+replace github.com/KEINOS/go-bayes => ../../..
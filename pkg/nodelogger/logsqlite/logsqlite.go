@@ -0,0 +1,622 @@
+/*
+Package logsqlite is an implementation of bayes.NodeLogger for SQLite3-based logging.
+
+The records are stored in a SQLite3 database file, so they are not limited by
+the size of the memory and they survive the process restarts. The records are
+distinguished by the node ID (scope ID), so several nodes can share the same
+database file.
+
+It uses a pure-Go SQLite3 driver (modernc.org/sqlite), so cgo is not required.
+
+Importing the package registers it as bayes.SQLite3Storage, so the predictors
+of the bayes package can store their records in a SQLite3 database file:
+
+	import _ "github.com/KEINOS/go-bayes/pkg/nodelogger/logsqlite"
+*/
+package logsqlite
+
+import (
+	"database/sql"
+	"math"
+	"strconv"
+	"sync"
+
+	"github.com/KEINOS/go-bayes"
+	"github.com/KEINOS/go-bayes/pkg/theorem"
+	"github.com/pkg/errors"
+
+	// Pure-Go SQLite3 driver. Registers itself as "sqlite".
+	_ "modernc.org/sqlite"
+)
+
+// epsilon is the count regarded as 0 on decrement, to drop the records left by
+// the rounding errors of the fractional counts.
+const epsilon = 1e-9
+
+// DriverName is the name of the database/sql driver used to open the database.
+const DriverName = "sqlite"
+
+// MemoryPath is the path to use for a temporary in-memory database. The records
+// are lost once the NodeLog is closed.
+const MemoryPath = ":memory:"
+
+// schema is the set of statements to create the tables if not exist. Each table
+// is keyed by the scope ID, which is the node ID of the NodeLog.
+//
+// Note that the node IDs are uint64 but SQLite3 INTEGER is int64. They are stored
+// as int64 preserving the bit representation. See toInt64(). The counts are REAL
+// to hold the fractional weights of UpdateN(). The tables created as INTEGER by
+// the older versions also hold them, since SQLite3 stores the non-integer values
+// as is. The classes table holds the values given via StoreClass().
+const schema = `
+CREATE TABLE IF NOT EXISTS total_accesses (
+	scope_id INTEGER NOT NULL PRIMARY KEY,
+	count    REAL    NOT NULL DEFAULT 0
+);
+CREATE TABLE IF NOT EXISTS from_a (
+	scope_id INTEGER NOT NULL,
+	node_a   INTEGER NOT NULL,
+	count    REAL    NOT NULL DEFAULT 0,
+	PRIMARY KEY (scope_id, node_a)
+);
+CREATE TABLE IF NOT EXISTS to_b (
+	scope_id INTEGER NOT NULL,
+	node_b   INTEGER NOT NULL,
+	count    REAL    NOT NULL DEFAULT 0,
+	PRIMARY KEY (scope_id, node_b)
+);
+CREATE TABLE IF NOT EXISTS from_a_to_b (
+	scope_id INTEGER NOT NULL,
+	node_a   INTEGER NOT NULL,
+	node_b   INTEGER NOT NULL,
+	count    REAL    NOT NULL DEFAULT 0,
+	PRIMARY KEY (scope_id, node_a, node_b)
+);
+CREATE TABLE IF NOT EXISTS classes (
+	scope_id INTEGER NOT NULL,
+	class_id INTEGER NOT NULL,
+	value    BLOB    NOT NULL,
+	PRIMARY KEY (scope_id, class_id)
+);
+`
+
+func init() {
+	bayes.RegisterStorage(bayes.SQLite3Storage, open)
+}
+
+// ----------------------------------------------------------------------------
+//  Type: NodeLog
+// ----------------------------------------------------------------------------
+
+// NodeLog holds the records of a node in a SQLite3 database. It is an
+// implementation of bayes.NodeLogger for SQLite3-based logging.
+//
+// Since the methods of bayes.NodeLogger do not return errors, the first error
+// occurred during the database access, such as on reading the counts, is kept
+// and can be retrieved via Err(). The counts read on error are 0.
+//
+// The updates between Begin() and Commit() are applied in a single transaction,
+// which is much faster than a transaction per update. See Begin().
+type NodeLog struct {
+	db *sql.DB
+	// err is the first error occurred during the database access outside the
+	// batch, since the last successful Begin() or Commit().
+	err error
+	// txn is the transaction of the batch begun via Begin(). Nil if no batch.
+	txn *sql.Tx
+	// batchErr is the first error occurred during the batch.
+	batchErr error
+	// muErr protects err and batchErr.
+	muErr sync.Mutex
+	// muTxn protects txn.
+	muTxn sync.Mutex
+	// nodeID is the node ID of the current node. Used as the scope ID.
+	nodeID uint64
+}
+
+// querier is the methods shared by sql.DB and sql.Tx.
+type querier interface {
+	Exec(query string, args ...any) (sql.Result, error)
+	Query(query string, args ...any) (*sql.Rows, error)
+	QueryRow(query string, args ...any) *sql.Row
+}
+
+// ----------------------------------------------------------------------------
+//  Constructor
+// ----------------------------------------------------------------------------
+
+// New returns a new NodeLog instance which stores the records in the SQLite3
+// database at the given path. The database file and the tables are created if
+// not exist. The existing records of the same nodeID are kept.
+//
+// Use MemoryPath as the path for a temporary in-memory database.
+func New(path string, nodeID uint64) (*NodeLog, error) {
+	db, err := sql.Open(DriverName, path)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to open the database")
+	}
+
+	// SQLite3 allows only one writer at a time. Also, each connection of an
+	// in-memory database is a different database. Therefore, use only one
+	// connection and never close it while the NodeLog is alive.
+	db.SetMaxOpenConns(1)
+	db.SetConnMaxLifetime(0)
+
+	if _, err := db.Exec(schema); err != nil {
+		_ = db.Close()
+
+		return nil, errors.Wrap(err, "failed to create the tables")
+	}
+
+	return &NodeLog{
+		db:     db,
+		nodeID: nodeID,
+	}, nil
+}
+
+// ----------------------------------------------------------------------------
+//  Methods
+// ----------------------------------------------------------------------------
+
+// Begin begins a batch, so the following updates are applied in a single
+// transaction until Commit() or Rollback(). It speeds up a series of updates,
+// such as training a sequence, and on error none of them are applied.
+//
+// Since SQLite3 allows only one writer at a time, the updates and the queries
+// of any goroutine join the batch until it ends. It returns an error if a batch
+// is already begun.
+func (n *NodeLog) Begin() error {
+	n.muTxn.Lock()
+	defer n.muTxn.Unlock()
+
+	if n.txn != nil {
+		return errors.New("failed to begin the batch. The batch is already begun")
+	}
+
+	txn, err := n.db.Begin()
+	if err != nil {
+		return errors.Wrap(err, "failed to begin the batch")
+	}
+
+	n.muErr.Lock()
+	n.err = nil
+	n.batchErr = nil
+	n.muErr.Unlock()
+
+	n.txn = txn
+
+	return nil
+}
+
+// Close closes the database. The batch not committed is rolled back. The
+// NodeLog must not be used after closing.
+func (n *NodeLog) Close() error {
+	if txn, _ := n.endBatch(); txn != nil {
+		_ = txn.Rollback()
+	}
+
+	return errors.Wrap(n.db.Close(), "failed to close the database")
+}
+
+// Commit ends the batch begun via Begin() and applies its updates.
+//
+// If an error occurred during the batch, such as on writing to a read-only
+// database, none of the updates are applied and the first error is returned.
+// On success, the error kept by `Err()` is cleared.
+func (n *NodeLog) Commit() error {
+	txn, err := n.endBatch()
+	if txn == nil {
+		return errors.New("failed to commit the batch. The batch is not begun")
+	}
+
+	if err != nil {
+		_ = txn.Rollback()
+
+		return errors.Wrap(err, "failed to commit the batch")
+	}
+
+	if err := txn.Commit(); err != nil {
+		return errors.Wrap(err, "failed to commit the batch")
+	}
+
+	n.muErr.Lock()
+	n.err = nil
+	n.muErr.Unlock()
+
+	return nil
+}
+
+// Err returns the first error occurred during the database access outside the
+// batch, such as on reading the counts. It returns nil if no error occurred.
+//
+// The error is kept until the next successful Begin() or Commit(), so a failure
+// does not hide the following ones. The errors during the batch are returned by
+// Commit() instead.
+func (n *NodeLog) Err() error {
+	n.muErr.Lock()
+	defer n.muErr.Unlock()
+
+	return n.err
+}
+
+// Rollback ends the batch begun via Begin() without applying its updates.
+func (n *NodeLog) Rollback() error {
+	txn, _ := n.endBatch()
+	if txn == nil {
+		return errors.New("failed to rollback the batch. The batch is not begun")
+	}
+
+	return errors.Wrap(txn.Rollback(), "failed to rollback the batch")
+}
+
+// Decrement reverses an update from node A to node B made via `Update()`. It
+// does nothing if there is no such update in the records, so the counts never
+// go negative. If the count of the transition is fractional and less than 1,
+// only the remaining count is subtracted. The records reaching 0 are deleted.
+//
+// On error, the error is kept and returned by `Err()`, or by `Commit()` during
+// the batch.
+func (n *NodeLog) Decrement(fromA, toB uint64) {
+	n.setErr(n.decrement(fromA, toB))
+}
+
+// FromA returns the number of incoming accesses from node A.
+func (n *NodeLog) FromA(nodeA uint64) float64 {
+	return n.queryCount(
+		`SELECT count FROM from_a WHERE scope_id = ? AND node_a = ?`,
+		toInt64(n.nodeID), toInt64(nodeA),
+	)
+}
+
+// FromAToB returns the number of accesses from node A to node B. A is the
+// incoming access and B is the outgoing access.
+func (n *NodeLog) FromAToB(nodeA, nodeB uint64) float64 {
+	return n.queryCount(
+		`SELECT count FROM from_a_to_b WHERE scope_id = ? AND node_a = ? AND node_b = ?`,
+		toInt64(n.nodeID), toInt64(nodeA), toInt64(nodeB),
+	)
+}
+
+// ID returns the node ID of the current node.
+func (n *NodeLog) ID() uint64 {
+	return n.nodeID
+}
+
+// Predict returns the probability of the next node to be toNodeB if the incoming
+// node is fromNodeA.
+func (n *NodeLog) Predict(fromNodeA, toNodeB uint64) float64 {
+	// Prior probability of the next node to be node B.
+	PriorProbToB := n.PriorPtoB(toNodeB)
+	// Prior probability of the incoming node to be node B if the previous node was A.
+	PriorProbFromAtoB := n.PriorPfromAtoB(fromNodeA, toNodeB)
+	// Prior probability of the incoming node not to be node B if the previous node was A.
+	PriorProbNotFromAtoB := n.PriorPNotFromAtoB(fromNodeA, toNodeB)
+
+	return theorem.Bayes(PriorProbToB, PriorProbFromAtoB, PriorProbNotFromAtoB)
+}
+
+// PriorPfromAtoB returns the prior probability of the node to be B if the
+// previous node is A.
+func (n *NodeLog) PriorPfromAtoB(fromA, toB uint64) float64 {
+	total := n.TotalAccesses()
+	if total == 0 {
+		return 0
+	}
+
+	return n.FromAToB(fromA, toB) / total
+}
+
+// PriorPNotFromAtoB returns the prior probability of the node not to be B
+// if the previous node is A.
+func (n *NodeLog) PriorPNotFromAtoB(fromA, toB uint64) float64 {
+	total := n.TotalAccesses()
+	if total == 0 {
+		return 0
+	}
+
+	notA := n.FromA(fromA) - n.FromAToB(fromA, toB)
+
+	return notA / total
+}
+
+// PriorPtoB returns the prior probability of the outgoing node to be nodeB.
+//
+// Which is the number of outgoing accesses to the node B out of the total number
+// of accesses of current node.
+func (n *NodeLog) PriorPtoB(nodeB uint64) float64 {
+	total := n.TotalAccesses()
+	if total == 0 {
+		return 0
+	}
+
+	return n.ToB(nodeB) / total
+}
+
+// String returns a string representation of the NodeLog which is the node ID.
+func (n *NodeLog) String() string {
+	return strconv.FormatUint(n.nodeID, 10)
+}
+
+// ToB returns the number of outgoing accesses to node B.
+func (n *NodeLog) ToB(nodeB uint64) float64 {
+	return n.queryCount(
+		`SELECT count FROM to_b WHERE scope_id = ? AND node_b = ?`,
+		toInt64(n.nodeID), toInt64(nodeB),
+	)
+}
+
+// TotalAccesses returns the total number of accesses to the node.
+func (n *NodeLog) TotalAccesses() float64 {
+	return n.queryCount(
+		`SELECT count FROM total_accesses WHERE scope_id = ?`,
+		toInt64(n.nodeID),
+	)
+}
+
+// Update updates the records of a node.
+// It must be called by the next node accessed.
+//
+// The records are updated in a single transaction, or in the transaction of the
+// batch if begun. On error, none of the records are updated and the error can be
+// retrieved via Err(), or via Commit() during the batch.
+func (n *NodeLog) Update(fromA, toB uint64) {
+	n.setErr(n.update(fromA, toB, 1))
+}
+
+// UpdateN is the same as calling `Update()` n times at once, such as for the
+// pre-aggregated sequences. The count may be fractional. It does nothing if the
+// count is not a positive finite number.
+func (n *NodeLog) UpdateN(fromA, toB uint64, count float64) {
+	if count <= 0 || math.IsInf(count, 0) || math.IsNaN(count) {
+		return
+	}
+
+	n.setErr(n.update(fromA, toB, count))
+}
+
+// ----------------------------------------------------------------------------
+//  Private methods
+// ----------------------------------------------------------------------------
+
+// begin returns the transaction of the batch if begun. Otherwise, it begins a
+// new transaction owned by the caller, which must be ended via end().
+func (n *NodeLog) begin() (*sql.Tx, bool, error) {
+	n.muTxn.Lock()
+	defer n.muTxn.Unlock()
+
+	if n.txn != nil {
+		return n.txn, false, nil
+	}
+
+	txn, err := n.db.Begin()
+	if err != nil {
+		return nil, false, errors.Wrap(err, "failed to begin the transaction")
+	}
+
+	return txn, true, nil
+}
+
+// conn returns the transaction of the batch if begun, otherwise the database.
+// The database must not be accessed directly during the batch, since the batch
+// holds the only connection.
+func (n *NodeLog) conn() querier {
+	n.muTxn.Lock()
+	defer n.muTxn.Unlock()
+
+	if n.txn != nil {
+		return n.txn
+	}
+
+	return n.db
+}
+
+// decrement subtracts up to 1 from the counts of the transition from node A to
+// node B in a single transaction and deletes the records reaching 0.
+func (n *NodeLog) decrement(fromA, toB uint64) error {
+	scopeID, nodeA, nodeB := toInt64(n.nodeID), toInt64(fromA), toInt64(toB)
+
+	txn, owned, err := n.begin()
+	if err != nil {
+		return err
+	}
+
+	var count float64
+
+	err = txn.QueryRow(
+		`SELECT count FROM from_a_to_b WHERE scope_id = ? AND node_a = ? AND node_b = ?`,
+		scopeID, nodeA, nodeB,
+	).Scan(&count)
+	if errors.Is(err, sql.ErrNoRows) || (err == nil && count <= 0) {
+		return n.end(txn, owned, nil)
+	}
+
+	if err != nil {
+		return n.end(txn, owned, errors.Wrap(err, "failed to query the records"))
+	}
+
+	weight := math.Min(1, count)
+
+	for _, stmt := range []struct {
+		query string
+		args  []any
+	}{
+		{`UPDATE total_accesses SET count = count - ? WHERE scope_id = ?`, []any{weight, scopeID}},
+		{`UPDATE from_a SET count = count - ? WHERE scope_id = ? AND node_a = ?`, []any{weight, scopeID, nodeA}},
+		{`UPDATE to_b SET count = count - ? WHERE scope_id = ? AND node_b = ?`, []any{weight, scopeID, nodeB}},
+		{
+			`UPDATE from_a_to_b SET count = count - ? WHERE scope_id = ? AND node_a = ? AND node_b = ?`,
+			[]any{weight, scopeID, nodeA, nodeB},
+		},
+		{`DELETE FROM from_a WHERE scope_id = ? AND node_a = ? AND count <= ?`, []any{scopeID, nodeA, epsilon}},
+		{`DELETE FROM to_b WHERE scope_id = ? AND node_b = ? AND count <= ?`, []any{scopeID, nodeB, epsilon}},
+		{
+			`DELETE FROM from_a_to_b WHERE scope_id = ? AND node_a = ? AND node_b = ? AND count <= ?`,
+			[]any{scopeID, nodeA, nodeB, epsilon},
+		},
+	} {
+		if _, err := txn.Exec(stmt.query, stmt.args...); err != nil {
+			return n.end(txn, owned, errors.Wrap(err, "failed to decrement the records"))
+		}
+	}
+
+	return n.end(txn, owned, nil)
+}
+
+// end ends the transaction returned by begin() if owned by the caller. It
+// commits the transaction if err is nil, otherwise rolls it back and returns
+// err. The transaction of the batch is left to Commit() or Rollback().
+func (n *NodeLog) end(txn *sql.Tx, owned bool, err error) error {
+	if !owned {
+		return err
+	}
+
+	if err != nil {
+		_ = txn.Rollback()
+
+		return err
+	}
+
+	return errors.Wrap(txn.Commit(), "failed to commit the transaction")
+}
+
+// endBatch detaches the transaction of the batch and returns it with the first
+// error occurred during the batch. It returns nil if no batch is begun.
+func (n *NodeLog) endBatch() (*sql.Tx, error) {
+	n.muTxn.Lock()
+	defer n.muTxn.Unlock()
+
+	txn := n.txn
+	n.txn = nil
+
+	n.muErr.Lock()
+	defer n.muErr.Unlock()
+
+	err := n.batchErr
+	n.batchErr = nil
+
+	return txn, err
+}
+
+// queryCount returns the count of the given query. It returns 0 if no record
+// found or on error. The error is kept and returned by `Err()`, or by `Commit()`
+// during the batch.
+func (n *NodeLog) queryCount(query string, args ...any) float64 {
+	count, err := n.count(query, args...)
+	n.setErr(err)
+
+	return count
+}
+
+// count returns the count of the given query. It returns 0 without error if no
+// record found.
+func (n *NodeLog) count(query string, args ...any) (float64, error) {
+	var count float64
+
+	err := n.conn().QueryRow(query, args...).Scan(&count)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return 0, nil
+		}
+
+		return 0, errors.Wrap(err, "failed to query the count")
+	}
+
+	return count, nil
+}
+
+// setErr keeps the given error if no error was kept before. During the batch,
+// it is kept as the error of the batch instead, which is returned by Commit().
+func (n *NodeLog) setErr(err error) {
+	if err == nil {
+		return
+	}
+
+	n.muTxn.Lock()
+	inBatch := n.txn != nil
+	n.muTxn.Unlock()
+
+	n.muErr.Lock()
+	defer n.muErr.Unlock()
+
+	if inBatch {
+		if n.batchErr == nil {
+			n.batchErr = err
+		}
+
+		return
+	}
+
+	if n.err == nil {
+		n.err = err
+	}
+}
+
+func (n *NodeLog) update(fromA, toB uint64, count float64) error {
+	scopeID, nodeA, nodeB := toInt64(n.nodeID), toInt64(fromA), toInt64(toB)
+
+	txn, owned, err := n.begin()
+	if err != nil {
+		return err
+	}
+
+	for _, stmt := range []struct {
+		query string
+		args  []any
+	}{
+		{
+			`INSERT INTO total_accesses (scope_id, count) VALUES (?, ?)
+			 ON CONFLICT (scope_id) DO UPDATE SET count = count + excluded.count`,
+			[]any{scopeID, count},
+		},
+		{
+			`INSERT INTO from_a (scope_id, node_a, count) VALUES (?, ?, ?)
+			 ON CONFLICT (scope_id, node_a) DO UPDATE SET count = count + excluded.count`,
+			[]any{scopeID, nodeA, count},
+		},
+		{
+			`INSERT INTO to_b (scope_id, node_b, count) VALUES (?, ?, ?)
+			 ON CONFLICT (scope_id, node_b) DO UPDATE SET count = count + excluded.count`,
+			[]any{scopeID, nodeB, count},
+		},
+		{
+			`INSERT INTO from_a_to_b (scope_id, node_a, node_b, count) VALUES (?, ?, ?, ?)
+			 ON CONFLICT (scope_id, node_a, node_b) DO UPDATE SET count = count + excluded.count`,
+			[]any{scopeID, nodeA, nodeB, count},
+		},
+	} {
+		if _, err := txn.Exec(stmt.query, stmt.args...); err != nil {
+			return n.end(txn, owned, errors.Wrap(err, "failed to update the records"))
+		}
+	}
+
+	return n.end(txn, owned, nil)
+}
+
+// ----------------------------------------------------------------------------
+//  Private functions
+// ----------------------------------------------------------------------------
+
+// open is the bayes.StorageOpener of the SQLite3Storage.
+func open(path string, scopeID uint64) (bayes.NodeLogger, error) {
+	nodeLog, err := New(path, scopeID)
+	if err != nil {
+		return nil, err
+	}
+
+	return nodeLog, nil
+}
+
+// toInt64 converts the node ID to int64 to store in SQLite3.
+//
+// Intentional: convert unsigned integer to signed preserving bit representation.
+// Large uint64 values will map to negative int64 values. This is intended since
+// the node IDs are used as discrete keys and never used for arithmetic.
+func toInt64(nodeID uint64) int64 {
+	return int64(nodeID) // #nosec
+}
+
+// toUint64 converts the stored node ID back to uint64. It is the reverse of
+// toInt64().
+func toUint64(nodeID int64) uint64 {
+	return uint64(nodeID) // #nosec
+}
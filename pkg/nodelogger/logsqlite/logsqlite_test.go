@@ -0,0 +1,721 @@
+package logsqlite
+
+import (
+	"bytes"
+	"io"
+	"math"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/KEINOS/go-bayes"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNew_bad_path(t *testing.T) {
+	t.Parallel()
+
+	// Directory does not exist
+	pathDB := filepath.Join(t.TempDir(), "unknown", "dir", "test.db")
+
+	nodeLog, err := New(pathDB, 12345)
+
+	require.Error(t, err, "it should be an error if the database can not be created")
+	require.Nil(t, nodeLog, "it should be nil on error")
+	assert.Contains(t, err.Error(), "failed to create the tables")
+}
+
+func TestNodeLog_closed_database(t *testing.T) {
+	t.Parallel()
+
+	nodeLog, err := New(MemoryPath, 12345)
+	require.NoError(t, err)
+	require.NoError(t, nodeLog.Close())
+
+	require.NotPanics(t, func() { nodeLog.Update(1, 2) })
+	require.Zero(t, nodeLog.TotalAccesses())
+
+	err = nodeLog.Err()
+
+	require.Error(t, err, "accessing the closed database should be kept as an error")
+	assert.Contains(t, err.Error(), "failed to begin the transaction")
+}
+
+func TestNodeLog_persistence(t *testing.T) {
+	t.Parallel()
+
+	pathDB := filepath.Join(t.TempDir(), "test.db")
+
+	// First process
+	{
+		nodeLog, err := New(pathDB, 12345)
+		require.NoError(t, err)
+
+		nodeLog.Update(1, 2)
+		nodeLog.Update(1, 3)
+		nodeLog.Update(1, 2)
+
+		require.NoError(t, nodeLog.Err())
+		require.NoError(t, nodeLog.Close())
+	}
+
+	// Second process
+	{
+		nodeLog, err := New(pathDB, 12345)
+		require.NoError(t, err)
+
+		defer nodeLog.Close()
+
+		assert.Equal(t, 3.0, nodeLog.TotalAccesses())
+		assert.Equal(t, 3.0, nodeLog.FromA(1))
+		assert.Equal(t, 2.0, nodeLog.ToB(2))
+		assert.Equal(t, 1.0, nodeLog.ToB(3))
+		assert.Equal(t, 2.0, nodeLog.FromAToB(1, 2))
+		assert.Equal(t, 1.0, nodeLog.FromAToB(1, 3))
+		require.NoError(t, nodeLog.Err())
+	}
+}
+
+func TestNodeLog_scope_and_large_id(t *testing.T) {
+	t.Parallel()
+
+	pathDB := filepath.Join(t.TempDir(), "test.db")
+	maxID := uint64(0xffffffffffffffff)
+
+	nodeA, err := New(pathDB, 1)
+	require.NoError(t, err)
+
+	defer nodeA.Close()
+
+	nodeB, err := New(pathDB, maxID)
+	require.NoError(t, err)
+
+	defer nodeB.Close()
+
+	nodeA.Update(maxID, maxID)
+	nodeB.Update(1, 2)
+	nodeB.Update(1, 2)
+
+	assert.Equal(t, 1.0, nodeA.FromAToB(maxID, maxID), "IDs over int64 should be stored as is")
+	assert.Equal(t, 0.0, nodeA.FromAToB(1, 2), "records of the other scope should not be mixed")
+	assert.Equal(t, 2.0, nodeB.FromAToB(1, 2))
+	assert.Equal(t, 0.0, nodeB.FromAToB(maxID, maxID), "records of the other scope should not be mixed")
+
+	require.NoError(t, nodeA.Err())
+	require.NoError(t, nodeB.Err())
+}
+
+func TestNodeLog_zero_division(t *testing.T) {
+	t.Parallel()
+
+	nodeLog, err := New(MemoryPath, 12345)
+	require.NoError(t, err)
+
+	defer nodeLog.Close()
+
+	require.InDelta(t, 0.0, nodeLog.Predict(1, 2), 0)
+	require.InDelta(t, 0.0, nodeLog.PriorPfromAtoB(1, 2), 0)
+	require.InDelta(t, 0.0, nodeLog.PriorPNotFromAtoB(1, 2), 0)
+	require.InDelta(t, 0.0, nodeLog.PriorPtoB(1), 0)
+	require.NotPanics(t, func() { nodeLog.Update(1, 2) })
+	require.NoError(t, nodeLog.Err())
+}
+
+func TestNodeLog_concurrent_access(t *testing.T) {
+	t.Parallel()
+
+	const (
+		numWorkers = 4
+		numLoops   = 50
+	)
+
+	nodeLog, err := New(filepath.Join(t.TempDir(), "test.db"), 12345)
+	require.NoError(t, err)
+
+	defer nodeLog.Close()
+
+	var wg sync.WaitGroup
+
+	for i := 0; i < numWorkers; i++ {
+		wg.Add(2)
+
+		go func() {
+			defer wg.Done()
+
+			for j := 0; j < numLoops; j++ {
+				nodeLog.Update(uint64(j%3), uint64(j%5)) // #nosec
+			}
+		}()
+
+		go func() {
+			defer wg.Done()
+
+			for j := 0; j < numLoops; j++ {
+				_ = nodeLog.Predict(uint64(j%3), uint64(j%5)) // #nosec
+			}
+		}()
+	}
+
+	wg.Wait()
+
+	require.NoError(t, nodeLog.Err())
+	require.Equal(t, float64(numWorkers*numLoops), nodeLog.TotalAccesses())
+}
+
+func TestNodeLog_Decrement(t *testing.T) {
+	t.Parallel()
+
+	nodeLog, err := New(MemoryPath, 12345)
+	require.NoError(t, err)
+
+	defer nodeLog.Close()
+
+	nodeLog.Update(1, 2)
+	nodeLog.Update(1, 2)
+	nodeLog.Update(1, 3)
+
+	nodeLog.Decrement(1, 2)
+	nodeLog.Decrement(4, 5) // never updated
+
+	assert.Equal(t, 2.0, nodeLog.TotalAccesses())
+	assert.Equal(t, 2.0, nodeLog.FromA(1))
+	assert.Equal(t, 1.0, nodeLog.FromAToB(1, 2))
+
+	nodeLog.Decrement(1, 2)
+	nodeLog.Decrement(1, 2) // already removed
+	nodeLog.Decrement(1, 3)
+
+	assert.Equal(t, 0.0, nodeLog.TotalAccesses())
+	assert.Equal(t, 0.0, nodeLog.FromA(1))
+	assert.Equal(t, 0.0, nodeLog.ToB(2))
+	require.NoError(t, nodeLog.Err())
+
+	// The records reaching 0 should be deleted
+	records, err := nodeLog.export()
+	require.NoError(t, err)
+	assert.Empty(t, records.FromA)
+	assert.Empty(t, records.ToB)
+	assert.Empty(t, records.FromAToB)
+}
+
+func TestNodeLog_Decrement_closed_database(t *testing.T) {
+	t.Parallel()
+
+	nodeLog, err := New(MemoryPath, 12345)
+	require.NoError(t, err)
+	require.NoError(t, nodeLog.Close())
+
+	nodeLog.Decrement(1, 2)
+
+	require.Error(t, nodeLog.Err())
+}
+
+func TestNodeLog_UpdateN(t *testing.T) {
+	t.Parallel()
+
+	nodeLog, err := New(MemoryPath, 12345)
+	require.NoError(t, err)
+
+	defer nodeLog.Close()
+
+	nodeLog.UpdateN(1, 2, 2.5)
+	nodeLog.UpdateN(1, 2, 1)
+	nodeLog.UpdateN(1, 3, 0.5)
+
+	// Invalid counts should be ignored
+	nodeLog.UpdateN(1, 3, 0)
+	nodeLog.UpdateN(1, 3, -1)
+	nodeLog.UpdateN(1, 3, math.NaN())
+	nodeLog.UpdateN(1, 3, math.Inf(1))
+
+	require.NoError(t, nodeLog.Err())
+	assert.InDelta(t, 4.0, nodeLog.TotalAccesses(), 1e-9)
+	assert.InDelta(t, 3.5, nodeLog.FromAToB(1, 2), 1e-9)
+	assert.InDelta(t, 0.5, nodeLog.ToB(3), 1e-9)
+	assert.InDelta(t, 3.5/4.0, nodeLog.PriorPfromAtoB(1, 2), 1e-9)
+
+	// Decrement should subtract only the remaining count below 1
+	nodeLog.Decrement(1, 3)
+
+	assert.InDelta(t, 3.5, nodeLog.TotalAccesses(), 1e-9)
+	assert.Zero(t, nodeLog.ToB(3))
+	assert.Zero(t, nodeLog.FromAToB(1, 3))
+	require.NoError(t, nodeLog.Err())
+}
+
+func TestNodeLog_UpdateN_closed_database(t *testing.T) {
+	t.Parallel()
+
+	nodeLog, err := New(MemoryPath, 12345)
+	require.NoError(t, err)
+	require.NoError(t, nodeLog.Close())
+
+	nodeLog.UpdateN(1, 2, 2)
+
+	require.Error(t, nodeLog.Err())
+}
+
+func TestNodeLog_batch(t *testing.T) {
+	t.Parallel()
+
+	nodeLog, err := New(MemoryPath, 12345)
+	require.NoError(t, err)
+
+	defer nodeLog.Close()
+
+	require.Error(t, nodeLog.Commit(), "commit without begin should be an error")
+	require.Error(t, nodeLog.Rollback(), "rollback without begin should be an error")
+
+	// Committed batch
+	require.NoError(t, nodeLog.Begin())
+	require.Error(t, nodeLog.Begin(), "nested batch should be an error")
+
+	nodeLog.Update(1, 2)
+	nodeLog.Update(1, 2)
+	nodeLog.Decrement(1, 2)
+	nodeLog.StoreClass(2, []byte("foo"))
+
+	// The queries during the batch read the updates of the batch
+	assert.Equal(t, 1.0, nodeLog.FromAToB(1, 2))
+
+	require.NoError(t, nodeLog.Commit())
+
+	// Rolled back batch
+	require.NoError(t, nodeLog.Begin())
+
+	nodeLog.Update(1, 3)
+	nodeLog.DeleteClass(2)
+
+	require.NoError(t, nodeLog.Rollback())
+
+	assert.Equal(t, 1.0, nodeLog.TotalAccesses())
+	assert.Equal(t, 1.0, nodeLog.FromAToB(1, 2))
+	assert.Zero(t, nodeLog.FromAToB(1, 3))
+
+	classes, err := nodeLog.Classes()
+	require.NoError(t, err)
+	assert.Equal(t, map[uint64][]byte{2: []byte("foo")}, classes)
+	require.NoError(t, nodeLog.Err())
+}
+
+func TestNodeLog_batch_read_only_database(t *testing.T) {
+	t.Parallel()
+
+	pathDB := filepath.Join(t.TempDir(), "test.db")
+
+	nodeLog, err := New(pathDB, 12345)
+	require.NoError(t, err)
+
+	nodeLog.Update(1, 2)
+	require.NoError(t, nodeLog.Close())
+
+	nodeLog, err = New("file:"+pathDB+"?mode=ro", 12345)
+	require.NoError(t, err)
+
+	defer nodeLog.Close()
+
+	require.NoError(t, nodeLog.Begin())
+
+	nodeLog.Update(1, 3)
+
+	err = nodeLog.Commit()
+
+	require.Error(t, err, "writing to the read-only database should be an error on commit")
+	assert.Contains(t, err.Error(), "failed to update the records")
+	assert.Equal(t, 1.0, nodeLog.TotalAccesses(), "the records should be left unchanged")
+	require.NoError(t, nodeLog.Err(), "the error of the batch should be returned by Commit() only")
+
+	require.NoError(t, nodeLog.Begin(), "the batch should be available after the error")
+	require.NoError(t, nodeLog.Commit(), "the error of the previous batch should not be returned")
+}
+
+func TestNodeLog_Err_cleared(t *testing.T) {
+	t.Parallel()
+
+	nodeLog, err := New(MemoryPath, 12345)
+	require.NoError(t, err)
+
+	defer nodeLog.Close()
+
+	// Reading a dropped table fails
+	_, err = nodeLog.db.Exec(`ALTER TABLE total_accesses RENAME TO total_accesses_old`)
+	require.NoError(t, err)
+
+	assert.Zero(t, nodeLog.TotalAccesses())
+	require.Error(t, nodeLog.Err(), "the read error should be kept")
+	assert.Contains(t, nodeLog.Err().Error(), "failed to query the count")
+
+	_, err = nodeLog.db.Exec(`ALTER TABLE total_accesses_old RENAME TO total_accesses`)
+	require.NoError(t, err)
+
+	require.Error(t, nodeLog.Err(), "the error should be kept until the next batch")
+
+	require.NoError(t, nodeLog.Begin())
+	require.NoError(t, nodeLog.Err(), "the error should be cleared on begin")
+
+	nodeLog.Update(1, 2)
+
+	require.NoError(t, nodeLog.Commit())
+	require.NoError(t, nodeLog.Err())
+
+	// Failure outside the batch followed by a successful batch
+	nodeLog.StoreClass(1, nil) // NOT NULL constraint
+	require.Error(t, nodeLog.Err())
+
+	require.NoError(t, nodeLog.Begin())
+	require.NoError(t, nodeLog.Commit())
+	require.NoError(t, nodeLog.Err(), "the error should not poison the following batches")
+	assert.Equal(t, 1.0, nodeLog.TotalAccesses())
+}
+
+func TestNodeLog_batch_closed_database(t *testing.T) {
+	t.Parallel()
+
+	nodeLog, err := New(MemoryPath, 12345)
+	require.NoError(t, err)
+
+	require.NoError(t, nodeLog.Begin())
+	require.NoError(t, nodeLog.Close(), "the batch should be rolled back on close")
+
+	err = nodeLog.Begin()
+
+	require.Error(t, err, "closed database should be an error")
+	assert.Contains(t, err.Error(), "failed to begin the batch")
+}
+
+func TestPredictor_read_only_database(t *testing.T) {
+	t.Parallel()
+
+	pathDB := filepath.Join(t.TempDir(), "test.db")
+
+	predictor, err := bayes.NewPredictor(bayes.WithStorage(bayes.SQLite3Storage), bayes.WithSQLite3Path(pathDB))
+	require.NoError(t, err)
+
+	require.NoError(t, predictor.Train([]any{"a", "b"}))
+	require.NoError(t, predictor.Close())
+
+	predictor, err = bayes.NewPredictor(
+		bayes.WithStorage(bayes.SQLite3Storage),
+		bayes.WithSQLite3Path("file:"+pathDB+"?mode=ro"),
+	)
+	require.NoError(t, err)
+
+	defer predictor.Close()
+
+	err = predictor.Train([]any{"a", "c", "d"})
+
+	require.Error(t, err, "training a read-only database should be an error")
+	assert.Contains(t, err.Error(), "failed to update the node logger")
+
+	// None of the items should be trained, including the classes
+	classID, err := predictor.Predict([]any{"a"})
+	require.NoError(t, err)
+	assert.Equal(t, "b", predictor.GetClass(classID))
+
+	_, err = predictor.Predict([]any{"c"})
+	require.ErrorIs(t, err, bayes.ErrUnknownContext)
+
+	predictions, err := predictor.PredictTopK([]any{"a"}, 0)
+	require.NoError(t, err)
+	assert.Len(t, predictions, 1, "records of the failed training should not be added")
+}
+
+func TestPredictor_read_error(t *testing.T) {
+	t.Parallel()
+
+	pathDB := filepath.Join(t.TempDir(), "test.db")
+
+	predictor, err := bayes.NewPredictor(bayes.WithStorage(bayes.SQLite3Storage), bayes.WithSQLite3Path(pathDB))
+	require.NoError(t, err)
+
+	defer predictor.Close()
+
+	require.NoError(t, predictor.Train([]any{"a", "b"}))
+
+	// Break the database from another connection
+	other, err := New(pathDB, 0)
+	require.NoError(t, err)
+
+	_, err = other.db.Exec(`ALTER TABLE from_a_to_b RENAME TO from_a_to_b_old`)
+	require.NoError(t, err)
+
+	_, err = predictor.Predict([]any{"a"})
+	require.Error(t, err, "broken database should not look like an empty model")
+	require.NotErrorIs(t, err, bayes.ErrUnknownContext)
+	assert.Contains(t, err.Error(), "failed to read the node logger")
+
+	_, err = predictor.Score([]any{"a", "b"})
+	require.Error(t, err)
+
+	// Repair the database. The next successful training clears the error.
+	_, err = other.db.Exec(`ALTER TABLE from_a_to_b_old RENAME TO from_a_to_b`)
+	require.NoError(t, err)
+	require.NoError(t, other.Close())
+
+	require.NoError(t, predictor.Train([]any{"a", "b"}))
+
+	classID, err := predictor.Predict([]any{"a"})
+	require.NoError(t, err)
+	assert.Equal(t, "b", predictor.GetClass(classID))
+}
+
+// ----------------------------------------------------------------------------
+//  bayes.SQLite3Storage
+// ----------------------------------------------------------------------------
+
+//nolint:paralleltest // disable parallel test due to global variable change
+func TestSQLite3Storage(t *testing.T) {
+	defer bayes.SetSQLite3Path(bayes.SQLite3PathDefault)
+
+	bayes.SetSQLite3Path(filepath.Join(t.TempDir(), "test.db"))
+
+	scopeID := uint64(100)
+
+	trainer, err := bayes.New(bayes.SQLite3Storage, scopeID)
+	require.NoError(t, err)
+	require.Equal(t, scopeID, trainer.ID())
+
+	trainer.Update(1, 2)
+	trainer.Update(1, 2)
+
+	closer, ok := trainer.(io.Closer)
+	require.True(t, ok, "SQLite3 storage should be closable")
+	require.NoError(t, closer.Close())
+
+	// Reopen the same file. The records should survive.
+	trainer, err = bayes.New(bayes.SQLite3Storage, scopeID)
+	require.NoError(t, err)
+
+	defer trainer.(io.Closer).Close()
+
+	require.InDelta(t, float64(1), trainer.PriorPtoB(2), 0)
+}
+
+//nolint:paralleltest // disable parallel test due to global variable change
+func TestSQLite3Storage_bad_path(t *testing.T) {
+	defer bayes.SetSQLite3Path(bayes.SQLite3PathDefault)
+
+	bayes.SetSQLite3Path(filepath.Join(t.TempDir(), "unknown", "test.db"))
+
+	trainer, err := bayes.New(bayes.SQLite3Storage, 0)
+
+	require.Error(t, err, "it should be an error if the database file can not be created")
+	require.Nil(t, trainer, "it should be nil on error")
+
+	assert.Contains(t, err.Error(), "failed to create SQLite3 storage")
+}
+
+//nolint:paralleltest // disable parallel test due to global variable change
+func TestSQLite3Storage_Load(t *testing.T) {
+	defer func() {
+		bayes.SetStorage(bayes.StorageDefault)
+		bayes.SetSQLite3Path(bayes.SQLite3PathDefault)
+		bayes.Reset()
+	}()
+
+	bayes.Reset()
+
+	require.NoError(t, bayes.Train([]string{"foo", "bar", "foo", "bar", "baz"}))
+
+	var saved bytes.Buffer
+
+	require.NoError(t, bayes.Save(&saved))
+
+	// Load the in-memory model into the SQLite3 storage
+	bayes.SetStorage(bayes.SQLite3Storage)
+	bayes.SetSQLite3Path(filepath.Join(t.TempDir(), "test.db"))
+	bayes.Reset()
+
+	require.NoError(t, bayes.Load(&saved))
+
+	classID, err := bayes.Predict([]string{"foo"})
+	require.NoError(t, err)
+	assert.Equal(t, "bar", bayes.GetClass(classID))
+}
+
+func TestPredictor_sqlite3_storage(t *testing.T) {
+	t.Parallel()
+
+	pathDB := filepath.Join(t.TempDir(), "test.db")
+
+	predictor, err := bayes.NewPredictor(
+		bayes.WithStorage(bayes.SQLite3Storage),
+		bayes.WithScopeID(100),
+		bayes.WithSQLite3Path(pathDB),
+	)
+	require.NoError(t, err)
+	require.FileExists(t, pathDB)
+	require.NoError(t, predictor.Train([]any{"foo", "bar"}))
+	require.NoError(t, predictor.Close())
+
+	// The records are stored under the scope ID
+	nodeLog, err := New(pathDB, 100)
+	require.NoError(t, err)
+
+	defer nodeLog.Close()
+
+	assert.Equal(t, 2.0, nodeLog.TotalAccesses(), "the transition and its context should be stored")
+}
+
+func TestPredictor_Close(t *testing.T) {
+	t.Parallel()
+
+	predictor, err := bayes.NewPredictor(
+		bayes.WithStorage(bayes.SQLite3Storage),
+		bayes.WithSQLite3Path(filepath.Join(t.TempDir(), "test.db")),
+	)
+	require.NoError(t, err)
+
+	require.NoError(t, predictor.Close())
+	require.NoError(t, predictor.Close(), "closing twice should not be an error")
+
+	_, err = predictor.Predict([]any{"foo"})
+
+	require.Error(t, err, "closed predictor should be an error on predict")
+	assert.Contains(t, err.Error(), "predictor is not initialized")
+
+	// Reset re-opens the database
+	require.NoError(t, predictor.Reset())
+	require.NoError(t, predictor.Train([]any{"foo", "bar"}))
+
+	// Reset keeps the trained data of SQLite3 storage
+	require.NoError(t, predictor.Reset())
+
+	classID, err := predictor.Predict([]any{"foo"})
+	require.NoError(t, err)
+	assert.Equal(t, "bar", predictor.GetClass(classID))
+	require.NoError(t, predictor.Close())
+}
+
+func TestPredictor_restart(t *testing.T) {
+	t.Parallel()
+
+	pathDB := filepath.Join(t.TempDir(), "test.db")
+	opts := []bayes.Option{bayes.WithStorage(bayes.SQLite3Storage), bayes.WithSQLite3Path(pathDB)}
+
+	predictor, err := bayes.NewPredictor(opts...)
+	require.NoError(t, err)
+
+	require.NoError(t, predictor.Train([]any{"a", "b", "c"}))
+	require.NoError(t, predictor.Train([]any{"x", "y"}))
+
+	classY, err := predictor.Predict([]any{"x"})
+	require.NoError(t, err)
+
+	require.NoError(t, predictor.Untrain([]any{"x", "y"}))
+	require.NoError(t, predictor.Close())
+
+	// Reopen the same file as a restarted process
+	predictor, err = bayes.NewPredictor(opts...)
+	require.NoError(t, err)
+
+	classB, err := predictor.Predict([]any{"a"})
+	require.NoError(t, err, "classes should be restored from the database")
+	assert.Equal(t, "b", predictor.GetClass(classB))
+	assert.Nil(t, predictor.GetClass(classY), "untrained class should not be restored")
+
+	// Loaded model is kept in the database as well
+	trained, err := bayes.NewPredictor()
+	require.NoError(t, err)
+	require.NoError(t, trained.Train([]any{"foo", "bar"}))
+
+	var saved bytes.Buffer
+
+	require.NoError(t, trained.Save(&saved))
+	require.NoError(t, predictor.Load(&saved))
+	require.NoError(t, predictor.Close())
+
+	predictor, err = bayes.NewPredictor(opts...)
+	require.NoError(t, err)
+
+	classID, err := predictor.Predict([]any{"foo"})
+	require.NoError(t, err)
+	assert.Equal(t, "bar", predictor.GetClass(classID))
+	assert.Nil(t, predictor.GetClass(classB), "replaced class should not be restored")
+	require.NoError(t, predictor.Close())
+}
+
+func TestPredictor_TrainWeighted(t *testing.T) {
+	t.Parallel()
+
+	predictor, err := bayes.NewPredictor(
+		bayes.WithStorage(bayes.SQLite3Storage),
+		bayes.WithSQLite3Path(filepath.Join(t.TempDir(), "test.db")),
+	)
+	require.NoError(t, err)
+
+	defer predictor.Close()
+
+	require.NoError(t, predictor.TrainWeighted([]any{"a", "b"}, 1.5))
+	require.NoError(t, predictor.TrainWeighted([]any{"a", "c"}, 0.5))
+
+	predictions, err := predictor.PredictTopK([]any{"a"}, 0)
+	require.NoError(t, err)
+	require.Len(t, predictions, 2)
+	assert.Equal(t, "b", predictions[0].Raw, "heavier sequence should be more probable")
+	assert.Greater(t, predictions[0].Probability, predictions[1].Probability)
+}
+
+func TestPredictor_Untrain(t *testing.T) {
+	t.Parallel()
+
+	predictor, err := bayes.NewPredictor(
+		bayes.WithStorage(bayes.SQLite3Storage),
+		bayes.WithSQLite3Path(filepath.Join(t.TempDir(), "test.db")),
+	)
+	require.NoError(t, err)
+
+	defer predictor.Close()
+
+	require.NoError(t, predictor.Train([]any{"a", "b"}))
+	require.NoError(t, predictor.Train([]any{"a", "c", "b"}))
+
+	predictions, err := predictor.PredictTopK([]any{"a"}, 0)
+	require.NoError(t, err)
+	require.Len(t, predictions, 2)
+	require.Equal(t, "c", predictions[1].Raw)
+
+	classC := predictions[1].ClassID
+
+	require.NoError(t, predictor.Untrain([]any{"a", "c", "b"}))
+
+	predictions, err = predictor.PredictTopK([]any{"a"}, 0)
+	require.NoError(t, err)
+	require.Len(t, predictions, 1)
+	assert.Equal(t, "b", predictions[0].Raw)
+	assert.Nil(t, predictor.GetClass(classC))
+
+	_, err = predictor.Predict([]any{"a", "c"})
+	require.ErrorIs(t, err, bayes.ErrUnknownContext)
+}
+
+func TestModel_restart(t *testing.T) {
+	t.Parallel()
+
+	opts := []bayes.Option{
+		bayes.WithStorage(bayes.SQLite3Storage),
+		bayes.WithSQLite3Path(filepath.Join(t.TempDir(), "test.db")),
+		bayes.WithBoundaries(),
+	}
+
+	model, err := bayes.NewModel[string](opts...)
+	require.NoError(t, err)
+	require.NoError(t, model.Train([]string{"a", "b"}))
+	require.NoError(t, model.Close())
+
+	// Reopen the same file as a restarted process
+	model, err = bayes.NewModel[string](opts...)
+	require.NoError(t, err)
+
+	next, err := model.Predict([]string{"a"})
+	require.NoError(t, err, "classes should be restored from the database")
+	assert.Equal(t, "b", next)
+	require.NoError(t, model.Close())
+
+	// The restored classes of the other type should be an error
+	_, err = bayes.NewModel[int](opts...)
+
+	require.Error(t, err, "classes of the other type should be an error")
+	assert.Contains(t, err.Error(), "is not of Model[int]. Given: string")
+}
@@ -0,0 +1,1195 @@
+package bayes
+
+import (
+	"bytes"
+	"io"
+	"math"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/KEINOS/go-bayes/pkg/nodelogger/logmem"
+	"github.com/pkg/errors"
+)
+
+// ----------------------------------------------------------------------------
+//  Errors
+// ----------------------------------------------------------------------------
+
+var (
+	// ErrUnknownContext is returned by Predict when none of the classes have
+	// followed the given items in the training set. Use errors.Is() to check.
+	ErrUnknownContext = errors.New("unknown context. No class has followed the given items")
+	// ErrBelowThreshold is returned by Predict when the probability of the most
+	// probable class is below the minimum probability. See WithMinProbability().
+	ErrBelowThreshold = errors.New("probability of the prediction is below the threshold")
+	// ErrNoProvenance is returned by the methods of the provenance if the
+	// provenance is not enabled. See WithProvenance().
+	ErrNoProvenance = errors.New("provenance is not enabled")
+	// ErrHashCollision is returned by the methods of Model when different items
+	// share the same class ID. See WithTypedHashing() to reduce the collisions
+	// between the types.
+	ErrHashCollision = errors.New("hash collision. Different items share the same class ID")
+	// ErrEndOfSequence is returned by Model when EndOfSequence is predicted as
+	// the next item. See WithBoundaries().
+	ErrEndOfSequence = errors.New("end of the sequence is predicted")
+)
+
+// ----------------------------------------------------------------------------
+//  Type: Predictor
+// ----------------------------------------------------------------------------
+
+// Predictor is a trainable model which holds its own NodeLogger and class list.
+// Unlike the convenient functions, which share a single default predictor in
+// the package, several Predictor instances can be used in the same process
+// independently.
+//
+// The methods are safe for concurrent use. Predictions do not block each other,
+// while training blocks the predictions until it finishes.
+//
+// Use `NewPredictor()` to create an instance.
+type Predictor struct {
+	// nodeLogger logs the transitions of the items.
+	nodeLogger NodeLogger
+	// classes is the list of the classes appeared in the training set.
+	classes map[uint64]_Class
+	// sqlite3Path is the database file path used by the SQLite3Storage.
+	sqlite3Path string
+	// storage is the storage type of the nodeLogger.
+	storage Storage
+	// boundaries is true if the sequences are trained between the markers.
+	boundaries bool
+	// minProbability is the threshold of the probability to predict.
+	minProbability float64
+	// maxOrder is the maximum number of the last items used as the context.
+	maxOrder int
+	// memOptions are the options of the node logger of the MemoryStorage.
+	memOptions []logmem.Option
+	// provenance is true if the provenance index is enabled.
+	provenance bool
+	// typedHashing is true if the type of the item is mixed into the item ID.
+	typedHashing bool
+	// quantizer maps the numeric items to their bins. Nil if not set.
+	quantizer Quantizer
+	// mu protects the fields above.
+	mu sync.RWMutex
+	// scopeID is the scope ID of the nodeLogger.
+	scopeID uint64
+}
+
+// ----------------------------------------------------------------------------
+//  Type: Option
+// ----------------------------------------------------------------------------
+
+// Option is a functional option of `NewPredictor()`.
+type Option func(*Predictor)
+
+// WithBoundaries trains each sequence between StartOfSequence and EndOfSequence,
+// so that the first item of a sequence and the end of it can be predicted. Use
+// StartOfSequence or the empty context to predict the first item, and check if
+// the class predicted is EndOfSequence. Default: disabled.
+//
+// It applies to every sequence given to the training methods, including each
+// sequence of `TrainCorpus()` and the streams of `TrainReader()` and
+// `TrainChan()`. The empty sequences are not trained. Untrain the sequences
+// with the same option as the training.
+func WithBoundaries() Option {
+	return func(p *Predictor) {
+		p.boundaries = true
+	}
+}
+
+// WithHalfLife enables the time-decay of the trained records, so that the
+// recent behavior dominates the predictions. The weight of a training halves
+// every halfLife of the elapsed time. Zero or negative disables it. Default: 0
+// (no decay). Supported only by the MemoryStorage.
+func WithHalfLife(halfLife time.Duration) Option {
+	return func(p *Predictor) {
+		p.memOptions = append(p.memOptions, logmem.WithHalfLife(halfLife))
+	}
+}
+
+// WithHalfLifeUpdates is similar to `WithHalfLife()` but the weight halves by
+// the number of the records updated. Note that training n items updates about
+// n * order records. Zero or negative disables it. Default: 0 (no decay).
+// Supported only by the MemoryStorage.
+func WithHalfLifeUpdates(numUpdates int) Option {
+	return func(p *Predictor) {
+		p.memOptions = append(p.memOptions, logmem.WithHalfLifeUpdates(numUpdates))
+	}
+}
+
+// WithMaxOrder sets the maximum order of the context, which is the maximum
+// number of the last items used as the context on training and predicting.
+// Zero or negative means unlimited. Default: 0 (unlimited).
+//
+// On training a sequence of n items, every suffix of the sequence seen so far
+// is trained as a context. So, unlimited order costs O(n^2) hashes and up to
+// O(n^2) stored contexts (flows), while the order of N costs O(n*N).
+//
+// As a rule of thumb:
+//   - 1: Simple Markov chain. Smallest memory, but only the last item matters.
+//   - 2-5: Captures short phrases. Good balance for most event streams.
+//   - 6-10: Better for long and repetitive patterns, such as melodies. The
+//     memory grows linearly to the order.
+//   - Unlimited: Memorizes the whole sequence. Fine for short sequences but
+//     not suitable for long streams. The stream trainers, such as
+//     `TrainReader()`, cap it at StreamContextMax.
+//
+// Since Predict backs off to the shorter context, a smaller order rarely ends
+// up with ErrUnknownContext, but may miss the patterns longer than the order.
+func WithMaxOrder(order int) Option {
+	return func(p *Predictor) {
+		p.maxOrder = order
+	}
+}
+
+// WithMinProbability sets the minimum probability of the prediction. If the
+// normalized probability of the most probable class is below the threshold,
+// Predict abstains by returning ErrBelowThreshold. Also, PredictTopK omits the
+// candidates below the threshold. Default: 0 (never abstain).
+func WithMinProbability(threshold float64) Option {
+	return func(p *Predictor) {
+		p.minProbability = threshold
+	}
+}
+
+// WithProvenance enables the provenance index, which records the sources, such
+// as the document IDs, given via `TrainFrom()`. Use `Sources()` to find which
+// sources contributed to a transition, and `UntrainFrom()` to remove them.
+// Default: disabled. Supported only by the MemoryStorage.
+//
+// Note that the index costs memory per record and source. It is saved via
+// `Save()` and restored by `Load()` if the loading predictor also has this
+// option.
+func WithProvenance() Option {
+	return func(p *Predictor) {
+		p.provenance = true
+		p.memOptions = append(p.memOptions, logmem.WithProvenance())
+	}
+}
+
+// WithQuantizer sets the quantizer of the numeric items, so that the close
+// values share the same class. The numbers of any type are converted to float64
+// and replaced by the representative value of their bin, which `GetClass()`
+// returns. Default: nil (the floats are distinct by their exact bits).
+//
+// The available quantizers are FixedWidth, LogScale and QuantileBins (see
+// `NewQuantileBins()`). Note that the quantizer is not saved via `Save()`. Set
+// the same one on loading.
+//
+// A nil quantizer, including a nil pointer such as `(*QuantileBins)(nil)`, is
+// rejected and leaves the floats distinct by their exact bits.
+func WithQuantizer(quantizer Quantizer) Option {
+	return func(p *Predictor) {
+		if isNilQuantizer(quantizer) {
+			p.quantizer = nil
+
+			return
+		}
+
+		p.quantizer = quantizer
+	}
+}
+
+// WithScopeID sets the scope ID of the predictor. Default: ScopeIDDefault.
+func WithScopeID(scopeID uint64) Option {
+	return func(p *Predictor) {
+		p.scopeID = scopeID
+	}
+}
+
+// WithSQLite3Path sets the database file path used by the SQLite3Storage.
+// Default: SQLite3PathDefault.
+func WithSQLite3Path(path string) Option {
+	return func(p *Predictor) {
+		p.sqlite3Path = path
+	}
+}
+
+// WithSmoothing sets the smoothing strategy of the node logger, so that the
+// classes never seen after a known context still get a small probability
+// instead of exactly 0. Default: nil (no smoothing).
+//
+// The available strategies are logmem.Additive (logmem.Laplace()),
+// logmem.GoodTuring, logmem.WittenBell and logmem.KneserNey. Supported only by
+// the MemoryStorage. The unknown contexts are not smoothed, so Predict still
+// backs off to the shorter context.
+func WithSmoothing(smoother logmem.Smoother) Option {
+	return func(p *Predictor) {
+		p.memOptions = append(p.memOptions, logmem.WithSmoothing(smoother))
+	}
+}
+
+// WithStorage sets the storage of the predictor. Default: StorageDefault.
+// The storages other than MemoryStorage must be registered. See
+// `RegisterStorage()`.
+func WithStorage(storage Storage) Option {
+	return func(p *Predictor) {
+		p.storage = storage
+	}
+}
+
+// WithTypedHashing mixes the type of the item into its ID, so the items of the
+// different types never share the same class. e.g. true, int(1) and uint16(1)
+// are all the class ID 1 by default, but 3 different classes with this option.
+// Default: disabled.
+//
+// Note that it changes the class IDs, so `HashTrans()` no longer returns the
+// class ID of an item. The models saved via `Save()` keep their hashing mode on
+// `Load()`.
+func WithTypedHashing() Option {
+	return func(p *Predictor) {
+		p.typedHashing = true
+	}
+}
+
+// WithWindow enables the sliding window, where only the last numUpdates records
+// updated count toward the predictions. The records fallen out of the window
+// are subtracted exactly. Note that training n items updates about n * order
+// records. Zero or negative disables it. Default: 0 (no window). Supported only
+// by the MemoryStorage.
+//
+// The classes stay in the class list even after they fall out of the window.
+func WithWindow(numUpdates int) Option {
+	return func(p *Predictor) {
+		p.memOptions = append(p.memOptions, logmem.WithWindow(numUpdates))
+	}
+}
+
+// WithWindowDuration is similar to `WithWindow()` but the window is the last
+// duration. The records older than the duration stop counting toward the
+// predictions even without further training. Zero or negative disables it.
+// Default: 0 (no window). Supported only by the MemoryStorage.
+func WithWindowDuration(duration time.Duration) Option {
+	return func(p *Predictor) {
+		p.memOptions = append(p.memOptions, logmem.WithWindowDuration(duration))
+	}
+}
+
+// ----------------------------------------------------------------------------
+//  Type: Prediction
+// ----------------------------------------------------------------------------
+
+// Prediction is a candidate of the next class.
+type Prediction struct {
+	// Raw is the original value of the class. Same as `GetClass(ClassID)`.
+	Raw any
+	// ClassID is the ID of the class.
+	ClassID uint64
+	// Score is the raw value returned from `NodeLogger.Predict()`.
+	Score float64
+	// Probability is the score normalized across the candidates.
+	Probability float64
+	// Order is the number of the last items used as the context of the
+	// prediction. It is less than the number of the given items if the
+	// prediction backed off to the shorter context.
+	Order int
+}
+
+// ----------------------------------------------------------------------------
+//  Type: _Class (private)
+// ----------------------------------------------------------------------------
+
+// _Class holds the class ID and the original value.
+type _Class struct {
+	Raw any
+	ID  uint64
+}
+
+// ----------------------------------------------------------------------------
+//  Type: _Trainer (private)
+// ----------------------------------------------------------------------------
+
+// _Trainer holds the rolling context of a sequence being trained, so that a
+// sequence can be trained item by item.
+type _Trainer struct {
+	// predictor is the predictor to train. The caller of step must hold its lock.
+	predictor *Predictor
+	// drill is the previous items up to the maximum order.
+	drill []uint64
+	// maxDrill is the maximum number of the previous items in drill if the
+	// maximum order is unlimited. Zero means unlimited.
+	maxDrill int
+	// prevItem is the previous item.
+	prevItem uint64
+	// started is true once the first item is given.
+	started bool
+	// source is the source of the sequence recorded in the provenance. Empty
+	// if not given.
+	source string
+	// untrain is true to reverse the updates of the training.
+	untrain bool
+	// weight is the count of each update. 1 unless trained with the weight.
+	weight float64
+}
+
+// step trains the predictor with the next item of the sequence.
+func (t *_Trainer) step(itemRaw any) error {
+	itemRaw = t.predictor.quantize(itemRaw)
+
+	item, err := t.predictor.itemID(itemRaw)
+	if err != nil {
+		return errors.Wrap(err, "failed during training iteration")
+	}
+
+	t.stepID(item, itemRaw)
+
+	return nil
+}
+
+// stepID is the same as step but with the item ID converted from the quantized
+// item beforehand.
+func (t *_Trainer) stepID(item uint64, itemRaw any) {
+	p := t.predictor
+	record := t.recorder()
+
+	if !t.started {
+		t.started = true
+		t.prevItem = item
+		t.drill = t.truncate(append(t.drill, item))
+
+		return
+	}
+
+	// 101 training. Trains only the predecessor and the successor item.
+	// e.g.
+	//   previous items --> [1, 2, 3, 4, 5]
+	//   following item --> 6
+	//   will train:
+	//               [5] --> 6
+	record(t.prevItem, item)
+
+	// Drill.
+	// Trains by repeating the flow of the previous items.
+	// e.g.
+	//   previous items --> [1, 2, 3, 4, 5]
+	//   following item --> 6
+	//   will train:
+	//               [5] --> 6
+	//            [4, 5] --> 6
+	//         [3, 4, 5] --> 6
+	//      [2, 3, 4, 5] --> 6
+	//   [1, 2, 3, 4, 5] --> 6
+	// The previous items are limited to the last maxOrder items if set.
+	for i := 0; i < len(t.drill); i++ {
+		flowID, _ := HashTrans(t.drill[i:]...)
+
+		record(flowID, item)
+	}
+
+	t.prevItem = item
+	t.drill = t.truncate(append(t.drill, item))
+
+	if !t.untrain {
+		p.addClass(item, itemRaw)
+	}
+}
+
+// recorder returns the function to record a transition to the node logger.
+func (t *_Trainer) recorder() func(fromA, toB uint64) {
+	logger := t.predictor.nodeLogger
+	sourced, ok := logger.(sourceLogger)
+
+	switch {
+	case t.source != "" && ok && t.untrain:
+		return func(fromA, toB uint64) { sourced.DecrementFrom(t.source, fromA, toB) }
+	case t.source != "" && ok:
+		return func(fromA, toB uint64) { sourced.UpdateFrom(t.source, fromA, toB) }
+	case t.untrain:
+		return logger.Decrement
+	case t.weight != 1:
+		return func(fromA, toB uint64) { logger.UpdateN(fromA, toB, t.weight) }
+	}
+
+	return logger.Update
+}
+
+// truncate returns the last items up to the maximum order of the predictor, or
+// up to maxDrill if the order is unlimited.
+func (t *_Trainer) truncate(itemIDs []uint64) []uint64 {
+	itemIDs = t.predictor.truncate(itemIDs)
+
+	if t.maxDrill > 0 && len(itemIDs) > t.maxDrill {
+		return itemIDs[len(itemIDs)-t.maxDrill:]
+	}
+
+	return itemIDs
+}
+
+// ----------------------------------------------------------------------------
+//  Type: batchLogger (private)
+// ----------------------------------------------------------------------------
+
+// batchLogger is a NodeLogger which applies the updates between Begin and
+// Commit at once, such as logsqlite.NodeLog. Commit must return the error
+// occurred during the batch, if any, without applying the updates.
+type batchLogger interface {
+	Begin() error
+	Commit() error
+	Rollback() error
+}
+
+// ----------------------------------------------------------------------------
+//  Type: classLogger (private)
+// ----------------------------------------------------------------------------
+
+// classLogger is a NodeLogger which also keeps the classes, such as
+// logsqlite.NodeLog. The classes are stored in the format of `Save()`, so the
+// predictor restores them on reopening the records after the process restarts.
+type classLogger interface {
+	Classes() (map[uint64][]byte, error)
+	DeleteClass(classID uint64)
+	StoreClass(classID uint64, value []byte)
+}
+
+// ----------------------------------------------------------------------------
+//  Type: errLogger (private)
+// ----------------------------------------------------------------------------
+
+// errLogger is a NodeLogger which keeps the error occurred during the access,
+// such as on the updates and on reading the records, since the methods of
+// NodeLogger do not return errors. Such as logsqlite.NodeLog.
+type errLogger interface {
+	Err() error
+}
+
+// ----------------------------------------------------------------------------
+//  Type: sourceLogger (private)
+// ----------------------------------------------------------------------------
+
+// sourceLogger is a NodeLogger which records the sources of the updates, such
+// as logmem.NodeLog.
+type sourceLogger interface {
+	DecrementFrom(source string, fromA, toB uint64)
+	Sources(fromA, toB uint64) []string
+	UpdateFrom(source string, fromA, toB uint64)
+}
+
+// ----------------------------------------------------------------------------
+//  Constructor
+// ----------------------------------------------------------------------------
+
+// NewPredictor returns a new Predictor instance with the given options.
+func NewPredictor(opts ...Option) (*Predictor, error) {
+	predictor := &Predictor{
+		storage:     StorageDefault,
+		scopeID:     ScopeIDDefault,
+		sqlite3Path: SQLite3PathDefault,
+	}
+
+	for _, opt := range opts {
+		opt(predictor)
+	}
+
+	if err := predictor.Reset(); err != nil {
+		return nil, err
+	}
+
+	return predictor, nil
+}
+
+// ----------------------------------------------------------------------------
+//  Methods
+// ----------------------------------------------------------------------------
+
+// Close releases the resources held by the predictor, such as the database
+// connection of SQLite3Storage. The predictor must be `Reset()` or `Load()`
+// before reuse. Until then, the training and the predictions return an error.
+func (p *Predictor) Close() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return p.close()
+}
+
+// GetClass returns the original value of the given class ID.
+func (p *Predictor) GetClass(classID uint64) any {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	return p.classes[classID].Raw
+}
+
+// Predict returns the next class ID inferred from the given items.
+//
+// To get the original value of the class, use `GetClass()`.
+//
+// If the whole items have never appeared in the training set, it backs off to
+// the shorter context by dropping the oldest items one by one. So the longer
+// history than the training data can still be predicted by its latest items.
+//
+// Since the class ID 0 is a valid class (such as false, 0 and "0"), it returns
+// ErrUnknownContext if even the last item has never been followed by any class.
+// With `WithBoundaries()`, the empty items predict the first item of a sequence
+// as StartOfSequence does. It also returns ErrBelowThreshold if the prediction
+// is less probable than the threshold set via WithMinProbability().
+//
+//nolint:nonamedreturns // named return is used for readability.
+func (p *Predictor) Predict(items []any) (classID uint64, err error) {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	predictions, err := p.predict(items)
+	if err != nil {
+		return 0, err
+	}
+
+	if len(predictions) == 0 {
+		return 0, ErrUnknownContext
+	}
+
+	if predictions[0].Probability < p.minProbability {
+		return 0, ErrBelowThreshold
+	}
+
+	return predictions[0].ClassID, nil
+}
+
+// PredictTopK returns the k most probable next classes inferred from the given
+// items, in descending order of the probability. If k is zero or negative, all
+// the candidates are returned.
+//
+// The probabilities are normalized across the candidates, which are the classes
+// with a score greater than zero. Therefore, the sum of the probabilities of all
+// the candidates is 1. The candidates below the threshold set via
+// WithMinProbability() are omitted.
+//
+// Unlike Predict, it returns an empty slice without error if there are no
+// candidates.
+func (p *Predictor) PredictTopK(items []any, k int) ([]Prediction, error) {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	predictions, err := p.predict(items)
+	if err != nil {
+		return nil, err
+	}
+
+	for i, prediction := range predictions {
+		if prediction.Probability < p.minProbability {
+			predictions = predictions[:i]
+
+			break
+		}
+	}
+
+	if k > 0 && k < len(predictions) {
+		predictions = predictions[:k]
+	}
+
+	return predictions, nil
+}
+
+// Reset re-creates the node logger of the predictor with its options.
+//
+// For MemoryStorage, this discards the trained data.
+//
+// IMPORTANT: For SQLite3Storage, the trained data is NOT deleted. The records
+// and the classes of the scope ID in the database file are kept and reopened,
+// so the predictor continues from them. To start over, use another scope ID
+// via `WithScopeID()` or another database file.
+//
+// If the previous node logger holds resources such as a database connection,
+// they are released.
+func (p *Predictor) Reset() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return p.reset()
+}
+
+// Sources returns the sources, given via `TrainFrom()`, which contributed to
+// the transition from the context to the class, in ascending order. The
+// context is truncated to the maximum order as in the training. It returns
+// ErrNoProvenance if the provenance is not enabled. See `WithProvenance()`.
+func (p *Predictor) Sources(context []any, class any) ([]string, error) {
+	if !p.provenance {
+		return nil, errors.Wrap(ErrNoProvenance, "use WithProvenance() option")
+	}
+
+	if len(context) == 0 {
+		return nil, errors.New("context must not be empty")
+	}
+
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	if p.nodeLogger == nil {
+		return nil, errors.New("predictor is not initialized")
+	}
+
+	itemIDs, err := p.itemIDs(append(append([]any{}, context...), class))
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to hash the flow")
+	}
+
+	sourced, ok := p.nodeLogger.(sourceLogger)
+	if !ok {
+		return nil, errors.Errorf("%T does not support the provenance", p.nodeLogger)
+	}
+
+	classID := itemIDs[len(itemIDs)-1]
+	flowID, _ := HashTrans(p.truncate(itemIDs[:len(itemIDs)-1])...)
+
+	return sourced.Sources(flowID, classID), nil
+}
+
+// Train trains the predictor with the given items.
+//
+// Notes:
+//   - The context "train" here refers to updating the predictor's probability
+//     distribution based on the input items.
+//   - Once the item appears in the training set, the item is added to the class
+//     list.
+//   - The items are validated before any update, so on error the model is left
+//     unchanged.
+//   - The error of the node logger during the training, such as on writing to
+//     a read-only database, is returned. If the node logger supports the
+//     batch, such as logsqlite.NodeLog, none of the items are trained then.
+func (p *Predictor) Train(items []any) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.nodeLogger == nil {
+		return errors.New("predictor is not initialized")
+	}
+
+	return p.batch(func() error {
+		return p.train(p.newTrainer(), items)
+	})
+}
+
+// TrainCorpus trains the predictor with each sequence of the corpus as a
+// separate sequence, such as the sessions of the users. Unlike `Train()` with
+// the concatenated items, no transition is trained across the sequences.
+//
+// The whole corpus is validated before any update, so on error the model is
+// left unchanged.
+func (p *Predictor) TrainCorpus(corpus [][]any) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.nodeLogger == nil {
+		return errors.New("predictor is not initialized")
+	}
+
+	corpusIDs := make([][]uint64, len(corpus))
+
+	for i, items := range corpus {
+		itemIDs, err := p.itemIDs(items)
+		if err != nil {
+			return errors.Wrapf(err, "failed during training iteration. Invalid sequence at index %d", i)
+		}
+
+		corpusIDs[i] = itemIDs
+	}
+
+	return p.batch(func() error {
+		for i, itemIDs := range corpusIDs {
+			p.trainIDs(p.newTrainer(), itemIDs, corpus[i])
+		}
+
+		return nil
+	})
+}
+
+// TrainFrom is the same as `Train()` but also records the source of the items,
+// such as the ID of the training document, in the provenance index. It returns
+// ErrNoProvenance if the provenance is not enabled. See `WithProvenance()`.
+func (p *Predictor) TrainFrom(source string, items []any) error {
+	if err := p.checkSource(source); err != nil {
+		return err
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.nodeLogger == nil {
+		return errors.New("predictor is not initialized")
+	}
+
+	trainer := p.newTrainer()
+	trainer.source = source
+
+	return p.batch(func() error {
+		return p.train(trainer, items)
+	})
+}
+
+// TrainWeighted is the same as calling `Train()` with the items weight times,
+// such as for the pre-aggregated sequences with their frequencies. The weight
+// may be fractional, so the probabilities are computed from the weighted
+// counts. It returns an error if the weight is not a positive finite number.
+//
+// Note that `Untrain()` reverses the weight of 1, so call it weight times to
+// remove the items trained with an integer weight.
+func (p *Predictor) TrainWeighted(items []any, weight float64) error {
+	if weight <= 0 || math.IsInf(weight, 0) || math.IsNaN(weight) {
+		return errors.Errorf("weight must be a positive finite number. Given: %v", weight)
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.nodeLogger == nil {
+		return errors.New("predictor is not initialized")
+	}
+
+	trainer := p.newTrainer()
+	trainer.weight = weight
+
+	return p.batch(func() error {
+		return p.train(trainer, items)
+	})
+}
+
+// Untrain reverses the updates made by `Train()` with the same items,
+// including every suffix of the context. The classes which no longer appear in
+// the records are removed from the class list. Use it to delete the data from
+// the model without retraining from scratch.
+//
+// The items are validated before any update, so on error the model is left
+// unchanged. Note that the maximum order must be the same as the training. It
+// returns an error if the predictor is closed, and the error of the node logger
+// as in `Train()`.
+//
+// It is exact only for the items actually trained. Untraining the items never
+// trained still reverses the transitions they share with the trained ones, such
+// as b->c of [a, b, c] by untraining [x, b, c]. With the time-decay but without
+// the sliding window, the weight of a training made now is subtracted instead
+// of the decayed weight of the original training.
+func (p *Predictor) Untrain(items []any) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return p.batch(func() error {
+		return p.untrain("", items)
+	})
+}
+
+// UntrainFrom is the same as `Untrain()` but reverses only the updates made by
+// `TrainFrom()` with the same source. So the records of the other sources are
+// kept even if they share the same transitions. It returns ErrNoProvenance if
+// the provenance is not enabled.
+//
+// After untraining all the items of the source, `Sources()` no longer returns
+// the source for any transition.
+func (p *Predictor) UntrainFrom(source string, items []any) error {
+	if err := p.checkSource(source); err != nil {
+		return err
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return p.batch(func() error {
+		return p.untrain(source, items)
+	})
+}
+
+// ----------------------------------------------------------------------------
+//  Private methods
+// ----------------------------------------------------------------------------
+//  The private methods do not lock the predictor. The caller must hold the lock.
+
+// batch runs fn in a batch of the node logger if supported, so the updates of
+// fn are applied at once. On error of fn, the batch is rolled back. Then it
+// returns the error of the node logger during fn, if any.
+//
+// Since the classes added or deleted by fn may have been rolled back, they are
+// reloaded on error if the node logger keeps the classes.
+func (p *Predictor) batch(fn func() error) error {
+	err := inBatch(p.nodeLogger, fn)
+	if err == nil {
+		return nil
+	}
+
+	if _, ok := p.nodeLogger.(classLogger); ok {
+		if classes, errLoad := loadClasses(p.nodeLogger); errLoad == nil {
+			p.classes = classes
+		}
+	}
+
+	return err
+}
+
+// checkSource returns an error if the source can not be recorded. It does not
+// need the lock since the provenance option is never changed after creation.
+func (p *Predictor) checkSource(source string) error {
+	if !p.provenance {
+		return errors.Wrap(ErrNoProvenance, "use WithProvenance() option")
+	}
+
+	if source == "" {
+		return errors.New("source must not be empty")
+	}
+
+	return nil
+}
+
+func (p *Predictor) close() error {
+	if closer, ok := p.nodeLogger.(io.Closer); ok {
+		if err := closer.Close(); err != nil {
+			return errors.Wrap(err, "failed to close the node logger")
+		}
+	}
+
+	p.nodeLogger = nil
+
+	return nil
+}
+
+// itemID converts the item to the item ID in the hashing mode of the predictor.
+func (p *Predictor) itemID(item any) (uint64, error) {
+	if p.typedHashing {
+		return convAnyToTypedUint64(item)
+	}
+
+	return convAnyToUint64(item)
+}
+
+// itemIDs converts the items to the item IDs.
+func (p *Predictor) itemIDs(items []any) ([]uint64, error) {
+	itemIDs := make([]uint64, len(items))
+
+	for i, item := range items {
+		itemID, err := p.itemID(p.quantize(item))
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid item at index %d", i)
+		}
+
+		itemIDs[i] = itemID
+	}
+
+	return itemIDs, nil
+}
+
+// predict returns the candidates of the next class inferred from the given items
+// in descending order of the probability.
+//
+// If the whole items have never been followed by any class, it backs off to the
+// shorter suffixes of the items, which are trained by the drill of Train, until
+// a candidate is found. e.g.
+//
+//	[1, 2, 3, 4, 5] --> not found
+//	   [2, 3, 4, 5] --> not found
+//	      [3, 4, 5] --> found. Returns the candidates of [3, 4, 5]
+//
+// If the boundaries are enabled, the empty items are the start of a sequence,
+// so the candidates of the first item are returned.
+func (p *Predictor) predict(items []any) ([]Prediction, error) {
+	if p.nodeLogger == nil {
+		return nil, errors.New("predictor is not initialized")
+	}
+
+	if len(items) == 0 && p.boundaries {
+		items = []any{StartOfSequence}
+	}
+
+	itemIDs, err := p.itemIDs(items)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to hash the flow")
+	}
+
+	return p.predictIDs(itemIDs)
+}
+
+// predictIDs is the same as predict but with the item IDs of the context. It
+// returns the error kept by the node logger on reading, such as of a broken
+// database, instead of regarding the records as empty.
+func (p *Predictor) predictIDs(itemIDs []uint64) ([]Prediction, error) {
+	itemIDs = p.truncate(itemIDs)
+
+	for i := 0; i < len(itemIDs); i++ {
+		flowID, err := HashTrans(itemIDs[i:]...)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to hash the flow")
+		}
+
+		predictions := p.rank(flowID, len(itemIDs)-i)
+
+		if err := loggerErr(p.nodeLogger); err != nil {
+			return nil, errors.Wrap(err, "failed to read the node logger")
+		}
+
+		if len(predictions) > 0 {
+			return predictions, nil
+		}
+	}
+
+	return []Prediction{}, nil
+}
+
+// rank returns the candidates of the next class of the given flow in descending
+// order of the probability. The order is the number of items in the flow.
+func (p *Predictor) rank(flowID uint64, order int) []Prediction {
+	predictions := []Prediction{}
+	total := float64(0)
+
+	for classID, class := range p.classes {
+		score := p.nodeLogger.Predict(flowID, classID)
+		if score <= 0 {
+			continue
+		}
+
+		total += score
+		predictions = append(predictions, Prediction{
+			Raw:     class.Raw,
+			ClassID: classID,
+			Score:   score,
+			Order:   order,
+		})
+	}
+
+	for i := range predictions {
+		predictions[i].Probability = predictions[i].Score / total
+	}
+
+	// Sort by the score. On tie, the smaller class ID comes first to always get
+	// the same result.
+	sort.Slice(predictions, func(i, j int) bool {
+		if predictions[i].Score != predictions[j].Score {
+			return predictions[i].Score > predictions[j].Score
+		}
+
+		return predictions[i].ClassID < predictions[j].ClassID
+	})
+
+	return predictions
+}
+
+func (p *Predictor) reset() error {
+	nodeLogger, err := newNodeLogger(p.storage, p.scopeID, p.sqlite3Path, p.memOptions...)
+	if err != nil {
+		return errors.Wrap(err, "failed to reset the predictor")
+	}
+
+	classes, err := loadClasses(nodeLogger)
+	if err != nil {
+		if closer, ok := nodeLogger.(io.Closer); ok {
+			_ = closer.Close()
+		}
+
+		return errors.Wrap(err, "failed to reset the predictor")
+	}
+
+	if err := p.close(); err != nil {
+		return err
+	}
+
+	p.nodeLogger = nodeLogger
+	p.classes = classes
+
+	return nil
+}
+
+// train trains the predictor with the items as a sequence. The whole items are
+// validated before any update, so on error the model is left unchanged.
+func (p *Predictor) train(trainer *_Trainer, items []any) error {
+	itemIDs, err := p.itemIDs(items)
+	if err != nil {
+		return errors.Wrap(err, "failed during training iteration")
+	}
+
+	p.trainIDs(trainer, itemIDs, items)
+
+	return nil
+}
+
+// trainIDs trains the predictor with the items and their IDs given by itemIDs.
+// The markers of the sequence are added if the boundaries are enabled.
+func (p *Predictor) trainIDs(trainer *_Trainer, itemIDs []uint64, items []any) {
+	itemIDs, items = p.bound(itemIDs, items)
+
+	for i, itemID := range itemIDs {
+		trainer.stepID(itemID, p.quantize(items[i]))
+	}
+}
+
+func (p *Predictor) newTrainer() *_Trainer {
+	return &_Trainer{predictor: p, weight: 1}
+}
+
+func (p *Predictor) untrain(source string, items []any) error {
+	if p.nodeLogger == nil {
+		return errors.New("predictor is not initialized")
+	}
+
+	itemIDs, err := p.itemIDs(items)
+	if err != nil {
+		return errors.Wrap(err, "failed to untrain")
+	}
+
+	trainer := p.newTrainer()
+	trainer.source = source
+	trainer.untrain = true
+
+	p.trainIDs(trainer, itemIDs, items)
+
+	itemIDs, _ = p.bound(itemIDs, items)
+
+	for _, itemID := range itemIDs {
+		if p.nodeLogger.PriorPtoB(itemID) <= 0 {
+			p.deleteClass(itemID)
+		}
+	}
+
+	return nil
+}
+
+// truncate returns the last items up to the maximum order.
+func (p *Predictor) truncate(itemIDs []uint64) []uint64 {
+	if p.maxOrder > 0 && len(itemIDs) > p.maxOrder {
+		return itemIDs[len(itemIDs)-p.maxOrder:]
+	}
+
+	return itemIDs
+}
+
+// addClass registers the original value of the class. The new classes are also
+// stored to the node logger if it keeps the classes.
+//
+//nolint:varnamelen,cyclop
+func (p *Predictor) addClass(class uint64, raw any) {
+	_, known := p.classes[class]
+
+	switch v := raw.(type) {
+	case uint64:
+		p.classes[class] = _Class{ID: class, Raw: v}
+	case uint32:
+		p.classes[class] = _Class{ID: class, Raw: v}
+	case uint16:
+		p.classes[class] = _Class{ID: class, Raw: v}
+	case uint8:
+		p.classes[class] = _Class{ID: class, Raw: v}
+	case uint:
+		p.classes[class] = _Class{ID: class, Raw: v}
+	case int64:
+		p.classes[class] = _Class{ID: class, Raw: v}
+	case int32:
+		p.classes[class] = _Class{ID: class, Raw: v}
+	case int16:
+		p.classes[class] = _Class{ID: class, Raw: v}
+	case int8:
+		p.classes[class] = _Class{ID: class, Raw: v}
+	case int:
+		p.classes[class] = _Class{ID: class, Raw: v}
+	case float64:
+		p.classes[class] = _Class{ID: class, Raw: v}
+	case float32:
+		p.classes[class] = _Class{ID: class, Raw: v}
+	case string:
+		p.classes[class] = _Class{ID: class, Raw: v}
+	case []byte:
+		// Copy not to be changed by the caller reusing the slice.
+		p.classes[class] = _Class{ID: class, Raw: append([]byte{}, v...)}
+	case bool:
+		p.classes[class] = _Class{ID: class, Raw: v}
+	default:
+		p.classes[class] = _Class{ID: class, Raw: raw}
+	}
+
+	if !known {
+		p.storeClass(class)
+	}
+}
+
+// deleteClass removes the class from the class list and from the node logger if
+// it keeps the classes.
+func (p *Predictor) deleteClass(classID uint64) {
+	delete(p.classes, classID)
+
+	if logger, ok := p.nodeLogger.(classLogger); ok {
+		logger.DeleteClass(classID)
+	}
+}
+
+// storeClass stores the class to the node logger if it keeps the classes.
+func (p *Predictor) storeClass(classID uint64) {
+	storeClass(p.nodeLogger, p.classes[classID])
+}
+
+// ----------------------------------------------------------------------------
+//  Private functions
+// ----------------------------------------------------------------------------
+
+// inBatch runs fn in a batch of the node logger if the node logger supports it
+// (batchLogger), and rolls the batch back on error of fn. Then it returns the
+// error kept by the node logger (errLogger) if not in a batch.
+func inBatch(nodeLogger NodeLogger, fn func() error) error {
+	batch, ok := nodeLogger.(batchLogger)
+	if !ok {
+		if err := fn(); err != nil {
+			return err
+		}
+
+		return errors.Wrap(loggerErr(nodeLogger), "failed to update the node logger")
+	}
+
+	if err := batch.Begin(); err != nil {
+		return errors.Wrap(err, "failed to update the node logger")
+	}
+
+	if err := fn(); err != nil {
+		_ = batch.Rollback()
+
+		return err
+	}
+
+	return errors.Wrap(batch.Commit(), "failed to update the node logger")
+}
+
+// loadClasses returns the classes kept by the node logger. It returns an empty
+// class list if the node logger does not keep the classes.
+func loadClasses(nodeLogger NodeLogger) (map[uint64]_Class, error) {
+	classes := make(map[uint64]_Class)
+
+	logger, ok := nodeLogger.(classLogger)
+	if !ok {
+		return classes, nil
+	}
+
+	stored, err := logger.Classes()
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to load the classes")
+	}
+
+	for classID, value := range stored {
+		class, err := readClass(bytes.NewReader(value))
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to read the class %d", classID)
+		}
+
+		classes[class.ID] = class
+	}
+
+	return classes, nil
+}
+
+// loggerErr returns the error kept by the node logger if supported (errLogger).
+func loggerErr(nodeLogger NodeLogger) error {
+	if logger, ok := nodeLogger.(errLogger); ok {
+		return logger.Err()
+	}
+
+	return nil
+}
+
+// storeClass stores the class to the node logger if it keeps the classes.
+func storeClass(nodeLogger NodeLogger, class _Class) {
+	logger, ok := nodeLogger.(classLogger)
+	if !ok {
+		return
+	}
+
+	var buf bytes.Buffer
+
+	// The classes which can not be saved, such as the ones failed to marshal,
+	// are kept in memory only.
+	if err := writeClass(&buf, class); err != nil {
+		return
+	}
+
+	logger.StoreClass(class.ID, buf.Bytes())
+}
@@ -0,0 +1,1101 @@
+package bayes
+
+import (
+	"bytes"
+	"context"
+	"math"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/KEINOS/go-bayes/pkg/nodelogger/logmem"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// ----------------------------------------------------------------------------
+//  NewPredictor
+// ----------------------------------------------------------------------------
+
+func TestNewPredictor_options(t *testing.T) {
+	t.Parallel()
+
+	pathDB := filepath.Join(t.TempDir(), "test.db")
+
+	predictor, err := NewPredictor(
+		WithStorage(MemoryStorage),
+		WithScopeID(100),
+		WithSQLite3Path(pathDB),
+	)
+	require.NoError(t, err)
+
+	defer predictor.Close()
+
+	require.Equal(t, MemoryStorage, predictor.storage)
+	require.Equal(t, pathDB, predictor.sqlite3Path)
+	require.Equal(t, uint64(100), predictor.nodeLogger.ID())
+}
+
+func TestNewPredictor_unknown_storage(t *testing.T) {
+	t.Parallel()
+
+	predictor, err := NewPredictor(WithStorage(UnknwonStorage))
+
+	require.Error(t, err, "unknown storage should be an error")
+	require.Nil(t, predictor, "it should be nil on error")
+
+	assert.Contains(t, err.Error(), "failed to reset the predictor")
+	assert.Contains(t, err.Error(), "unknown storage engine type")
+}
+
+// ----------------------------------------------------------------------------
+//  Predictor
+// ----------------------------------------------------------------------------
+
+func TestPredictor_independent_instances(t *testing.T) {
+	t.Parallel()
+
+	predictorA, err := NewPredictor()
+	require.NoError(t, err)
+
+	predictorB, err := NewPredictor()
+	require.NoError(t, err)
+
+	require.NoError(t, predictorA.Train([]any{"foo", "bar"}))
+	require.NoError(t, predictorB.Train([]any{"foo", "baz"}))
+
+	classA, err := predictorA.Predict([]any{"foo"})
+	require.NoError(t, err)
+
+	classB, err := predictorB.Predict([]any{"foo"})
+	require.NoError(t, err)
+
+	assert.Equal(t, "bar", predictorA.GetClass(classA))
+	assert.Equal(t, "baz", predictorB.GetClass(classB))
+	assert.Nil(t, predictorA.GetClass(classB), "class of the other instance should not be mixed")
+}
+
+func TestPredictor_not_initialized(t *testing.T) {
+	t.Parallel()
+
+	var predictor Predictor
+
+	classID, err := predictor.Predict([]any{"foo"})
+
+	require.Error(t, err, "zero value predictor should be an error on predict")
+	require.Zero(t, classID, "it should be zero on error")
+	assert.Contains(t, err.Error(), "predictor is not initialized")
+
+	err = predictor.Save(new(bytes.Buffer))
+
+	require.Error(t, err, "zero value predictor should be an error on save")
+	assert.Contains(t, err.Error(), "predictor is not initialized")
+
+	err = predictor.Train([]any{"foo", "bar"})
+
+	require.Error(t, err, "zero value predictor should be an error on train")
+	assert.Contains(t, err.Error(), "predictor is not initialized")
+}
+
+func TestPredictor_Train_closed(t *testing.T) {
+	t.Parallel()
+
+	predictor, err := NewPredictor()
+	require.NoError(t, err)
+	require.NoError(t, predictor.Close())
+
+	for name, train := range map[string]func() error{
+		"Train":         func() error { return predictor.Train([]any{"a", "b"}) },
+		"TrainCorpus":   func() error { return predictor.TrainCorpus([][]any{{"a", "b"}}) },
+		"TrainWeighted": func() error { return predictor.TrainWeighted([]any{"a", "b"}, 2) },
+		"TrainReader": func() error {
+			return predictor.TrainReader(context.Background(), strings.NewReader("a\nb\n"), FormatLines)
+		},
+	} {
+		err := train()
+
+		require.Error(t, err, "%s: closed predictor should not be reopened silently", name)
+		assert.Contains(t, err.Error(), "predictor is not initialized", name)
+	}
+
+	require.NoError(t, predictor.Reset())
+	require.NoError(t, predictor.Train([]any{"a", "b"}), "reset predictor should be trainable")
+}
+
+func TestPredictor_Load_unknown_storage(t *testing.T) {
+	t.Parallel()
+
+	predictor, err := NewPredictor()
+	require.NoError(t, err)
+
+	require.NoError(t, predictor.Train([]any{"foo", "bar"}))
+
+	var saved bytes.Buffer
+
+	require.NoError(t, predictor.Save(&saved))
+
+	predictor.storage = UnknwonStorage
+
+	err = predictor.Load(&saved)
+
+	require.Error(t, err, "loading to unknown storage should be an error")
+	assert.Contains(t, err.Error(), "failed to create the node logger")
+	assert.Equal(t, "bar", predictor.GetClass(mustConv(t, "bar")), "model should be left unchanged")
+}
+
+func TestPredictor_concurrent_train_and_predict(t *testing.T) {
+	t.Parallel()
+
+	const numLoops = 200
+
+	predictor, err := NewPredictor()
+	require.NoError(t, err)
+
+	score := []any{"So", "So", "La", "So", "Do", "Si"}
+
+	var wg sync.WaitGroup
+
+	for i := 0; i < 4; i++ {
+		wg.Add(2)
+
+		go func() {
+			defer wg.Done()
+
+			for j := 0; j < numLoops; j++ {
+				assert.NoError(t, predictor.Train(score))
+			}
+		}()
+
+		go func() {
+			defer wg.Done()
+
+			for j := 0; j < numLoops; j++ {
+				classID, err := predictor.Predict(score[:2])
+				if err != nil {
+					assert.ErrorIs(t, err, ErrUnknownContext, "only untrained context is allowed to fail")
+				}
+
+				_ = predictor.GetClass(classID)
+			}
+		}()
+	}
+
+	wg.Wait()
+
+	classID, err := predictor.Predict(score[:2])
+	require.NoError(t, err)
+	require.Equal(t, "La", predictor.GetClass(classID))
+}
+
+//nolint:paralleltest // disable parallel test due to global variable change
+func TestTrain_concurrent_with_Predict(t *testing.T) {
+	defer Reset()
+
+	const numLoops = 200
+
+	score := []string{"So", "So", "La", "So", "Do", "Si"}
+
+	var wg sync.WaitGroup
+
+	for i := 0; i < 4; i++ {
+		wg.Add(3)
+
+		go func() {
+			defer wg.Done()
+
+			for j := 0; j < numLoops; j++ {
+				assert.NoError(t, Train(score))
+			}
+		}()
+
+		go func() {
+			defer wg.Done()
+
+			for j := 0; j < numLoops; j++ {
+				classID, err := Predict(score[:2])
+				if err != nil {
+					assert.ErrorIs(t, err, ErrUnknownContext, "only untrained context is allowed to fail")
+				}
+
+				_ = GetClass(classID)
+			}
+		}()
+
+		go func() {
+			defer wg.Done()
+
+			for j := 0; j < numLoops/10; j++ {
+				Reset()
+			}
+		}()
+	}
+
+	wg.Wait()
+}
+
+func TestPredictor_PredictTopK(t *testing.T) {
+	t.Parallel()
+
+	predictor, err := NewPredictor()
+	require.NoError(t, err)
+
+	require.NoError(t, predictor.Train([]any{"a", "b", "a", "b", "a", "c", "a", "d"}))
+
+	all, err := predictor.PredictTopK([]any{"a"}, 0)
+	require.NoError(t, err)
+	require.Len(t, all, 3, "classes never followed 'a' should not be candidates")
+
+	total := float64(0)
+
+	for i, prediction := range all {
+		total += prediction.Probability
+
+		assert.Equal(t, predictor.GetClass(prediction.ClassID), prediction.Raw)
+
+		if i > 0 {
+			assert.GreaterOrEqual(t, all[i-1].Probability, prediction.Probability, "should be ranked")
+		}
+	}
+
+	require.InDelta(t, 1.0, total, 1e-9, "probabilities should be normalized")
+	require.Equal(t, "b", all[0].Raw)
+
+	top, err := predictor.PredictTopK([]any{"a"}, 1)
+	require.NoError(t, err)
+	require.Equal(t, all[:1], top)
+
+	// Same as Predict
+	classID, err := predictor.Predict([]any{"a"})
+	require.NoError(t, err)
+	require.Equal(t, top[0].ClassID, classID)
+
+	// Unknown context
+	none, err := predictor.PredictTopK([]any{"z"}, 3)
+	require.NoError(t, err)
+	require.Empty(t, none)
+
+	// Error
+	_, err = predictor.PredictTopK([]any{nil}, 3)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "failed to hash the flow")
+}
+
+//nolint:paralleltest // disable parallel test due to global variable change
+func TestPredictTopK_not_initialized(t *testing.T) {
+	oldPredictor := _predictor
+
+	defer func() {
+		_predictor = oldPredictor
+	}()
+
+	// Mock the singleton predictor
+	_predictor = nil
+
+	predictions, err := PredictTopK([]string{"foo"}, 3)
+
+	require.Error(t, err, "it should be an error if the predictor is not initialized")
+	require.Nil(t, predictions, "it should be nil on error")
+	assert.Contains(t, err.Error(), "predictor is not initialized")
+}
+
+func TestPredictor_Predict_unknown_context(t *testing.T) {
+	t.Parallel()
+
+	predictor, err := NewPredictor()
+	require.NoError(t, err)
+
+	// Class ID of false is 0
+	require.NoError(t, predictor.Train([]any{true, false, true, false}))
+
+	classID, err := predictor.Predict([]any{true})
+	require.NoError(t, err)
+	require.Equal(t, false, predictor.GetClass(classID), "class ID 0 should be predicted as is")
+
+	classID, err = predictor.Predict([]any{"never seen"})
+	require.ErrorIs(t, err, ErrUnknownContext)
+	require.Zero(t, classID)
+}
+
+func TestPredictor_Predict_min_probability(t *testing.T) {
+	t.Parallel()
+
+	predictor, err := NewPredictor(WithMinProbability(0.5))
+	require.NoError(t, err)
+
+	// "a" is followed by "b" and "c" equally, "x" is always followed by "y"
+	require.NoError(t, predictor.Train([]any{"a", "b"}))
+	require.NoError(t, predictor.Train([]any{"a", "c"}))
+	require.NoError(t, predictor.Train([]any{"x", "y"}))
+
+	classID, err := predictor.Predict([]any{"x"})
+	require.NoError(t, err)
+	require.Equal(t, "y", predictor.GetClass(classID))
+
+	predictions, err := predictor.PredictTopK([]any{"a"}, 0)
+	require.NoError(t, err)
+	require.Len(t, predictions, 2, "candidates at the threshold should be kept")
+
+	predictor.minProbability = 0.51
+
+	classID, err = predictor.Predict([]any{"a"})
+	require.ErrorIs(t, err, ErrBelowThreshold)
+	require.Zero(t, classID)
+
+	predictions, err = predictor.PredictTopK([]any{"a"}, 0)
+	require.NoError(t, err)
+	require.Empty(t, predictions, "candidates below the threshold should be omitted")
+}
+
+//nolint:paralleltest // disable parallel test due to global variable change
+func TestSetMinProbability(t *testing.T) {
+	defer func() {
+		SetMinProbability(0)
+		Reset()
+	}()
+
+	SetMinProbability(0.51)
+	Reset()
+
+	require.NoError(t, Train([]string{"a", "b"}))
+	require.NoError(t, Train([]string{"a", "c"}))
+
+	_, err := Predict([]string{"a"})
+	require.ErrorIs(t, err, ErrBelowThreshold, "default predictor should abstain")
+}
+
+func TestPredictor_Predict_back_off(t *testing.T) {
+	t.Parallel()
+
+	predictor, err := NewPredictor()
+	require.NoError(t, err)
+
+	require.NoError(t, predictor.Train([]any{1, 2, 3, 4}))
+	require.NoError(t, predictor.Train([]any{5, 3, 6}))
+
+	//nolint:varnamelen // tt is short but descriptive
+	for _, tt := range []struct {
+		input       []any
+		expectClass any
+		expectOrder int
+	}{
+		{[]any{1, 2, 3}, 4, 3},          // exact context
+		{[]any{9, 9, 2, 3}, 4, 2},       // backs off to [2, 3]
+		{[]any{9, 9, 5, 3}, 6, 2},       // backs off to [5, 3]
+		{[]any{9, 1, 2, 3, 9, 3}, 4, 1}, // backs off to [3]
+	} {
+		predictions, err := predictor.PredictTopK(tt.input, 1)
+		require.NoError(t, err)
+		require.Len(t, predictions, 1)
+
+		assert.Equal(t, tt.expectClass, predictions[0].Raw, "input: %v", tt.input)
+		assert.Equal(t, tt.expectOrder, predictions[0].Order, "input: %v", tt.input)
+	}
+
+	_, err = predictor.Predict([]any{1, 2, 3, 9})
+	require.ErrorIs(t, err, ErrUnknownContext, "the last item must be known at least")
+}
+
+func TestPredictor_max_order(t *testing.T) {
+	t.Parallel()
+
+	score := []any{1, 2, 3, 4, 5, 6, 7, 8, 9, 10}
+
+	unlimited, err := NewPredictor()
+	require.NoError(t, err)
+
+	limited, err := NewPredictor(WithMaxOrder(2))
+	require.NoError(t, err)
+
+	require.NoError(t, unlimited.Train(score))
+	require.NoError(t, limited.Train(score))
+
+	// Number of the flows stored. The 101 training of the predecessor is also
+	// counted as the flow.
+	numFlows := func(predictor *Predictor) int {
+		nodeLog, ok := predictor.nodeLogger.(*logmem.NodeLog)
+		require.True(t, ok)
+
+		return len(nodeLog.FromAToB)
+	}
+
+	assert.Equal(t, 9+45, numFlows(unlimited), "unlimited order should store every suffix")
+	assert.Equal(t, 9+9+8, numFlows(limited), "order 2 should store the suffixes up to 2 items")
+
+	// Longer context than the max order is truncated to the last 2 items
+	predictions, err := limited.PredictTopK([]any{1, 2, 3, 4}, 1)
+	require.NoError(t, err)
+	require.Len(t, predictions, 1)
+	assert.Equal(t, 5, predictions[0].Raw)
+	assert.Equal(t, 2, predictions[0].Order)
+}
+
+//nolint:paralleltest // disable parallel test due to global variable change
+func TestSetMaxOrder(t *testing.T) {
+	defer func() {
+		SetMaxOrder(0)
+		Reset()
+	}()
+
+	SetMaxOrder(2)
+	Reset()
+
+	require.NoError(t, Train([]int{1, 2, 3, 4, 5}))
+
+	predictions, err := PredictTopK([]int{1, 2, 3, 4}, 1)
+	require.NoError(t, err)
+	require.Len(t, predictions, 1)
+	assert.Equal(t, 5, predictions[0].Raw)
+	assert.Equal(t, 2, predictions[0].Order, "context should be capped by the default predictor")
+}
+
+func TestPredictor_smoothing(t *testing.T) {
+	t.Parallel()
+
+	raw, err := NewPredictor()
+	require.NoError(t, err)
+
+	smoothed, err := NewPredictor(WithSmoothing(logmem.WittenBell{}))
+	require.NoError(t, err)
+
+	for _, predictor := range []*Predictor{raw, smoothed} {
+		require.NoError(t, predictor.Train([]any{"a", "b"}))
+		require.NoError(t, predictor.Train([]any{"a", "b"}))
+		require.NoError(t, predictor.Train([]any{"x", "c"}))
+	}
+
+	rawPredictions, err := raw.PredictTopK([]any{"a"}, 0)
+	require.NoError(t, err)
+	require.Len(t, rawPredictions, 1, "unseen transitions should not be candidates without smoothing")
+
+	predictions, err := smoothed.PredictTopK([]any{"a"}, 0)
+	require.NoError(t, err)
+	require.Len(t, predictions, 2, "unseen transitions should be candidates with smoothing")
+	assert.Equal(t, "b", predictions[0].Raw)
+	assert.Equal(t, "c", predictions[1].Raw)
+	assert.Greater(t, predictions[1].Probability, 0.0)
+
+	_, err = smoothed.Predict([]any{"never seen"})
+	require.ErrorIs(t, err, ErrUnknownContext, "unknown context should not be smoothed")
+}
+
+//nolint:paralleltest // disable parallel test due to global variable change
+func TestSetSmoothing(t *testing.T) {
+	defer func() {
+		SetSmoothing(nil)
+		Reset()
+	}()
+
+	SetSmoothing(logmem.WittenBell{})
+	Reset()
+
+	require.NoError(t, Train([]string{"a", "b"}))
+	require.NoError(t, Train([]string{"x", "c"}))
+
+	predictions, err := PredictTopK([]string{"a"}, 0)
+	require.NoError(t, err)
+	require.Len(t, predictions, 2, "unseen transitions should be smoothed by the default predictor")
+}
+
+func TestPredictor_smoothing_sqlite3_storage(t *testing.T) {
+	t.Parallel()
+
+	predictor, err := NewPredictor(
+		WithStorage(SQLite3Storage),
+		WithSQLite3Path(filepath.Join(t.TempDir(), "test.db")),
+		WithSmoothing(logmem.Laplace()),
+	)
+
+	require.Error(t, err, "smoothing should not be supported by SQLite3 storage")
+	require.Nil(t, predictor)
+	assert.Contains(t, err.Error(), "not supported by SQLite3 storage")
+}
+
+func TestPredictor_half_life(t *testing.T) {
+	t.Parallel()
+
+	predictor, err := NewPredictor(WithHalfLifeUpdates(2))
+	require.NoError(t, err)
+
+	// The old behavior is trained more, but decayed.
+	for i := 0; i < 5; i++ {
+		require.NoError(t, predictor.Train([]any{"home", "old-page"}))
+	}
+
+	require.NoError(t, predictor.Train([]any{"home", "new-page"}))
+
+	classID, err := predictor.Predict([]any{"home"})
+	require.NoError(t, err)
+	assert.Equal(t, "new-page", predictor.GetClass(classID), "recent behavior should dominate")
+
+	predictor, err = NewPredictor(WithHalfLife(time.Hour))
+	require.NoError(t, err)
+
+	nodeLog, ok := predictor.nodeLogger.(*logmem.NodeLog)
+	require.True(t, ok)
+	require.NoError(t, predictor.Train([]any{"home", "page"}))
+	// The 101 training and the drill are both recorded just now.
+	assert.InDelta(t, 2.0, nodeLog.TotalAccesses*nodeLog.Scale(), 1e-3)
+}
+
+func TestPredictor_window(t *testing.T) {
+	t.Parallel()
+
+	// Training 2 items updates 2 records. So the window holds the last 2
+	// trainings.
+	predictor, err := NewPredictor(WithWindow(4))
+	require.NoError(t, err)
+
+	for i := 0; i < 5; i++ {
+		require.NoError(t, predictor.Train([]any{"card", "legit"}))
+	}
+
+	require.NoError(t, predictor.Train([]any{"card", "fraud"}))
+	require.NoError(t, predictor.Train([]any{"card", "fraud"}))
+
+	predictions, err := predictor.PredictTopK([]any{"card"}, 0)
+	require.NoError(t, err)
+	require.Len(t, predictions, 1, "trainings out of the window should not count")
+	assert.Equal(t, "fraud", predictions[0].Raw)
+	assert.InDelta(t, 1.0, predictions[0].Probability, 0)
+
+	predictor, err = NewPredictor(WithWindowDuration(time.Hour))
+	require.NoError(t, err)
+	require.NoError(t, predictor.Train([]any{"card", "legit"}))
+
+	classID, err := predictor.Predict([]any{"card"})
+	require.NoError(t, err)
+	assert.Equal(t, "legit", predictor.GetClass(classID))
+}
+
+func TestPredictor_window_duration_expires_without_training(t *testing.T) {
+	t.Parallel()
+
+	predictor, err := NewPredictor(WithWindowDuration(50 * time.Millisecond))
+	require.NoError(t, err)
+	require.NoError(t, predictor.Train([]any{"a", "b"}))
+
+	classID, err := predictor.Predict([]any{"a"})
+	require.NoError(t, err)
+	require.Equal(t, "b", predictor.GetClass(classID))
+
+	time.Sleep(100 * time.Millisecond)
+
+	_, err = predictor.Predict([]any{"a"})
+	require.ErrorIs(t, err, ErrUnknownContext, "trainings out of the window should not be predicted")
+
+	score, err := predictor.Score([]any{"a", "b"})
+	require.NoError(t, err)
+	assert.True(t, math.IsInf(score.LogProbability, -1), "expired transition should score zero")
+}
+
+func TestPredictor_Untrain(t *testing.T) {
+	t.Parallel()
+
+	keep := []any{"a", "b", "c", "a", "b"}
+	remove := []any{"x", "a", "b", "d", "b"}
+
+	for _, opts := range [][]Option{
+		nil,
+		{WithMaxOrder(2)},
+		{WithWindow(100)},
+	} {
+		expect := mustSaveTrained(t, func(p *Predictor) error {
+			return p.Train(keep)
+		}, opts...)
+		actual := mustSaveTrained(t, func(p *Predictor) error {
+			if err := p.Train(keep); err != nil {
+				return err
+			}
+
+			if err := p.Train(remove); err != nil {
+				return err
+			}
+
+			return p.Untrain(remove)
+		}, opts...)
+
+		assert.Equal(t, expect, actual, "untrain should reverse the training exactly")
+	}
+}
+
+func TestPredictor_Untrain_classes(t *testing.T) {
+	t.Parallel()
+
+	predictor, err := NewPredictor()
+	require.NoError(t, err)
+
+	require.NoError(t, predictor.Train([]any{"a", "b", "c"}))
+	require.NoError(t, predictor.Train([]any{"a", "d"}))
+	require.NoError(t, predictor.Untrain([]any{"a", "b", "c"}))
+
+	assert.Nil(t, predictor.GetClass(mustConv(t, "b")), "class no longer trained should be removed")
+	assert.Nil(t, predictor.GetClass(mustConv(t, "c")), "class no longer trained should be removed")
+	assert.Equal(t, "d", predictor.GetClass(mustConv(t, "d")))
+
+	classID, err := predictor.Predict([]any{"a"})
+	require.NoError(t, err)
+	assert.Equal(t, "d", predictor.GetClass(classID))
+
+	// Untraining again does nothing
+	require.NoError(t, predictor.Untrain([]any{"a", "b", "c"}))
+
+	classID, err = predictor.Predict([]any{"a"})
+	require.NoError(t, err)
+	assert.Equal(t, "d", predictor.GetClass(classID))
+}
+
+func TestPredictor_Untrain_unsupported_item(t *testing.T) {
+	t.Parallel()
+
+	predictor, err := NewPredictor()
+	require.NoError(t, err)
+
+	require.NoError(t, predictor.Train([]any{"a", "b"}))
+
+	err = predictor.Untrain([]any{"a", "b", []int{1}})
+
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "failed to untrain")
+
+	classID, err := predictor.Predict([]any{"a"})
+	require.NoError(t, err, "model should be left unchanged on error")
+	assert.Equal(t, "b", predictor.GetClass(classID))
+}
+
+func TestPredictor_Untrain_not_initialized(t *testing.T) {
+	t.Parallel()
+
+	predictor, err := NewPredictor()
+	require.NoError(t, err)
+	require.NoError(t, predictor.Close())
+
+	err = predictor.Untrain([]any{"a", "b"})
+	require.Error(t, err, "closed predictor should not silently succeed")
+	assert.Contains(t, err.Error(), "predictor is not initialized")
+}
+
+//nolint:paralleltest // disable parallel test due to global variable change
+func TestUntrain_not_initialized(t *testing.T) {
+	oldPredictor := _predictor
+
+	defer func() {
+		_predictor = oldPredictor // Recover object
+	}()
+
+	// Mock the singleton predictor
+	_predictor = nil
+
+	err := Untrain([]string{"foo", "bar"})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "predictor is not initialized")
+}
+
+func TestPredictor_provenance(t *testing.T) {
+	t.Parallel()
+
+	docA := []any{"login", "view", "buy"}
+	docB := []any{"login", "view", "logout"}
+
+	predictor, err := NewPredictor(WithProvenance())
+	require.NoError(t, err)
+
+	require.NoError(t, predictor.TrainFrom("doc-a", docA))
+	require.NoError(t, predictor.TrainFrom("doc-b", docB))
+	require.NoError(t, predictor.Train([]any{"login", "view"})) // without source
+
+	sources, err := predictor.Sources([]any{"login"}, "view")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"doc-a", "doc-b"}, sources)
+
+	sources, err = predictor.Sources([]any{"login", "view"}, "buy")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"doc-a"}, sources)
+
+	// Delete doc-a
+	require.NoError(t, predictor.UntrainFrom("doc-a", docA))
+
+	for _, flow := range [][]any{{"login", "view"}, {"login", "view", "buy"}, {"view", "buy"}} {
+		sources, err := predictor.Sources(flow[:len(flow)-1], flow[len(flow)-1])
+		require.NoError(t, err)
+		assert.NotContains(t, sources, "doc-a", "flow: %v", flow)
+	}
+
+	assert.Nil(t, predictor.GetClass(mustConv(t, "buy")))
+
+	// Untraining the other source does nothing
+	require.NoError(t, predictor.UntrainFrom("doc-c", docB))
+
+	sources, err = predictor.Sources([]any{"login", "view"}, "logout")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"doc-b"}, sources)
+}
+
+func TestPredictor_provenance_same_as_untrained(t *testing.T) {
+	t.Parallel()
+
+	docA := []any{1, 2, 3, 2, 1}
+	docB := []any{1, 2, 3, 4}
+
+	expect := mustSaveTrained(t, func(p *Predictor) error {
+		return p.TrainFrom("doc-b", docB)
+	}, WithProvenance())
+	actual := mustSaveTrained(t, func(p *Predictor) error {
+		if err := p.TrainFrom("doc-a", docA); err != nil {
+			return err
+		}
+
+		if err := p.TrainFrom("doc-b", docB); err != nil {
+			return err
+		}
+
+		return p.UntrainFrom("doc-a", docA)
+	}, WithProvenance())
+
+	assert.Equal(t, expect, actual)
+}
+
+func TestPredictor_provenance_Load(t *testing.T) {
+	t.Parallel()
+
+	saved := mustSaveTrained(t, func(p *Predictor) error {
+		if err := p.TrainFrom("user-1", []any{"a", "b"}); err != nil {
+			return err
+		}
+
+		return p.TrainFrom("user-2", []any{"a", "c"})
+	}, WithProvenance())
+
+	predictor, err := NewPredictor(WithProvenance())
+	require.NoError(t, err)
+	require.NoError(t, predictor.Load(bytes.NewReader(saved)))
+
+	sources, err := predictor.Sources([]any{"a"}, "b")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"user-1"}, sources, "provenance should be restored on load")
+
+	require.NoError(t, predictor.UntrainFrom("user-1", []any{"a", "b"}))
+
+	predictions, err := predictor.PredictTopK([]any{"a"}, 0)
+	require.NoError(t, err)
+	require.Len(t, predictions, 1)
+	assert.Equal(t, "c", predictions[0].Raw, "loaded source should be untrained")
+}
+
+func TestPredictor_provenance_errors(t *testing.T) {
+	t.Parallel()
+
+	predictor, err := NewPredictor()
+	require.NoError(t, err)
+
+	require.ErrorIs(t, predictor.TrainFrom("doc", []any{1, 2}), ErrNoProvenance)
+	require.ErrorIs(t, predictor.UntrainFrom("doc", []any{1, 2}), ErrNoProvenance)
+
+	_, err = predictor.Sources([]any{1}, 2)
+	require.ErrorIs(t, err, ErrNoProvenance)
+
+	predictor, err = NewPredictor(WithProvenance())
+	require.NoError(t, err)
+
+	err = predictor.TrainFrom("", []any{1, 2})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "source must not be empty")
+
+	_, err = predictor.Sources(nil, 2)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "context must not be empty")
+
+	_, err = predictor.Sources([]any{[]int{1}}, 2)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "failed to hash the flow")
+
+	require.NoError(t, predictor.Close())
+
+	_, err = predictor.Sources([]any{1}, 2)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "predictor is not initialized")
+
+	_, err = NewPredictor(
+		WithProvenance(),
+		WithStorage(SQLite3Storage),
+		WithSQLite3Path(filepath.Join(t.TempDir(), "test.db")),
+	)
+	require.Error(t, err, "provenance should not be supported by SQLite3 storage")
+}
+
+//nolint:paralleltest // disable parallel test due to global variable change
+func TestTrainFrom(t *testing.T) {
+	defer func() {
+		SetProvenance(false)
+		Reset()
+	}()
+
+	SetProvenance(true)
+	Reset()
+
+	require.NoError(t, TrainFrom("doc-1", []string{"foo", "bar"}))
+
+	sources, err := Sources([]string{"foo"}, "bar")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"doc-1"}, sources)
+
+	require.NoError(t, UntrainFrom("doc-1", []string{"foo", "bar"}))
+
+	sources, err = Sources([]string{"foo"}, "bar")
+	require.NoError(t, err)
+	assert.Empty(t, sources)
+}
+
+//nolint:paralleltest // disable parallel test due to global variable change
+func TestSources_not_initialized(t *testing.T) {
+	oldPredictor := _predictor
+
+	defer func() {
+		_predictor = oldPredictor // Recover object
+	}()
+
+	// Mock the singleton predictor
+	_predictor = nil
+
+	_, err := Sources([]string{"foo"}, "bar")
+	require.Error(t, err)
+
+	err = UntrainFrom("doc-1", []string{"foo", "bar"})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "predictor is not initialized")
+}
+
+func TestPredictor_TrainWeighted(t *testing.T) {
+	t.Parallel()
+
+	items := []any{"a", "b", "c", "a", "b"}
+
+	for _, opts := range [][]Option{
+		nil,
+		{WithMaxOrder(2)},
+	} {
+		expect := mustSaveTrained(t, func(p *Predictor) error {
+			for i := 0; i < 3; i++ {
+				if err := p.Train(items); err != nil {
+					return err
+				}
+			}
+
+			return p.Train([]any{"a", "c"})
+		}, opts...)
+		actual := mustSaveTrained(t, func(p *Predictor) error {
+			if err := p.TrainWeighted(items, 3); err != nil {
+				return err
+			}
+
+			return p.TrainWeighted([]any{"a", "c"}, 1)
+		}, opts...)
+
+		assert.Equal(t, expect, actual, "weighted training should be the same as repeating the training")
+	}
+}
+
+func TestPredictor_TrainWeighted_fractional(t *testing.T) {
+	t.Parallel()
+
+	predictor, err := NewPredictor()
+	require.NoError(t, err)
+
+	require.NoError(t, predictor.TrainWeighted([]any{"a", "b"}, 1.5))
+	require.NoError(t, predictor.TrainWeighted([]any{"a", "c"}, 0.5))
+
+	predictions, err := predictor.PredictTopK([]any{"a"}, 0)
+	require.NoError(t, err)
+	require.Len(t, predictions, 2)
+	assert.Equal(t, "b", predictions[0].Raw, "heavier sequence should be more probable")
+	assert.Greater(t, predictions[0].Probability, predictions[1].Probability)
+}
+
+func TestPredictor_TrainWeighted_invalid_weight(t *testing.T) {
+	t.Parallel()
+
+	predictor, err := NewPredictor()
+	require.NoError(t, err)
+
+	for _, weight := range []float64{0, -1, math.NaN(), math.Inf(1)} {
+		err := predictor.TrainWeighted([]any{"a", "b"}, weight)
+
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "weight must be a positive finite number")
+	}
+
+	require.Empty(t, predictor.classes, "nothing should be trained on error")
+}
+
+//nolint:paralleltest // disable parallel test due to global variable change
+func TestTrainWeighted(t *testing.T) {
+	defer Reset()
+
+	Reset()
+
+	require.NoError(t, TrainWeighted([]string{"foo", "bar"}, 2))
+	require.NoError(t, TrainWeighted([]string{"foo", "baz"}, 1))
+
+	classID, err := Predict([]string{"foo"})
+	require.NoError(t, err)
+	assert.Equal(t, "bar", GetClass(classID))
+}
+
+func TestPredictor_typed_hashing(t *testing.T) {
+	t.Parallel()
+
+	items := []any{"x", true, "x", 1, "x", uint16(1), "x", 1.9}
+
+	untyped, err := NewPredictor()
+	require.NoError(t, err)
+	require.NoError(t, untyped.Train(items))
+
+	predictions, err := untyped.PredictTopK([]any{"x"}, 0)
+	require.NoError(t, err)
+	require.Len(t, predictions, 2, "integers and bools of the same value collide by default")
+
+	typed, err := NewPredictor(WithTypedHashing())
+	require.NoError(t, err)
+	require.NoError(t, typed.Train(items))
+
+	predictions, err = typed.PredictTopK([]any{"x"}, 0)
+	require.NoError(t, err)
+	require.Len(t, predictions, 4, "values of different types should be distinct")
+
+	raws := make([]any, 0, len(predictions))
+
+	for _, prediction := range predictions {
+		raws = append(raws, prediction.Raw)
+		assert.Equal(t, prediction.Raw, typed.GetClass(prediction.ClassID))
+	}
+
+	assert.ElementsMatch(t, []any{true, 1, uint16(1), 1.9}, raws)
+
+	// Same type and value should be the same class
+	require.NoError(t, typed.Untrain([]any{"x", 1}))
+
+	predictions, err = typed.PredictTopK([]any{"x"}, 0)
+	require.NoError(t, err)
+	require.Len(t, predictions, 3)
+
+	for _, prediction := range predictions {
+		assert.NotEqual(t, mustConvTyped(t, 1), prediction.ClassID, "only int(1) should be untrained")
+	}
+}
+
+//nolint:paralleltest // disable parallel test due to global variable change
+func TestSetTypedHashing(t *testing.T) {
+	defer func() {
+		SetTypedHashing(false)
+		Reset()
+	}()
+
+	SetTypedHashing(true)
+	Reset()
+
+	require.NoError(t, Train([]any{"x", 1, "x", true}))
+
+	predictions, err := PredictTopK([]string{"x"}, 0)
+	require.NoError(t, err)
+	require.Len(t, predictions, 2)
+}
+
+func TestPredictor_Train_validates_before_update(t *testing.T) {
+	t.Parallel()
+
+	trained := []any{"a", "b", "c"}
+
+	expect := mustSaveTrained(t, func(p *Predictor) error {
+		return p.Train(trained)
+	})
+	actual := mustSaveTrained(t, func(p *Predictor) error {
+		if err := p.Train(trained); err != nil {
+			return err
+		}
+
+		err := p.Train([]any{"a", "b", "c", nil, "d"})
+
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "invalid item at index 3")
+
+		return nil
+	})
+
+	assert.Equal(t, expect, actual, "model should be left unchanged on error")
+}
+
+func TestPredictor_Train_large_int(t *testing.T) {
+	t.Parallel()
+
+	predictor, err := NewPredictor()
+	require.NoError(t, err)
+
+	// Snowflake-like IDs over the int32 range
+	items := []any{int(1 << 40), int(-1 << 50), int(math.MaxInt64)}
+
+	require.NoError(t, predictor.Train(items))
+
+	classID, err := predictor.Predict(items[:2])
+	require.NoError(t, err)
+	assert.Equal(t, int(math.MaxInt64), predictor.GetClass(classID))
+}
+
+func TestPredictor_Train_node_logger_error(t *testing.T) {
+	t.Parallel()
+
+	predictor, err := NewPredictor()
+	require.NoError(t, err)
+
+	predictor.nodeLogger = &failingLogger{NodeLog: logmem.New(0)}
+
+	for name, train := range map[string]func() error{
+		"Train":         func() error { return predictor.Train([]any{"a", "b"}) },
+		"TrainCorpus":   func() error { return predictor.TrainCorpus([][]any{{"a", "b"}}) },
+		"TrainWeighted": func() error { return predictor.TrainWeighted([]any{"a", "b"}, 2) },
+		"Untrain":       func() error { return predictor.Untrain([]any{"a", "b"}) },
+	} {
+		err := train()
+
+		require.Error(t, err, "%s should return the error of the node logger", name)
+		assert.Contains(t, err.Error(), "failed to update the node logger", name)
+		assert.Contains(t, err.Error(), "attempt to write a readonly database", name)
+	}
+}
+
+// ----------------------------------------------------------------------------
+//  Helpers
+// ----------------------------------------------------------------------------
+
+// failingLogger is a NodeLogger which keeps an error on every update instead of
+// updating, like a SQLite3 storage of a read-only database.
+type failingLogger struct {
+	*logmem.NodeLog
+	err error
+}
+
+func (f *failingLogger) Decrement(_, _ uint64)          { f.fail() }
+func (f *failingLogger) Update(_, _ uint64)             { f.fail() }
+func (f *failingLogger) UpdateN(_, _ uint64, _ float64) { f.fail() }
+
+func (f *failingLogger) Err() error {
+	return f.err
+}
+
+func (f *failingLogger) fail() {
+	f.err = errors.New("attempt to write a readonly database")
+}
+
+// newTrainedPredictor returns a new predictor with the options trained with the
+// corpus.
+func newTrainedPredictor(t *testing.T, corpus [][]any, opts ...Option) *Predictor {
+	t.Helper()
+
+	predictor, err := NewPredictor(opts...)
+	require.NoError(t, err)
+
+	require.NoError(t, predictor.TrainCorpus(corpus))
+
+	return predictor
+}
@@ -0,0 +1,234 @@
+package bayes
+
+import (
+	"math"
+	"reflect"
+	"sort"
+
+	"github.com/pkg/errors"
+)
+
+// ============================================================================
+//  Quantization of the numeric items
+// ============================================================================
+//  The float items are hashed by their exact bits, so 0.50 and 0.51 are two
+//  different classes. To treat close values as the same class, such as sensor
+//  readings, set a Quantizer via `WithQuantizer()`. The numeric items are then
+//  replaced by the representative value of their bin as float64, which is also
+//  the original value returned by `GetClass()`.
+// ============================================================================
+
+// Quantizer maps a numeric value to the representative value of its bin. The
+// values in the same bin must return exactly the same value.
+type Quantizer interface {
+	Quantize(value float64) float64
+}
+
+// ----------------------------------------------------------------------------
+//  Type: FixedWidth
+// ----------------------------------------------------------------------------
+
+// FixedWidth is the quantizer of the bins in the same width from Origin. The
+// representative value is the lower bound of the bin. e.g. with Width of 0.5,
+// 0.1 and 0.4 are 0, and -0.1 is -0.5.
+//
+// The values are returned as is if Width is zero or negative.
+type FixedWidth struct {
+	Width  float64
+	Origin float64
+}
+
+// Quantize implements the Quantizer interface.
+func (f FixedWidth) Quantize(value float64) float64 {
+	if f.Width <= 0 || math.IsNaN(value) || math.IsInf(value, 0) {
+		return value
+	}
+
+	// The small epsilon keeps the values on the bounds, such as 0.3 / 0.1 of
+	// 2.9999999999999996, in their own bin.
+	const epsilon = 1e-9
+
+	return f.Origin + math.Floor((value-f.Origin)/f.Width+epsilon)*f.Width
+}
+
+// ----------------------------------------------------------------------------
+//  Type: LogScale
+// ----------------------------------------------------------------------------
+
+// LogScaleBase is the default base of LogScale.
+const LogScaleBase = 10
+
+// LogScale is the quantizer of the bins growing by the power of Base, for the
+// values in a wide range of magnitudes. The representative value is the power
+// of Base at or below the absolute value, with the sign. e.g. with Base of 10,
+// 0.05 is 0.01, 123 is 100 and -123 is -100. Zero is 0.
+type LogScale struct {
+	// Base is the base of the power greater than 1. Zero or less than 1 means
+	// LogScaleBase.
+	Base float64
+}
+
+// Quantize implements the Quantizer interface.
+func (l LogScale) Quantize(value float64) float64 {
+	if value == 0 || math.IsNaN(value) || math.IsInf(value, 0) {
+		return value
+	}
+
+	base := l.Base
+	if base <= 1 {
+		base = LogScaleBase
+	}
+
+	// The small epsilon keeps the exact powers, such as log10(1000) of
+	// 2.9999999999999996, in their own bin.
+	const epsilon = 1e-9
+
+	exponent := math.Floor(math.Log(math.Abs(value))/math.Log(base) + epsilon)
+
+	return math.Copysign(math.Pow(base, exponent), value)
+}
+
+// ----------------------------------------------------------------------------
+//  Type: QuantileBins
+// ----------------------------------------------------------------------------
+
+// QuantileBins is the quantizer of the bins learned from the sample values, so
+// that each bin holds about the same number of the samples. The representative
+// value is the lower edge of the bin. The values below the lowest edge belong
+// to the first bin.
+//
+// Use `NewQuantileBins()` to create an instance. The zero value has no bins and
+// returns the values as is.
+type QuantileBins struct {
+	// edges are the lower edges of the bins in ascending order.
+	edges []float64
+}
+
+// NewQuantileBins returns the quantizer of numBins bins learned from the
+// samples. The number of the bins may be less than numBins if the samples have
+// many duplicates. NaN samples are ignored.
+func NewQuantileBins(samples []float64, numBins int) (*QuantileBins, error) {
+	if numBins < 1 {
+		return nil, errors.Errorf("number of the bins must be 1 or more. Given: %d", numBins)
+	}
+
+	sorted := make([]float64, 0, len(samples))
+
+	for _, sample := range samples {
+		if !math.IsNaN(sample) {
+			sorted = append(sorted, sample)
+		}
+	}
+
+	if len(sorted) == 0 {
+		return nil, errors.New("no samples to learn the bins")
+	}
+
+	sort.Float64s(sorted)
+
+	edges := make([]float64, 0, numBins)
+
+	for i := 0; i < numBins; i++ {
+		edge := sorted[i*len(sorted)/numBins]
+
+		if len(edges) == 0 || edge > edges[len(edges)-1] {
+			edges = append(edges, edge)
+		}
+	}
+
+	return &QuantileBins{edges: edges}, nil
+}
+
+// Edges returns the lower edges of the bins in ascending order.
+func (q *QuantileBins) Edges() []float64 {
+	return append([]float64(nil), q.edges...)
+}
+
+// Quantize implements the Quantizer interface.
+func (q *QuantileBins) Quantize(value float64) float64 {
+	if len(q.edges) == 0 || math.IsNaN(value) {
+		return value
+	}
+
+	// Index of the first edge greater than the value.
+	index := sort.Search(len(q.edges), func(i int) bool { return q.edges[i] > value })
+	if index == 0 {
+		return q.edges[0]
+	}
+
+	return q.edges[index-1]
+}
+
+// ----------------------------------------------------------------------------
+//  Private methods
+// ----------------------------------------------------------------------------
+
+// quantize returns the representative value of the bin of the numeric item if
+// the quantizer is set. Otherwise, or if the item is not a number, it returns
+// the item as is.
+func (p *Predictor) quantize(item any) any {
+	if p.quantizer == nil {
+		return item
+	}
+
+	value, ok := toFloat64(item)
+	if !ok {
+		return item
+	}
+
+	return p.quantizer.Quantize(value)
+}
+
+// ----------------------------------------------------------------------------
+//  Private functions
+// ----------------------------------------------------------------------------
+
+// isNilQuantizer returns true if the quantizer is nil or a nil value of the
+// nilable kinds, such as `(*QuantileBins)(nil)`.
+func isNilQuantizer(quantizer Quantizer) bool {
+	if quantizer == nil {
+		return true
+	}
+
+	value := reflect.ValueOf(quantizer)
+
+	switch value.Kind() { //nolint:exhaustive // the other kinds are never nil
+	case reflect.Chan, reflect.Func, reflect.Interface, reflect.Map, reflect.Pointer, reflect.Slice:
+		return value.IsNil()
+	default:
+		return false
+	}
+}
+
+// toFloat64 converts the numeric item to float64. It returns false if the item
+// is not a number.
+func toFloat64(item any) (float64, bool) {
+	switch value := item.(type) {
+	case float64:
+		return value, true
+	case float32:
+		return float64(value), true
+	case int:
+		return float64(value), true
+	case int64:
+		return float64(value), true
+	case int32:
+		return float64(value), true
+	case int16:
+		return float64(value), true
+	case int8:
+		return float64(value), true
+	case uint:
+		return float64(value), true
+	case uint64:
+		return float64(value), true
+	case uint32:
+		return float64(value), true
+	case uint16:
+		return float64(value), true
+	case uint8:
+		return float64(value), true
+	}
+
+	return 0, false
+}
@@ -0,0 +1,232 @@
+package bayes
+
+import (
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// ----------------------------------------------------------------------------
+//  FixedWidth
+// ----------------------------------------------------------------------------
+
+func TestFixedWidth_Quantize(t *testing.T) {
+	t.Parallel()
+
+	quantizer := FixedWidth{Width: 0.5}
+
+	//nolint:varnamelen // tt is short but descriptive
+	for _, tt := range []struct {
+		input  float64
+		expect float64
+	}{
+		{0, 0},
+		{0.1, 0},
+		{0.49, 0},
+		{0.5, 0.5},
+		{0.9, 0.5},
+		{-0.1, -0.5},
+		{-0.5, -0.5},
+		{12.3, 12},
+	} {
+		assert.InDelta(t, tt.expect, quantizer.Quantize(tt.input), 0, "input: %v", tt.input)
+	}
+
+	// Origin shifts the bins
+	assert.InDelta(t, 0.25, FixedWidth{Width: 0.5, Origin: 0.25}.Quantize(0.5), 0)
+
+	// The values on the bounds stay in their own bin despite the rounding
+	assert.InDelta(t, 0.3, FixedWidth{Width: 0.1}.Quantize(0.3), 1e-12)
+	assert.InDelta(t, 0.7, FixedWidth{Width: 0.1}.Quantize(0.7), 1e-12)
+	assert.InDelta(t, 0.2, FixedWidth{Width: 0.1}.Quantize(0.29), 1e-12)
+
+	// Invalid width or values are returned as is
+	assert.InDelta(t, 0.1, FixedWidth{}.Quantize(0.1), 0)
+	assert.True(t, math.IsNaN(quantizer.Quantize(math.NaN())))
+	assert.True(t, math.IsInf(quantizer.Quantize(math.Inf(1)), 1))
+}
+
+// ----------------------------------------------------------------------------
+//  LogScale
+// ----------------------------------------------------------------------------
+
+func TestLogScale_Quantize(t *testing.T) {
+	t.Parallel()
+
+	quantizer := LogScale{}
+
+	//nolint:varnamelen // tt is short but descriptive
+	for _, tt := range []struct {
+		input  float64
+		expect float64
+	}{
+		{0, 0},
+		{0.05, 0.01},
+		{1, 1},
+		{9.9, 1},
+		{123, 100},
+		{1000, 1000},
+		{-123, -100},
+	} {
+		assert.InDelta(t, tt.expect, quantizer.Quantize(tt.input), 1e-12, "input: %v", tt.input)
+	}
+
+	assert.InDelta(t, 8.0, LogScale{Base: 2}.Quantize(10), 0)
+	assert.True(t, math.IsNaN(quantizer.Quantize(math.NaN())))
+}
+
+// ----------------------------------------------------------------------------
+//  QuantileBins
+// ----------------------------------------------------------------------------
+
+func TestNewQuantileBins(t *testing.T) {
+	t.Parallel()
+
+	samples := []float64{9, 1, 2, 3, 4, 5, 6, 7, 8, 10, math.NaN()}
+
+	quantizer, err := NewQuantileBins(samples, 5)
+	require.NoError(t, err)
+	require.Equal(t, []float64{1, 3, 5, 7, 9}, quantizer.Edges())
+
+	//nolint:varnamelen // tt is short but descriptive
+	for _, tt := range []struct {
+		input  float64
+		expect float64
+	}{
+		{-100, 1}, // below the lowest edge
+		{1, 1},
+		{2.9, 1},
+		{3, 3},
+		{8.5, 7},
+		{100, 9},
+	} {
+		assert.InDelta(t, tt.expect, quantizer.Quantize(tt.input), 0, "input: %v", tt.input)
+	}
+}
+
+func TestNewQuantileBins_duplicates(t *testing.T) {
+	t.Parallel()
+
+	quantizer, err := NewQuantileBins([]float64{1, 1, 1, 1, 2}, 4)
+	require.NoError(t, err)
+	require.Equal(t, []float64{1}, quantizer.Edges(), "duplicate edges should be merged")
+
+	edges := quantizer.Edges()
+	edges[0] = 100
+
+	require.Equal(t, []float64{1}, quantizer.Edges(), "edges should be a copy")
+}
+
+func TestQuantileBins_zero_value(t *testing.T) {
+	t.Parallel()
+
+	var quantizer QuantileBins
+
+	require.NotPanics(t, func() {
+		assert.InDelta(t, 1.5, quantizer.Quantize(1.5), 0, "zero value should return the value as is")
+	})
+	assert.Empty(t, quantizer.Edges())
+}
+
+func TestNewQuantileBins_errors(t *testing.T) {
+	t.Parallel()
+
+	_, err := NewQuantileBins([]float64{1, 2}, 0)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "number of the bins must be 1 or more")
+
+	_, err = NewQuantileBins([]float64{math.NaN()}, 2)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "no samples to learn the bins")
+}
+
+// ----------------------------------------------------------------------------
+//  Predictor
+// ----------------------------------------------------------------------------
+
+func TestPredictor_floats_are_distinct(t *testing.T) {
+	t.Parallel()
+
+	predictor, err := NewPredictor()
+	require.NoError(t, err)
+
+	require.NoError(t, predictor.Train([]any{"x", 0.1, "x", 0.5, "x", 0.9, "x", -0.5}))
+
+	predictions, err := predictor.PredictTopK([]any{"x"}, 0)
+	require.NoError(t, err)
+	require.Len(t, predictions, 4, "floats below 1.0 should not collapse into a class")
+
+	raws := make([]any, 0, len(predictions))
+
+	for _, prediction := range predictions {
+		raws = append(raws, prediction.Raw)
+	}
+
+	assert.ElementsMatch(t, []any{0.1, 0.5, 0.9, -0.5}, raws)
+}
+
+func TestPredictor_quantizer(t *testing.T) {
+	t.Parallel()
+
+	predictor, err := NewPredictor(WithQuantizer(FixedWidth{Width: 1}))
+	require.NoError(t, err)
+
+	// Readings of a sensor. The integers are also quantized.
+	require.NoError(t, predictor.Train([]any{0.2, 1.5, 0.7, 1.1, 0.4, 2, "off"}))
+
+	predictions, err := predictor.PredictTopK([]any{0.9}, 0)
+	require.NoError(t, err)
+	require.Len(t, predictions, 2, "bin 0 is followed by bin 1 twice and bin 2 once")
+	assert.Equal(t, 1.0, predictions[0].Raw, "original value should be the representative of the bin")
+	assert.Equal(t, 2.0, predictions[1].Raw)
+
+	classID, err := predictor.Predict([]any{int64(1)})
+	require.NoError(t, err)
+	assert.Equal(t, 0.0, predictor.GetClass(classID))
+
+	classID, err = predictor.Predict([]any{2.5})
+	require.NoError(t, err)
+	assert.Equal(t, "off", predictor.GetClass(classID), "non-numeric items should be as is")
+
+	// Untrain quantizes the items as well
+	require.NoError(t, predictor.Untrain([]any{0.2, 1.5, 0.7, 1.1, 0.4, 2, "off"}))
+
+	predictions, err = predictor.PredictTopK([]any{0.9}, 0)
+	require.NoError(t, err)
+	require.Empty(t, predictions)
+}
+
+func TestWithQuantizer_nil(t *testing.T) {
+	t.Parallel()
+
+	for _, quantizer := range []Quantizer{nil, (*QuantileBins)(nil)} {
+		predictor, err := NewPredictor(WithQuantizer(quantizer))
+		require.NoError(t, err)
+
+		// The nil quantizer is rejected and the floats stay distinct
+		require.NoError(t, predictor.Train([]any{0.5, 0.51, 0.52}))
+
+		classID, err := predictor.Predict([]any{0.5})
+		require.NoError(t, err)
+		assert.InDelta(t, 0.51, predictor.GetClass(classID), 0, "quantizer: %#v", quantizer)
+	}
+}
+
+//nolint:paralleltest // disable parallel test due to global variable change
+func TestSetQuantizer(t *testing.T) {
+	defer func() {
+		SetQuantizer(nil)
+		Reset()
+	}()
+
+	SetQuantizer(LogScale{})
+	Reset()
+
+	require.NoError(t, Train([]float64{150, 0.3, 120, 0.5}))
+
+	classID, err := Predict([]float64{199})
+	require.NoError(t, err)
+	assert.InDelta(t, 0.1, GetClass(classID), 1e-12)
+}
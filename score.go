@@ -0,0 +1,175 @@
+package bayes
+
+import (
+	"math"
+
+	"github.com/pkg/errors"
+)
+
+// ============================================================================
+//  Scoring of the sequences
+// ============================================================================
+//  `Score()` returns how likely the whole sequence is under the trained model,
+//  as the product of the probability of each item given the previous items.
+//  The probability of each step is the normalized one of `PredictTopK()`, so
+//  the context backs off to the shorter one as in `Predict()`. The threshold of
+//  `WithMinProbability()` does not apply.
+//
+//    P([a, b, c]) = P(b | [a]) * P(c | [a, b])
+//
+//  The product is computed as the sum of the logarithms to avoid the underflow
+//  of the long sequences. The first item has no context and is not scored,
+//  unless the boundaries are enabled. See `WithBoundaries()`.
+// ============================================================================
+
+// SequenceScore is the likelihood of a sequence. See `Predictor.Score()`.
+type SequenceScore struct {
+	// Steps are the scores of the items given their previous items, in the
+	// order of the sequence.
+	Steps []StepScore
+	// LogProbability is the natural logarithm of the probability of the whole
+	// sequence, which is the sum of the LogProbability of the steps. It is
+	// -Inf if any step is impossible under the model, and 0 if there are no
+	// steps.
+	LogProbability float64
+}
+
+// StepScore is the score of an item of a sequence given its previous items.
+type StepScore struct {
+	// Item is the item scored. StartOfSequence is never scored, while
+	// EndOfSequence is scored as the last step if the boundaries are enabled.
+	Item any
+	// ClassID is the ID of the item.
+	ClassID uint64
+	// Index is the index of the item in the given sequence. The index of
+	// EndOfSequence is the length of the sequence.
+	Index int
+	// Probability is the probability of the item given the previous items. It
+	// is 0 if the item has never followed the context.
+	Probability float64
+	// LogProbability is the natural logarithm of Probability.
+	LogProbability float64
+	// Order is the number of the previous items used as the context. It is 0
+	// if none of the suffixes of the context is known.
+	Order int
+}
+
+// ----------------------------------------------------------------------------
+//  Public functions
+// ----------------------------------------------------------------------------
+
+// Score returns the likelihood of the items as a sequence under the default
+// predictor. See `Predictor.Score()` for details.
+func Score[T any](items []T) (SequenceScore, error) {
+	predictor := getPredictor()
+	if predictor == nil {
+		return SequenceScore{}, errors.New("predictor is not initialized")
+	}
+
+	return predictor.Score(toAnySlice(items))
+}
+
+// ----------------------------------------------------------------------------
+//  Methods
+// ----------------------------------------------------------------------------
+
+// Perplexity returns the perplexity of the sequence, which is the inverse of
+// the geometric mean of the probabilities of the steps. Unlike LogProbability,
+// it does not depend on the length of the sequence, so the sequences of the
+// different lengths can be compared. The lower the more likely. It returns +Inf
+// if any step is impossible, and 1 if there are no steps.
+func (s SequenceScore) Perplexity() float64 {
+	if len(s.Steps) == 0 {
+		return 1
+	}
+
+	return math.Exp(-s.LogProbability / float64(len(s.Steps)))
+}
+
+// Score returns the likelihood of the items as a sequence under the trained
+// model, with the probability of each item given its previous items. The items
+// are hashed and truncated to the maximum order as in `Train()`. Use it to
+// compare and rank the candidate sequences.
+//
+// If no suffix of the context of an item is known, or the item has never
+// followed the longest known suffix, the probability of the step is 0 and the
+// LogProbability of the sequence is -Inf. Set `WithSmoothing()` to give small
+// probabilities to the unseen items instead.
+func (p *Predictor) Score(items []any) (SequenceScore, error) {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	if p.nodeLogger == nil {
+		return SequenceScore{}, errors.New("predictor is not initialized")
+	}
+
+	itemIDs, err := p.itemIDs(items)
+	if err != nil {
+		return SequenceScore{}, errors.Wrap(err, "failed to score the items")
+	}
+
+	quantized := make([]any, len(items))
+
+	for i, item := range items {
+		quantized[i] = p.quantize(item)
+	}
+
+	return p.scoreIDs(itemIDs, quantized)
+}
+
+// ----------------------------------------------------------------------------
+//  Private methods
+// ----------------------------------------------------------------------------
+
+// scoreIDs returns the score of the items given with their IDs. The items must
+// be quantized beforehand.
+func (p *Predictor) scoreIDs(itemIDs []uint64, items []any) (SequenceScore, error) {
+	boundIDs, boundItems := p.bound(itemIDs, items)
+
+	// Index of the items in boundIDs is shifted by the start marker.
+	shift := 0
+	if len(boundIDs) > len(itemIDs) {
+		shift = 1
+	}
+
+	score := SequenceScore{Steps: make([]StepScore, 0, len(boundIDs))}
+
+	for i := 1; i < len(boundIDs); i++ {
+		step, err := p.scoreStep(boundIDs[:i], boundIDs[i])
+		if err != nil {
+			return SequenceScore{}, err
+		}
+
+		step.Item = boundItems[i]
+		step.Index = i - shift
+
+		score.Steps = append(score.Steps, step)
+		score.LogProbability += step.LogProbability
+	}
+
+	return score, nil
+}
+
+// scoreStep returns the score of the class ID following the context IDs. Item
+// and Index are left to the caller.
+func (p *Predictor) scoreStep(contextIDs []uint64, classID uint64) (StepScore, error) {
+	step := StepScore{ClassID: classID, LogProbability: math.Inf(-1)}
+
+	predictions, err := p.predictIDs(contextIDs)
+	if err != nil {
+		return StepScore{}, err
+	}
+
+	for _, prediction := range predictions {
+		step.Order = prediction.Order
+
+		if prediction.ClassID == classID {
+			step.Probability = prediction.Probability
+			step.LogProbability = math.Log(prediction.Probability)
+
+			break
+		}
+	}
+
+	return step, nil
+}
@@ -0,0 +1,123 @@
+package bayes
+
+import (
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// ----------------------------------------------------------------------------
+//  Predictor
+// ----------------------------------------------------------------------------
+
+func TestPredictor_Score(t *testing.T) {
+	t.Parallel()
+
+	predictor := newTrainedPredictor(t, [][]any{{"a", "b", "a", "c"}})
+
+	score, err := predictor.Score([]any{"a", "b", "a", "c"})
+	require.NoError(t, err)
+	require.Len(t, score.Steps, 3, "the first item should not be scored")
+
+	// Each step should be the probability of PredictTopK() for the item
+	expectLogProb := 0.0
+
+	for i, step := range score.Steps {
+		predictions, err := predictor.PredictTopK([]any{"a", "b", "a", "c"}[:i+1], 0)
+		require.NoError(t, err)
+
+		expect := StepScore{Item: step.Item, ClassID: mustConv(t, step.Item), Index: i + 1}
+
+		for _, prediction := range predictions {
+			if prediction.Raw == step.Item {
+				expect.Probability = prediction.Probability
+				expect.LogProbability = math.Log(prediction.Probability)
+				expect.Order = prediction.Order
+			}
+		}
+
+		require.NotZero(t, expect.Probability)
+		assert.Equal(t, expect, step, "step #%d", i)
+
+		expectLogProb += expect.LogProbability
+	}
+
+	assert.Equal(t, []any{"b", "a", "c"}, []any{score.Steps[0].Item, score.Steps[1].Item, score.Steps[2].Item})
+	assert.InDelta(t, expectLogProb, score.LogProbability, 1e-12)
+	assert.InDelta(t, math.Exp(-expectLogProb/3), score.Perplexity(), 1e-12)
+
+	// Backs off to the shorter context
+	score, err = predictor.Score([]any{"x", "c", "a", "b"})
+	require.NoError(t, err)
+	assert.Equal(t, 0, score.Steps[0].Order, "unknown context should be the order of 0")
+	assert.Equal(t, 1, score.Steps[2].Order, "[x, c, a] should back off to [a]")
+	assert.InDelta(t, 1.0/3, score.Steps[2].Probability, 1e-12, "P(b | [a]) should be the one of PredictTopK()")
+}
+
+func TestPredictor_Score_impossible(t *testing.T) {
+	t.Parallel()
+
+	predictor := newTrainedPredictor(t, [][]any{{"a", "b"}})
+
+	score, err := predictor.Score([]any{"a", "z"})
+	require.NoError(t, err)
+
+	assert.Zero(t, score.Steps[0].Probability)
+	assert.True(t, math.IsInf(score.LogProbability, -1))
+	assert.True(t, math.IsInf(score.Perplexity(), 1))
+
+	// No steps
+	for _, items := range [][]any{nil, {"a"}} {
+		score, err = predictor.Score(items)
+		require.NoError(t, err)
+
+		assert.Empty(t, score.Steps)
+		assert.Zero(t, score.LogProbability)
+		assert.InDelta(t, 1.0, score.Perplexity(), 0)
+	}
+
+	_, err = predictor.Score([]any{"a", struct{}{}})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "failed to score the items")
+}
+
+func TestPredictor_Score_boundaries(t *testing.T) {
+	t.Parallel()
+
+	predictor := newTrainedPredictor(t, [][]any{{"a", "b"}, {"a", "c"}}, WithBoundaries())
+
+	score, err := predictor.Score([]any{"a", "b"})
+	require.NoError(t, err)
+	require.Len(t, score.Steps, 3, "first item and the end should be scored")
+
+	assert.Equal(t, "a", score.Steps[0].Item)
+	assert.Equal(t, 0, score.Steps[0].Index)
+	assert.Equal(t, EndOfSequence, score.Steps[2].Item)
+	assert.Equal(t, 2, score.Steps[2].Index)
+	assert.InDelta(t, math.Log(0.5), score.LogProbability, 1e-12)
+}
+
+func TestPredictor_Score_underflow(t *testing.T) {
+	t.Parallel()
+
+	predictor, err := NewPredictor(WithMaxOrder(1))
+	require.NoError(t, err)
+
+	// P(b | a) = P(c | a) = 0.5. The product of 2000 steps of 0.5 underflows.
+	items := make([]any, 0, 4000)
+
+	for i := 0; i < 1000; i++ {
+		items = append(items, "a", "b", "a", "c")
+	}
+
+	require.NoError(t, predictor.Train(items))
+
+	score, err := predictor.Score(items)
+	require.NoError(t, err)
+
+	require.Zero(t, math.Exp(score.LogProbability), "probability itself should underflow")
+	assert.InDelta(t, 2000*math.Log(0.5), score.LogProbability, 1e-6)
+	assert.InDelta(t, math.Sqrt(2), score.Perplexity(), 1e-3)
+}
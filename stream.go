@@ -0,0 +1,309 @@
+package bayes
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// ============================================================================
+//  Stream training
+// ============================================================================
+//  The stream trainers train a single sequence item by item, keeping the
+//  rolling context across the chunks. Unlike `Train()`, the whole sequence
+//  does not need to be in memory.
+//
+//  The items are trained in chunks of the items already available, such as
+//  the ones buffered from the reader, up to StreamChunkMax. The lock of the
+//  predictor and the batch of the node logger are held per chunk, so the
+//  predictions are not blocked until the end of the stream, while the storage
+//  such as SQLite3 does not pay a transaction per item.
+//
+//  Since every suffix of the context is trained, the context of the unlimited
+//  order is capped at StreamContextMax. Otherwise the cost of each item grows
+//  with the number of the items read so far, and so does the memory to keep
+//  them.
+// ============================================================================
+
+// StreamContextMax is the maximum number of the previous items trained as the
+// context of the stream trainers if the maximum order of the predictor is
+// unlimited. Set `WithMaxOrder()` for the other orders.
+const StreamContextMax = 32
+
+// StreamChunkMax is the maximum number of the items the stream trainers train
+// at once, in a single batch of the node logger.
+const StreamChunkMax = 1024
+
+// streamBufferSize is the size of the buffer to read the stream. The lines in
+// the buffer are trained in a chunk.
+const streamBufferSize = 64 * 1024
+
+// StreamFormat is the format of the items read by `TrainReader()`.
+type StreamFormat int
+
+const (
+	// FormatLines reads each line as a string item. Empty lines are skipped.
+	FormatLines StreamFormat = iota
+	// FormatJSONLines reads each line as a JSON value. Strings and booleans
+	// are read as is. Numbers are read as int64 if they are integers, otherwise
+	// as float64. Empty lines are skipped.
+	FormatJSONLines
+)
+
+// ----------------------------------------------------------------------------
+//  Public functions
+// ----------------------------------------------------------------------------
+
+// TrainChan trains the default predictor with the items received from the
+// channel as a single sequence, until the channel is closed or the ctx is
+// done.
+//
+// On cancellation, it returns the error of the ctx. The items received before
+// the cancellation are kept trained.
+func TrainChan[T any](ctx context.Context, items <-chan T) error {
+	predictor := getPredictor()
+	if predictor == nil {
+		Reset()
+
+		predictor = getPredictor()
+	}
+
+	return predictor.trainStream(ctx, func() (any, bool, error) {
+		select {
+		case <-ctx.Done():
+			return nil, false, ctx.Err() //nolint:wrapcheck // wrapped by the caller
+		case item, ok := <-items:
+			return item, ok, nil
+		}
+	}, func() bool { return len(items) > 0 })
+}
+
+// TrainReader trains the default predictor with the items read from r in the
+// given format as a single sequence, until EOF or the ctx is done.
+//
+// On cancellation, it returns the error of the ctx. The items read before the
+// cancellation are kept trained.
+func TrainReader(ctx context.Context, r io.Reader, format StreamFormat) error {
+	predictor := getPredictor()
+	if predictor == nil {
+		Reset()
+
+		predictor = getPredictor()
+	}
+
+	return predictor.TrainReader(ctx, r, format)
+}
+
+// ----------------------------------------------------------------------------
+//  Methods
+// ----------------------------------------------------------------------------
+
+// TrainChan trains the predictor with the items received from the channel as
+// a single sequence, until the channel is closed or the ctx is done.
+//
+// On cancellation, it returns the error of the ctx. The items received before
+// the cancellation are kept trained.
+func (p *Predictor) TrainChan(ctx context.Context, items <-chan any) error {
+	return p.trainStream(ctx, func() (any, bool, error) {
+		select {
+		case <-ctx.Done():
+			return nil, false, ctx.Err() //nolint:wrapcheck // wrapped by the caller
+		case item, ok := <-items:
+			return item, ok, nil
+		}
+	}, func() bool { return len(items) > 0 })
+}
+
+// TrainReader trains the predictor with the items read from r in the given
+// format as a single sequence, until EOF or the ctx is done.
+//
+// On cancellation, it returns the error of the ctx. The items read before the
+// cancellation are kept trained.
+func (p *Predictor) TrainReader(ctx context.Context, r io.Reader, format StreamFormat) error {
+	var parse func(line string) (any, error)
+
+	switch format {
+	case FormatLines:
+		parse = func(line string) (any, error) { return line, nil }
+	case FormatJSONLines:
+		parse = parseJSONItem
+	default:
+		return errors.Errorf("unknown stream format: %d", format)
+	}
+
+	reader := bufio.NewReaderSize(r, streamBufferSize)
+	lineNum := 0
+
+	return p.trainStream(ctx, func() (any, bool, error) {
+		for {
+			if err := ctx.Err(); err != nil {
+				return nil, false, err //nolint:wrapcheck // wrapped by the caller
+			}
+
+			// ReadString is used instead of bufio.Scanner to read the lines
+			// regardless of the length.
+			line, err := reader.ReadString('\n')
+			if err != nil && !errors.Is(err, io.EOF) {
+				return nil, false, errors.Wrap(err, "failed to read the stream")
+			}
+
+			if line == "" && err != nil {
+				return nil, false, nil // EOF
+			}
+
+			lineNum++
+
+			line = strings.TrimRight(line, "\r\n")
+			if line == "" {
+				continue
+			}
+
+			item, errParse := parse(line)
+			if errParse != nil {
+				return nil, false, errors.Wrapf(errParse, "failed to parse line %d", lineNum)
+			}
+
+			return item, true, nil
+		}
+	}, func() bool { return reader.Buffered() > 0 })
+}
+
+// ----------------------------------------------------------------------------
+//  Private methods
+// ----------------------------------------------------------------------------
+
+// trainStream trains the predictor with the items returned by next as a single
+// sequence, until next returns false or an error. The items are trained in
+// chunks while pending returns true, which tells that next returns without
+// blocking. The context is capped at StreamContextMax if the order is
+// unlimited.
+//
+// If the boundaries are enabled, StartOfSequence is trained before the first
+// item and EndOfSequence after the last item. On error or cancellation, the
+// items got before are trained but the sequence is not ended.
+func (p *Predictor) trainStream(ctx context.Context, next func() (any, bool, error), pending func() bool) error {
+	trainer := p.newTrainer()
+	trainer.maxDrill = StreamContextMax
+	chunk := make([]any, 0, StreamChunkMax)
+	got := false
+
+	p.mu.RLock()
+	boundaries := p.boundaries
+	p.mu.RUnlock()
+
+	if boundaries {
+		chunk = append(chunk, StartOfSequence)
+	}
+
+	for {
+		item, ok, err := next()
+		if err != nil {
+			if got && len(chunk) > 0 {
+				if errTrain := p.trainSteps(trainer, chunk); errTrain != nil {
+					return errTrain
+				}
+			}
+
+			if ctxErr := ctx.Err(); ctxErr != nil && errors.Is(err, ctxErr) {
+				return errors.Wrap(err, "training is canceled")
+			}
+
+			return errors.Wrap(err, "failed to get the next item")
+		}
+
+		if !ok {
+			if !got {
+				return nil
+			}
+
+			if boundaries {
+				chunk = append(chunk, EndOfSequence)
+			}
+
+			if len(chunk) == 0 {
+				return nil
+			}
+
+			return p.trainSteps(trainer, chunk)
+		}
+
+		chunk = append(chunk, item)
+		got = true
+
+		if len(chunk) >= StreamChunkMax || !pending() {
+			if err := p.trainSteps(trainer, chunk); err != nil {
+				return err
+			}
+
+			chunk = chunk[:0]
+		}
+	}
+}
+
+// trainSteps trains the predictor with the items of the sequence in a single
+// lock and batch. On an invalid item, the items before it are kept trained.
+func (p *Predictor) trainSteps(trainer *_Trainer, items []any) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.nodeLogger == nil {
+		return errors.New("predictor is not initialized")
+	}
+
+	var errStep error
+
+	err := p.batch(func() error {
+		for _, item := range items {
+			if errStep = trainer.step(item); errStep != nil {
+				break
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	return errStep
+}
+
+// ----------------------------------------------------------------------------
+//  Private functions
+// ----------------------------------------------------------------------------
+
+// parseJSONItem parses a line of JSON Lines into an item.
+func parseJSONItem(line string) (any, error) {
+	decoder := json.NewDecoder(bytes.NewReader([]byte(line)))
+	decoder.UseNumber()
+
+	var value any
+
+	if err := decoder.Decode(&value); err != nil {
+		return nil, errors.Wrap(err, "invalid JSON")
+	}
+
+	if decoder.More() {
+		return nil, errors.New("invalid JSON. Only one value per line is allowed")
+	}
+
+	switch item := value.(type) {
+	case string, bool:
+		return item, nil
+	case json.Number:
+		if integer, err := item.Int64(); err == nil {
+			return integer, nil
+		}
+
+		float, err := item.Float64()
+
+		return float, errors.Wrap(err, "invalid number")
+	}
+
+	return nil, errors.Errorf("unsupported JSON value type: %T", value)
+}
@@ -0,0 +1,245 @@
+package bayes
+
+import (
+	"context"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// ----------------------------------------------------------------------------
+//  TrainReader
+// ----------------------------------------------------------------------------
+
+func TestPredictor_TrainReader_same_as_Train(t *testing.T) {
+	t.Parallel()
+
+	items := []any{"a", "b", "c", "a", "b", "d", "a", "b", "c"}
+
+	expect := mustSaveTrained(t, func(p *Predictor) error { return p.Train(items) })
+	actual := mustSaveTrained(t, func(p *Predictor) error {
+		// Long lines, CRLF, empty lines and no newline at the end
+		input := "a\r\nb\n\nc\na\nb\nd\na\nb\nc"
+
+		return p.TrainReader(context.Background(), strings.NewReader(input), FormatLines)
+	})
+
+	assert.Equal(t, expect, actual, "streamed items should be trained as a single sequence")
+}
+
+func TestPredictor_TrainReader_chunks(t *testing.T) {
+	t.Parallel()
+
+	// More items than a chunk, with the order capped to keep the test fast
+	items := make([]any, StreamChunkMax*2+10)
+
+	var input strings.Builder
+
+	for i := range items {
+		items[i] = strconv.Itoa(i % 7)
+		input.WriteString(strconv.Itoa(i%7) + "\n")
+	}
+
+	opts := []Option{WithMaxOrder(2), WithBoundaries()}
+
+	expect := mustSaveTrained(t, func(p *Predictor) error { return p.Train(items) }, opts...)
+	actual := mustSaveTrained(t, func(p *Predictor) error {
+		return p.TrainReader(context.Background(), strings.NewReader(input.String()), FormatLines)
+	}, opts...)
+
+	assert.Equal(t, expect, actual, "the chunks should be trained as a single sequence")
+}
+
+func TestPredictor_TrainReader_error_keeps_trained(t *testing.T) {
+	t.Parallel()
+
+	predictor, err := NewPredictor()
+	require.NoError(t, err)
+
+	err = predictor.TrainReader(context.Background(), strings.NewReader("\"a\"\n\"b\"\nbroken\n"), FormatJSONLines)
+	require.Error(t, err)
+
+	// The items of the chunk before the error are trained
+	predictions, err := predictor.PredictTopK([]any{"a"}, 1)
+	require.NoError(t, err)
+	require.Len(t, predictions, 1)
+	assert.Equal(t, "b", predictions[0].Raw)
+}
+
+func TestPredictor_TrainReader_json_lines(t *testing.T) {
+	t.Parallel()
+
+	expect := mustSaveTrained(t, func(p *Predictor) error {
+		return p.Train([]any{"a", int64(1), 1.5, true, int64(-2), "a"})
+	})
+	actual := mustSaveTrained(t, func(p *Predictor) error {
+		input := "\"a\"\n1\n1.5\ntrue\n\n-2\n\"a\"\n"
+
+		return p.TrainReader(context.Background(), strings.NewReader(input), FormatJSONLines)
+	})
+
+	assert.Equal(t, expect, actual)
+}
+
+func TestPredictor_TrainReader_context_max(t *testing.T) {
+	t.Parallel()
+
+	predictor, err := NewPredictor()
+	require.NoError(t, err)
+
+	var input strings.Builder
+
+	items := make([]any, 100)
+
+	for i := range items {
+		items[i] = strconv.Itoa(i)
+		input.WriteString(strconv.Itoa(i) + "\n")
+	}
+
+	require.NoError(t, predictor.TrainReader(context.Background(), strings.NewReader(input.String()), FormatLines))
+
+	// The contexts longer than the cap are not trained
+	predictions, err := predictor.PredictTopK(items[:99], 1)
+	require.NoError(t, err)
+	require.Len(t, predictions, 1)
+	assert.Equal(t, "99", predictions[0].Raw)
+	assert.Equal(t, StreamContextMax, predictions[0].Order, "context should be capped on streaming")
+}
+
+func TestPredictor_TrainReader_errors(t *testing.T) {
+	t.Parallel()
+
+	//nolint:varnamelen // tt is short but descriptive
+	for _, tt := range []struct {
+		input  string
+		expect string
+		format StreamFormat
+	}{
+		{"a\n", "unknown stream format: 99", StreamFormat(99)},
+		{"\"a\"\n{\"b\":1}\n", "failed to parse line 2: unsupported JSON value type", FormatJSONLines},
+		{"\"a\"\n\nbroken\n", "failed to parse line 3: invalid JSON", FormatJSONLines},
+		{"1 2\n", "Only one value per line is allowed", FormatJSONLines},
+		{"1e999\n", "invalid number", FormatJSONLines},
+	} {
+		predictor, err := NewPredictor()
+		require.NoError(t, err)
+
+		err = predictor.TrainReader(context.Background(), strings.NewReader(tt.input), tt.format)
+
+		require.Error(t, err, "input: %q", tt.input)
+		assert.Contains(t, err.Error(), tt.expect)
+	}
+}
+
+func TestPredictor_TrainReader_canceled(t *testing.T) {
+	t.Parallel()
+
+	predictor, err := NewPredictor()
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	err = predictor.TrainReader(ctx, strings.NewReader("a\nb\n"), FormatLines)
+
+	require.ErrorIs(t, err, context.Canceled)
+	assert.Contains(t, err.Error(), "training is canceled")
+}
+
+// ----------------------------------------------------------------------------
+//  TrainChan
+// ----------------------------------------------------------------------------
+
+func TestPredictor_TrainChan(t *testing.T) {
+	t.Parallel()
+
+	items := []any{1, 2, 3, 1, 2, 4, 1, 2, 3}
+
+	expect := mustSaveTrained(t, func(p *Predictor) error { return p.Train(items) })
+	actual := mustSaveTrained(t, func(p *Predictor) error {
+		ch := make(chan any)
+
+		go func() {
+			defer close(ch)
+
+			for _, item := range items {
+				ch <- item
+			}
+		}()
+
+		return p.TrainChan(context.Background(), ch)
+	})
+
+	assert.Equal(t, expect, actual)
+}
+
+func TestPredictor_TrainChan_canceled(t *testing.T) {
+	t.Parallel()
+
+	predictor, err := NewPredictor()
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	ch := make(chan any)
+
+	go func() {
+		ch <- "a"
+		ch <- "b"
+
+		cancel() // never closes the channel
+	}()
+
+	err = predictor.TrainChan(ctx, ch)
+
+	require.ErrorIs(t, err, context.Canceled)
+
+	classID, err := predictor.Predict([]any{"a"})
+	require.NoError(t, err, "items received before the cancellation should be kept trained")
+	assert.Equal(t, "b", predictor.GetClass(classID))
+}
+
+func TestPredictor_TrainChan_unsupported_item(t *testing.T) {
+	t.Parallel()
+
+	predictor, err := NewPredictor()
+	require.NoError(t, err)
+
+	ch := make(chan any, 2)
+	ch <- "a"
+	ch <- []int{1}
+
+	close(ch)
+
+	err = predictor.TrainChan(context.Background(), ch)
+
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "failed during training iteration")
+}
+
+//nolint:paralleltest // disable parallel test due to global variable change
+func TestTrainChan(t *testing.T) {
+	defer Reset()
+
+	Reset()
+
+	ch := make(chan string, 3)
+	ch <- "foo"
+	ch <- "bar"
+	ch <- "foo"
+
+	close(ch)
+
+	require.NoError(t, TrainChan(context.Background(), ch))
+
+	classID, err := Predict([]string{"foo"})
+	require.NoError(t, err)
+	assert.Equal(t, "bar", GetClass(classID))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	require.ErrorIs(t, TrainChan(ctx, make(chan string)), context.Canceled)
+}